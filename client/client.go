@@ -11,6 +11,22 @@ import (
 )
 
 func NewMemoryStore(cfg *Config, opts ...model.Option) (protocol.MemoryStore, error) {
+	clt, err := newTableStoreClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return tb.NewMemoryStore(clt, opts...), nil
+}
+
+func NewKnowledgeStore(cfg *Config, opts ...model.KnowledgeOption) (protocol.KnowledgeStore, error) {
+	clt, err := newTableStoreClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return tb.NewKnowledgeStore(clt, opts...), nil
+}
+
+func newTableStoreClient(cfg *Config) (*tablestore.TableStoreClient, error) {
 	if cfg == nil {
 		return nil, fmt.Errorf("config must not be nil")
 	}
@@ -27,6 +43,5 @@ func NewMemoryStore(cfg *Config, opts ...model.Option) (protocol.MemoryStore, er
 		return nil, fmt.Errorf("config.AccessKeySecret is required")
 	}
 
-	clt := tablestore.NewClientWithConfig(cfg.Endpoint, cfg.Instance, cfg.AccessKeyID, cfg.AccessKeySecret, cfg.SecurityToken, nil)
-	return tb.NewMemoryStore(clt, opts...), nil
+	return tablestore.NewClientWithConfig(cfg.Endpoint, cfg.Instance, cfg.AccessKeyID, cfg.AccessKeySecret, cfg.SecurityToken, nil), nil
 }
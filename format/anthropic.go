@@ -0,0 +1,158 @@
+package format
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+// AnthropicRequest system prompt and messages of an Anthropic Messages request
+type AnthropicRequest struct {
+	System   string             `json:"system,omitempty"`
+	Messages []AnthropicMessage `json:"messages"`
+}
+
+type AnthropicMessage struct {
+	Role    string             `json:"role"`
+	Content []AnthropicContent `json:"content"`
+}
+
+// AnthropicContent content block of an Anthropic message
+type AnthropicContent struct {
+	Type      string                `json:"type"`
+	Text      string                `json:"text,omitempty"`
+	Source    *AnthropicImageSource `json:"source,omitempty"`
+	ID        string                `json:"id,omitempty"`
+	Name      string                `json:"name,omitempty"`
+	Input     json.RawMessage       `json:"input,omitempty"`
+	ToolUseID string                `json:"tool_use_id,omitempty"`
+	Content   string                `json:"content,omitempty"`
+}
+
+type AnthropicImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+// ToAnthropic renders messages as an Anthropic Messages request. System and developer messages
+// are joined into the system prompt, tool messages become tool_result blocks of a user turn and
+// consecutive messages of the same role are merged since Anthropic requires alternating roles.
+func ToAnthropic(messages []model.Message) (*AnthropicRequest, error) {
+	var (
+		ret    = new(AnthropicRequest)
+		system []string
+	)
+	for idx := range messages {
+		message := &messages[idx]
+		role := message.Role()
+		switch role {
+		case model.RoleSystem, model.RoleDeveloper:
+			if message.Content != "" {
+				system = append(system, message.Content)
+			}
+			continue
+		case model.RoleTool:
+			ret.appendContent("user", AnthropicContent{
+				Type:      "tool_result",
+				ToolUseID: message.ToolCallID(),
+				Content:   message.Content,
+			})
+			continue
+		case model.RoleAssistant:
+		default:
+			role = model.RoleUser
+		}
+		var blocks []AnthropicContent
+		if message.Content != "" {
+			blocks = append(blocks, AnthropicContent{Type: "text", Text: message.Content})
+		}
+		images, err := message.Images()
+		if err != nil {
+			return nil, fmt.Errorf("render message %s failed, %w", message.MessageID, err)
+		}
+		for _, image := range images {
+			blocks = append(blocks, AnthropicContent{Type: "image", Source: anthropicImageSource(image)})
+		}
+		calls, err := message.ToolCalls()
+		if err != nil {
+			return nil, fmt.Errorf("render message %s failed, %w", message.MessageID, err)
+		}
+		for _, call := range calls {
+			blocks = append(blocks, AnthropicContent{
+				Type:  "tool_use",
+				ID:    call.ID,
+				Name:  call.Name,
+				Input: rawArguments(call.Arguments),
+			})
+		}
+		ret.appendContent(string(role), blocks...)
+	}
+	ret.System = strings.Join(system, "\n\n")
+	return ret, nil
+}
+
+func (r *AnthropicRequest) appendContent(role string, blocks ...AnthropicContent) {
+	if len(blocks) == 0 {
+		return
+	}
+	if l := len(r.Messages); l > 0 && r.Messages[l-1].Role == role {
+		r.Messages[l-1].Content = append(r.Messages[l-1].Content, blocks...)
+		return
+	}
+	r.Messages = append(r.Messages, AnthropicMessage{Role: role, Content: blocks})
+}
+
+func anthropicImageSource(image model.Image) *AnthropicImageSource {
+	if len(image.Data) > 0 {
+		return &AnthropicImageSource{
+			Type:      "base64",
+			MediaType: imageMediaType(image),
+			Data:      base64Encode(image.Data),
+		}
+	}
+	if mediaType, data, ok := parseDataURL(image.URL); ok {
+		return &AnthropicImageSource{
+			Type:      "base64",
+			MediaType: mediaType,
+			Data:      base64Encode(data),
+		}
+	}
+	return &AnthropicImageSource{
+		Type: "url",
+		URL:  image.URL,
+	}
+}
+
+type anthropicResponse struct {
+	ID      string             `json:"id"`
+	Content []AnthropicContent `json:"content"`
+}
+
+// ParseAnthropic parses an Anthropic Messages response into an assistant message
+func ParseAnthropic(sessionID string, data []byte) (*model.Message, error) {
+	var resp anthropicResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("decode anthropic response failed, %w", err)
+	}
+	var (
+		content string
+		calls   []model.ToolCall
+	)
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			content += block.Text
+		case "tool_use":
+			calls = append(calls, model.ToolCall{
+				ID:        block.ID,
+				Name:      block.Name,
+				Arguments: string(block.Input),
+			})
+		}
+	}
+	return newAssistantMessage(sessionID, resp.ID, content, calls)
+}
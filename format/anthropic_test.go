@@ -0,0 +1,73 @@
+package format
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+func TestToAnthropic(t *testing.T) {
+	req, err := ToAnthropic(conversation(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertJSON(t, req, `{"system":"be brief","messages":[`+
+		`{"role":"user","content":[{"type":"text","text":"weather here?"},{"type":"image","source":{"type":"base64","media_type":"image/png","data":"cG5n"}}]},`+
+		`{"role":"assistant","content":[{"type":"tool_use","id":"call_1","name":"get_weather","input":{"city":"Paris"}}]},`+
+		`{"role":"user","content":[{"type":"tool_result","tool_use_id":"call_1","content":"sunny"}]},`+
+		`{"role":"assistant","content":[{"type":"text","text":"It is sunny."}]}]}`)
+}
+
+func TestToAnthropic_MergeRoles(t *testing.T) {
+	first := model.NewMessageWithTime("session_1", "m1", 1).SetContent("hello")
+	second := model.NewMessageWithTime("session_1", "m2", 2).SetContent("are you there?").SetRole(model.RoleUser)
+	if err := second.SetImages([]model.Image{{URL: "https://example.com/a.png"}}); err != nil {
+		t.Fatal(err)
+	}
+	req, err := ToAnthropic([]model.Message{*first, *second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertJSON(t, req, `{"messages":[{"role":"user","content":[`+
+		`{"type":"text","text":"hello"},{"type":"text","text":"are you there?"},{"type":"image","source":{"type":"url","url":"https://example.com/a.png"}}]}]}`)
+}
+
+func TestToAnthropic_ImageMediaType(t *testing.T) {
+	message := model.NewMessageWithTime("session_1", "m1", 1).SetRole(model.RoleUser)
+	if err := message.SetImages([]model.Image{
+		{Data: []byte("png")},
+		{Data: []byte("\xff\xd8\xff\xe0")},
+		{Data: []byte("png"), MediaType: "image/webp"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	req, err := ToAnthropic([]model.Message{*message})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertJSON(t, req, `{"messages":[{"role":"user","content":[`+
+		`{"type":"image","source":{"type":"base64","media_type":"image/png","data":"cG5n"}},`+
+		`{"type":"image","source":{"type":"base64","media_type":"image/jpeg","data":"/9j/4A=="}},`+
+		`{"type":"image","source":{"type":"base64","media_type":"image/webp","data":"cG5n"}}]}]}`)
+}
+
+func TestParseAnthropic(t *testing.T) {
+	data := []byte(`{"id":"msg_1","type":"message","role":"assistant","content":[` +
+		`{"type":"text","text":"checking"},{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"Paris"}}]}`)
+	message, err := ParseAnthropic("session_1", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.MessageID != "msg_1" || message.Content != "checking" || message.Role() != model.RoleAssistant {
+		t.Errorf("unexpected message: %+v", message)
+	}
+	calls, err := message.ToolCalls()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []model.ToolCall{{ID: "toolu_1", Name: "get_weather", Arguments: `{"city":"Paris"}`}}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("tool calls not equal:\n%+v\n%+v", calls, expected)
+	}
+}
@@ -0,0 +1,2 @@
+// Package format converts messages to and from LLM provider wire formats
+package format
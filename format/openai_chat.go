@@ -0,0 +1,144 @@
+package format
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+// OpenAIChatMessage message of the OpenAI Chat Completions API
+type OpenAIChatMessage struct {
+	Role string `json:"role"`
+	// Content string, []OpenAIChatContentPart or nil
+	Content    any              `json:"content,omitempty"`
+	Name       string           `json:"name,omitempty"`
+	ToolCalls  []OpenAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+// OpenAIChatContentPart content part of a multimodal chat message
+type OpenAIChatContentPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *OpenAIImageURL `json:"image_url,omitempty"`
+}
+
+type OpenAIImageURL struct {
+	URL string `json:"url"`
+}
+
+type OpenAIToolCall struct {
+	ID       string             `json:"id"`
+	Type     string             `json:"type"`
+	Function OpenAIFunctionCall `json:"function"`
+}
+
+type OpenAIFunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// ToOpenAIChat renders messages as the messages array of a Chat Completions request
+func ToOpenAIChat(messages []model.Message) ([]OpenAIChatMessage, error) {
+	ret := make([]OpenAIChatMessage, 0, len(messages))
+	for idx := range messages {
+		message := &messages[idx]
+		role := message.Role()
+		if role == "" {
+			role = model.RoleUser
+		}
+		item := OpenAIChatMessage{
+			Role:       string(role),
+			Name:       message.Name(),
+			ToolCallID: message.ToolCallID(),
+		}
+		images, err := message.Images()
+		if err != nil {
+			return nil, fmt.Errorf("render message %s failed, %w", message.MessageID, err)
+		}
+		if len(images) > 0 {
+			parts := make([]OpenAIChatContentPart, 0, len(images)+1)
+			if message.Content != "" {
+				parts = append(parts, OpenAIChatContentPart{Type: "text", Text: message.Content})
+			}
+			for _, image := range images {
+				parts = append(parts, OpenAIChatContentPart{Type: "image_url", ImageURL: &OpenAIImageURL{URL: imageURL(image)}})
+			}
+			item.Content = parts
+		} else if message.Content != "" {
+			item.Content = message.Content
+		}
+		calls, err := message.ToolCalls()
+		if err != nil {
+			return nil, fmt.Errorf("render message %s failed, %w", message.MessageID, err)
+		}
+		for _, call := range calls {
+			item.ToolCalls = append(item.ToolCalls, OpenAIToolCall{
+				ID:   call.ID,
+				Type: "function",
+				Function: OpenAIFunctionCall{
+					Name:      call.Name,
+					Arguments: call.Arguments,
+				},
+			})
+		}
+		ret = append(ret, item)
+	}
+	return ret, nil
+}
+
+type openAIChatResponse struct {
+	ID      string `json:"id"`
+	Choices []struct {
+		Message struct {
+			Content   json.RawMessage  `json:"content"`
+			ToolCalls []OpenAIToolCall `json:"tool_calls"`
+		} `json:"message"`
+	} `json:"choices"`
+}
+
+// ParseOpenAIChat parses the first choice of a Chat Completions response into an assistant message
+func ParseOpenAIChat(sessionID string, data []byte) (*model.Message, error) {
+	var resp openAIChatResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("decode openai chat response failed, %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return nil, errors.New("openai chat response has no choices")
+	}
+	choice := resp.Choices[0].Message
+	content, err := openAIChatContent(choice.Content)
+	if err != nil {
+		return nil, err
+	}
+	calls := make([]model.ToolCall, 0, len(choice.ToolCalls))
+	for _, v := range choice.ToolCalls {
+		calls = append(calls, model.ToolCall{
+			ID:        v.ID,
+			Name:      v.Function.Name,
+			Arguments: v.Function.Arguments,
+		})
+	}
+	return newAssistantMessage(sessionID, resp.ID, content, calls)
+}
+
+// openAIChatContent decodes content which is either a string, an array of text parts or null
+func openAIChatContent(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text, nil
+	}
+	var parts []OpenAIChatContentPart
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return "", fmt.Errorf("decode openai chat content failed, %w", err)
+	}
+	for _, part := range parts {
+		text += part.Text
+	}
+	return text, nil
+}
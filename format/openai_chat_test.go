@@ -0,0 +1,52 @@
+package format
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+func TestToOpenAIChat(t *testing.T) {
+	messages, err := ToOpenAIChat(conversation(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertJSON(t, messages, `[`+
+		`{"role":"system","content":"be brief"},`+
+		`{"role":"user","content":[{"type":"text","text":"weather here?"},{"type":"image_url","image_url":{"url":"data:image/png;base64,cG5n"}}]},`+
+		`{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]},`+
+		`{"role":"tool","content":"sunny","tool_call_id":"call_1"},`+
+		`{"role":"assistant","content":"It is sunny."}]`)
+}
+
+func TestParseOpenAIChat(t *testing.T) {
+	data := []byte(`{"id":"chatcmpl-1","choices":[{"message":{"role":"assistant","content":null,` +
+		`"tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{}"}}]}}]}`)
+	message, err := ParseOpenAIChat("session_1", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.SessionID != "session_1" || message.MessageID != "chatcmpl-1" || message.Role() != model.RoleAssistant {
+		t.Errorf("unexpected message: %+v", message)
+	}
+	calls, err := message.ToolCalls()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []model.ToolCall{{ID: "call_1", Name: "get_weather", Arguments: "{}"}}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("tool calls not equal:\n%+v\n%+v", calls, expected)
+	}
+
+	message, err = ParseOpenAIChat("session_1", []byte(`{"id":"chatcmpl-2","choices":[{"message":{"role":"assistant","content":"hello"}}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.Content != "hello" {
+		t.Errorf("expect content:hello, got:%s", message.Content)
+	}
+	if _, err := ParseOpenAIChat("session_1", []byte(`{"id":"chatcmpl-3","choices":[]}`)); err == nil {
+		t.Error("expected error for empty choices, but got nil")
+	}
+}
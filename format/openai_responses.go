@@ -0,0 +1,117 @@
+package format
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+// OpenAIResponsesItem input or output item of the OpenAI Responses API
+type OpenAIResponsesItem struct {
+	Type      string                   `json:"type"`
+	ID        string                   `json:"id,omitempty"`
+	Role      string                   `json:"role,omitempty"`
+	Content   []OpenAIResponsesContent `json:"content,omitempty"`
+	CallID    string                   `json:"call_id,omitempty"`
+	Name      string                   `json:"name,omitempty"`
+	Arguments string                   `json:"arguments,omitempty"`
+	Output    string                   `json:"output,omitempty"`
+}
+
+// OpenAIResponsesContent content part of a Responses message item
+type OpenAIResponsesContent struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageURL string `json:"image_url,omitempty"`
+}
+
+// ToOpenAIResponses renders messages as the input array of a Responses request.
+// Tool calls become function_call items and tool messages become function_call_output items.
+func ToOpenAIResponses(messages []model.Message) ([]OpenAIResponsesItem, error) {
+	ret := make([]OpenAIResponsesItem, 0, len(messages))
+	for idx := range messages {
+		message := &messages[idx]
+		role := message.Role()
+		if role == model.RoleTool {
+			ret = append(ret, OpenAIResponsesItem{
+				Type:   "function_call_output",
+				CallID: message.ToolCallID(),
+				Output: message.Content,
+			})
+			continue
+		}
+		if role == "" {
+			role = model.RoleUser
+		}
+		textType := "input_text"
+		if role == model.RoleAssistant {
+			textType = "output_text"
+		}
+		item := OpenAIResponsesItem{
+			Type: "message",
+			Role: string(role),
+		}
+		if message.Content != "" {
+			item.Content = append(item.Content, OpenAIResponsesContent{Type: textType, Text: message.Content})
+		}
+		images, err := message.Images()
+		if err != nil {
+			return nil, fmt.Errorf("render message %s failed, %w", message.MessageID, err)
+		}
+		for _, image := range images {
+			item.Content = append(item.Content, OpenAIResponsesContent{Type: "input_image", ImageURL: imageURL(image)})
+		}
+		if len(item.Content) > 0 {
+			ret = append(ret, item)
+		}
+		calls, err := message.ToolCalls()
+		if err != nil {
+			return nil, fmt.Errorf("render message %s failed, %w", message.MessageID, err)
+		}
+		for _, call := range calls {
+			ret = append(ret, OpenAIResponsesItem{
+				Type:      "function_call",
+				CallID:    call.ID,
+				Name:      call.Name,
+				Arguments: call.Arguments,
+			})
+		}
+	}
+	return ret, nil
+}
+
+type openAIResponsesResponse struct {
+	ID     string                `json:"id"`
+	Output []OpenAIResponsesItem `json:"output"`
+}
+
+// ParseOpenAIResponses parses the output of a Responses response into one assistant message,
+// text of message items is concatenated and function_call items become tool calls
+func ParseOpenAIResponses(sessionID string, data []byte) (*model.Message, error) {
+	var resp openAIResponsesResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("decode openai responses response failed, %w", err)
+	}
+	var (
+		content string
+		calls   []model.ToolCall
+	)
+	for _, item := range resp.Output {
+		switch item.Type {
+		case "message":
+			for _, part := range item.Content {
+				if part.Type == "output_text" {
+					content += part.Text
+				}
+			}
+		case "function_call":
+			calls = append(calls, model.ToolCall{
+				ID:        item.CallID,
+				Name:      item.Name,
+				Arguments: item.Arguments,
+			})
+		}
+	}
+	return newAssistantMessage(sessionID, resp.ID, content, calls)
+}
@@ -0,0 +1,43 @@
+package format
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+func TestToOpenAIResponses(t *testing.T) {
+	items, err := ToOpenAIResponses(conversation(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertJSON(t, items, `[`+
+		`{"type":"message","role":"system","content":[{"type":"input_text","text":"be brief"}]},`+
+		`{"type":"message","role":"user","content":[{"type":"input_text","text":"weather here?"},{"type":"input_image","image_url":"data:image/png;base64,cG5n"}]},`+
+		`{"type":"function_call","call_id":"call_1","name":"get_weather","arguments":"{\"city\":\"Paris\"}"},`+
+		`{"type":"function_call_output","call_id":"call_1","output":"sunny"},`+
+		`{"type":"message","role":"assistant","content":[{"type":"output_text","text":"It is sunny."}]}]`)
+}
+
+func TestParseOpenAIResponses(t *testing.T) {
+	data := []byte(`{"id":"resp_1","output":[` +
+		`{"type":"reasoning","id":"rs_1"},` +
+		`{"type":"message","id":"msg_1","role":"assistant","content":[{"type":"output_text","text":"checking"}]},` +
+		`{"type":"function_call","id":"fc_1","call_id":"call_1","name":"get_weather","arguments":"{}"}]}`)
+	message, err := ParseOpenAIResponses("session_1", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.MessageID != "resp_1" || message.Content != "checking" || message.Role() != model.RoleAssistant {
+		t.Errorf("unexpected message: %+v", message)
+	}
+	calls, err := message.ToolCalls()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []model.ToolCall{{ID: "call_1", Name: "get_weather", Arguments: "{}"}}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("tool calls not equal:\n%+v\n%+v", calls, expected)
+	}
+}
@@ -0,0 +1,72 @@
+package format
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+// imageURL returns the image URL, inline data is encoded as a data URL
+func imageURL(image model.Image) string {
+	if len(image.Data) == 0 {
+		return image.URL
+	}
+	return "data:" + imageMediaType(image) + ";base64," + base64Encode(image.Data)
+}
+
+// imageMediaType returns the image media type, sniffed from inline data when unset, image/png when unknown
+func imageMediaType(image model.Image) string {
+	if image.MediaType != "" {
+		return image.MediaType
+	}
+	if mediaType := http.DetectContentType(image.Data); strings.HasPrefix(mediaType, "image/") {
+		return mediaType
+	}
+	return "image/png"
+}
+
+func base64Encode(data []byte) string {
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// parseDataURL splits a base64 data URL into media type and data
+func parseDataURL(url string) (string, []byte, bool) {
+	rest, ok := strings.CutPrefix(url, "data:")
+	if !ok {
+		return "", nil, false
+	}
+	meta, payload, ok := strings.Cut(rest, ",")
+	if !ok {
+		return "", nil, false
+	}
+	mediaType, ok := strings.CutSuffix(meta, ";base64")
+	if !ok {
+		return "", nil, false
+	}
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", nil, false
+	}
+	return mediaType, data, true
+}
+
+// rawArguments converts JSON encoded arguments to a raw JSON object
+func rawArguments(arguments string) json.RawMessage {
+	if strings.TrimSpace(arguments) == "" || !json.Valid([]byte(arguments)) {
+		return json.RawMessage("{}")
+	}
+	return json.RawMessage(arguments)
+}
+
+func newAssistantMessage(sessionID string, messageID string, content string, calls []model.ToolCall) (*model.Message, error) {
+	message := model.NewMessage(sessionID, messageID)
+	message.SetContent(content)
+	message.SetRole(model.RoleAssistant)
+	if err := message.SetToolCalls(calls); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
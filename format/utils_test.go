@@ -0,0 +1,47 @@
+package format
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+// conversation system prompt, user question with image, tool round trip and final answer
+func conversation(t *testing.T) []model.Message {
+	t.Helper()
+	system := model.NewMessageWithTime("session_1", "m1", 1).SetContent("be brief").SetRole(model.RoleSystem)
+	user := model.NewMessageWithTime("session_1", "m2", 2).SetContent("weather here?").SetRole(model.RoleUser)
+	if err := user.SetImages([]model.Image{{MediaType: "image/png", Data: []byte("png")}}); err != nil {
+		t.Fatal(err)
+	}
+	call := model.NewMessageWithTime("session_1", "m3", 3).SetRole(model.RoleAssistant)
+	if err := call.SetToolCalls([]model.ToolCall{{ID: "call_1", Name: "get_weather", Arguments: `{"city":"Paris"}`}}); err != nil {
+		t.Fatal(err)
+	}
+	result := model.NewMessageWithTime("session_1", "m4", 4).SetContent("sunny").SetRole(model.RoleTool).SetToolCallID("call_1")
+	answer := model.NewMessageWithTime("session_1", "m5", 5).SetContent("It is sunny.").SetRole(model.RoleAssistant)
+	return []model.Message{*system, *user, *call, *result, *answer}
+}
+
+func assertJSON(t *testing.T, v any, expected string) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != expected {
+		t.Fatalf("json not equal:\n%s\n%s", data, expected)
+	}
+}
+
+func TestParseDataURL(t *testing.T) {
+	url := imageURL(model.Image{MediaType: "image/jpeg", Data: []byte("jpeg")})
+	mediaType, data, ok := parseDataURL(url)
+	if !ok || mediaType != "image/jpeg" || string(data) != "jpeg" {
+		t.Errorf("unexpected data url parse result: %s, %s, %v", mediaType, data, ok)
+	}
+	if _, _, ok := parseDataURL("https://example.com/a.png"); ok {
+		t.Error("expect plain url not parsed as data url")
+	}
+}
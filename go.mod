@@ -5,12 +5,12 @@ go 1.25.4
 require (
 	github.com/aliyun/aliyun-tablestore-go-sdk v1.8.0
 	github.com/go-faker/faker/v4 v4.7.0
+	github.com/golang/protobuf v1.3.2
 	github.com/google/uuid v1.6.0
 	github.com/spf13/cast v1.10.0
 )
 
 require (
-	github.com/golang/protobuf v1.3.2 // indirect
 	github.com/google/flatbuffers v23.5.26+incompatible // indirect
 	github.com/hashicorp/golang-lru v0.5.4 // indirect
 	golang.org/x/text v0.29.0 // indirect
@@ -0,0 +1,189 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// AggregationType kind of a search index aggregation
+type AggregationType string
+
+const (
+	AggregationCount         AggregationType = "count"
+	AggregationDistinctCount AggregationType = "distinct_count"
+	AggregationMin           AggregationType = "min"
+	AggregationMax           AggregationType = "max"
+	AggregationAvg           AggregationType = "avg"
+	AggregationSum           AggregationType = "sum"
+	// AggregationTerms groups rows by the values of a field
+	AggregationTerms AggregationType = "terms"
+	// AggregationHistogram groups rows of a numeric field into fixed width buckets
+	AggregationHistogram AggregationType = "histogram"
+)
+
+// Histogram intervals of microsecond time fields such as create_time and update_time, buckets are aligned to UTC
+var (
+	HistogramHour = time.Hour.Microseconds()
+	HistogramDay  = 24 * HistogramHour
+	HistogramWeek = 7 * HistogramDay
+)
+
+// DefaultTermsSize max groups of a terms aggregation
+const DefaultTermsSize = 10
+
+// Aggregation an aggregation over an indexed field. Terms and histogram aggregations group rows into
+// buckets and compute SubAggregations per bucket.
+type Aggregation struct {
+	Name  string          `json:"name"`
+	Type  AggregationType `json:"type"`
+	Field string          `json:"field"`
+	// Size max buckets of a terms aggregation, defaults to DefaultTermsSize
+	Size int32 `json:"size,omitempty"`
+	// Interval bucket width of a histogram aggregation, microseconds for time fields
+	Interval int64 `json:"interval,omitempty"`
+	// From and To value range of a histogram aggregation, To is exclusive
+	From int64 `json:"from,omitempty"`
+	To   int64 `json:"to,omitempty"`
+	// MinCount drops histogram buckets with fewer rows
+	MinCount        int64         `json:"min_count,omitempty"`
+	SubAggregations []Aggregation `json:"sub_aggregations,omitempty"`
+}
+
+func CountAggregation(name string, field string) Aggregation {
+	return Aggregation{Name: name, Type: AggregationCount, Field: field}
+}
+
+func DistinctCountAggregation(name string, field string) Aggregation {
+	return Aggregation{Name: name, Type: AggregationDistinctCount, Field: field}
+}
+
+func MinAggregation(name string, field string) Aggregation {
+	return Aggregation{Name: name, Type: AggregationMin, Field: field}
+}
+
+func MaxAggregation(name string, field string) Aggregation {
+	return Aggregation{Name: name, Type: AggregationMax, Field: field}
+}
+
+func AvgAggregation(name string, field string) Aggregation {
+	return Aggregation{Name: name, Type: AggregationAvg, Field: field}
+}
+
+func SumAggregation(name string, field string) Aggregation {
+	return Aggregation{Name: name, Type: AggregationSum, Field: field}
+}
+
+// TermsAggregation groups by the values of field, size 0 uses DefaultTermsSize
+func TermsAggregation(name string, field string, size int32, subAggregations ...Aggregation) Aggregation {
+	return Aggregation{Name: name, Type: AggregationTerms, Field: field, Size: size, SubAggregations: subAggregations}
+}
+
+// HistogramAggregation groups values of field in [from, to) into buckets of interval width
+func HistogramAggregation(name string, field string, interval int64, from int64, to int64, subAggregations ...Aggregation) Aggregation {
+	return Aggregation{Name: name, Type: AggregationHistogram, Field: field, Interval: interval, From: from, To: to, SubAggregations: subAggregations}
+}
+
+// IsGroup reports whether the aggregation groups rows into buckets
+func (a Aggregation) IsGroup() bool {
+	return a.Type == AggregationTerms || a.Type == AggregationHistogram
+}
+
+func (a Aggregation) Validate() error {
+	if a.Name == "" {
+		return errors.New("aggregation name is required")
+	}
+	if a.Field == "" {
+		return fmt.Errorf("aggregation %s field is required", a.Name)
+	}
+	switch a.Type {
+	case AggregationCount, AggregationDistinctCount, AggregationMin, AggregationMax, AggregationAvg, AggregationSum:
+		if len(a.SubAggregations) > 0 {
+			return fmt.Errorf("aggregation %s of type %s does not support sub aggregations", a.Name, a.Type)
+		}
+		return nil
+	case AggregationTerms:
+		if a.Size < 0 {
+			return fmt.Errorf("aggregation %s size must not be negative", a.Name)
+		}
+	case AggregationHistogram:
+		if a.Interval <= 0 {
+			return fmt.Errorf("aggregation %s interval must be positive", a.Name)
+		}
+		if a.From >= a.To {
+			return fmt.Errorf("aggregation %s requires from < to", a.Name)
+		}
+	default:
+		return fmt.Errorf("aggregation %s has unsupported type %q", a.Name, a.Type)
+	}
+	return validateAggregations(a.SubAggregations)
+}
+
+func validateAggregations(aggregations []Aggregation) error {
+	names := make(map[string]struct{}, len(aggregations))
+	for _, v := range aggregations {
+		if err := v.Validate(); err != nil {
+			return err
+		}
+		if _, ok := names[v.Name]; ok {
+			return fmt.Errorf("duplicate aggregation name: %s", v.Name)
+		}
+		names[v.Name] = struct{}{}
+	}
+	return nil
+}
+
+// AggregationRequest aggregations over the rows matching the scope of AggregateSessions or AggregateMessages
+type AggregationRequest struct {
+	// UserID restricts sessions to a user, or messages to the sessions of a user
+	UserID string `json:"user_id,omitempty"`
+	// SessionID restricts messages to a session, ignored by AggregateSessions
+	SessionID string `json:"session_id,omitempty"`
+	// StartTime and EndTime inclusive update_time range of sessions or create_time range of messages, 0 means unbounded
+	StartTime    int64            `json:"start_time,omitempty"`
+	EndTime      int64            `json:"end_time,omitempty"`
+	Filters      []MetadataFilter `json:"filters,omitempty"`
+	Aggregations []Aggregation    `json:"aggregations"`
+}
+
+func (r *AggregationRequest) Validate() error {
+	if len(r.Aggregations) == 0 {
+		return errors.New("at least one aggregation is required")
+	}
+	for _, v := range r.Filters {
+		if err := v.Validate(); err != nil {
+			return err
+		}
+	}
+	return validateAggregations(r.Aggregations)
+}
+
+// AggregationResult value of a metric aggregation or buckets of a group aggregation
+type AggregationResult struct {
+	Name string          `json:"name"`
+	Type AggregationType `json:"type"`
+	// Count result of count and distinct count aggregations
+	Count int64 `json:"count,omitempty"`
+	// Value result of min, max, avg and sum aggregations, valid when HasValue
+	Value    float64 `json:"value,omitempty"`
+	HasValue bool    `json:"has_value,omitempty"`
+	// Buckets groups of terms and histogram aggregations
+	Buckets []AggregationBucket `json:"buckets,omitempty"`
+}
+
+// AggregationBucket a group of rows
+type AggregationBucket struct {
+	// Key field value of a terms bucket
+	Key string `json:"key,omitempty"`
+	// NumericKey lower bound of a histogram bucket
+	NumericKey      int64                        `json:"numeric_key,omitempty"`
+	Count           int64                        `json:"count"`
+	SubAggregations map[string]AggregationResult `json:"sub_aggregations,omitempty"`
+}
+
+// AggregationResponse results of an aggregation request by aggregation name
+type AggregationResponse struct {
+	// Total rows matching the request scope
+	Total   int64                        `json:"total"`
+	Results map[string]AggregationResult `json:"results"`
+}
@@ -0,0 +1,56 @@
+package model
+
+import "testing"
+
+func TestAggregationValidate(t *testing.T) {
+	valid := []Aggregation{
+		CountAggregation("count", "session_id"),
+		TermsAggregation("roles", "role", 0, AvgAggregation("avg_time", "create_time")),
+		HistogramAggregation("per_day", "create_time", HistogramDay, 0, 7*HistogramDay,
+			TermsAggregation("roles", "role", 5),
+		),
+	}
+	for _, v := range valid {
+		if err := v.Validate(); err != nil {
+			t.Errorf("expect %s valid, got:%v", v.Name, err)
+		}
+	}
+	invalid := []Aggregation{
+		{Type: AggregationCount, Field: "session_id"},
+		{Name: "no_field", Type: AggregationCount},
+		{Name: "unknown", Type: "median", Field: "create_time"},
+		{Name: "metric_with_sub", Type: AggregationSum, Field: "create_time", SubAggregations: []Aggregation{CountAggregation("c", "role")}},
+		HistogramAggregation("zero_interval", "create_time", 0, 0, 10),
+		HistogramAggregation("empty_range", "create_time", 1, 10, 10),
+		TermsAggregation("bad_sub", "role", 1, Aggregation{Name: "x"}),
+		TermsAggregation("dup_sub", "role", 1, CountAggregation("c", "role"), MaxAggregation("c", "create_time")),
+	}
+	for _, v := range invalid {
+		if err := v.Validate(); err == nil {
+			t.Errorf("expect %q invalid", v.Name)
+		}
+	}
+}
+
+func TestAggregationRequestValidate(t *testing.T) {
+	var req AggregationRequest
+	if err := req.Validate(); err == nil {
+		t.Error("expect aggregations required")
+	}
+	req.Aggregations = []Aggregation{CountAggregation("c", "role"), CountAggregation("c", "session_id")}
+	if err := req.Validate(); err == nil {
+		t.Error("expect duplicate names invalid")
+	}
+	req.Aggregations = req.Aggregations[:1]
+	req.Filters = []MetadataFilter{{Field: "role"}}
+	if err := req.Validate(); err == nil {
+		t.Error("expect invalid filter rejected")
+	}
+	req.Filters = []MetadataFilter{MetadataEquals("role", "user")}
+	if err := req.Validate(); err != nil {
+		t.Error(err)
+	}
+	if !TermsAggregation("t", "role", 0).IsGroup() || CountAggregation("c", "role").IsGroup() {
+		t.Error("unexpected group detection")
+	}
+}
@@ -0,0 +1,95 @@
+package model
+
+import "fmt"
+
+// --------------------
+// Conversation branches
+// --------------------
+
+// NewReply creates a message following parent on the same branch
+func NewReply(parent *Message, messageID string) *Message {
+	return NewMessage(parent.SessionID, messageID).
+		SetParentMessageID(parent.MessageID).
+		SetBranchID(parent.BranchID)
+}
+
+// NewAlternative creates a sibling of message, a regeneration or an edit, that starts branch branchID
+func NewAlternative(message *Message, messageID string, branchID string) *Message {
+	return NewMessage(message.SessionID, messageID).
+		SetParentMessageID(message.ParentMessageID).
+		SetBranchID(branchID)
+}
+
+// MessageTree messages of a session linked by their parent pointers
+type MessageTree struct {
+	// messages in create time order
+	messages []Message
+	index    map[string]int
+}
+
+// NewMessageTree builds the tree of messages, which must be in create time order
+func NewMessageTree(messages []Message) *MessageTree {
+	tree := &MessageTree{
+		messages: messages,
+		index:    make(map[string]int, len(messages)),
+	}
+	for i, message := range messages {
+		tree.index[message.MessageID] = i
+	}
+	return tree
+}
+
+// Get returns the message with the id, nil if not in the tree
+func (t *MessageTree) Get(messageID string) *Message {
+	if i, ok := t.index[messageID]; ok {
+		return &t.messages[i]
+	}
+	return nil
+}
+
+// Leaf returns the latest message of a branch, nil if the branch has no message
+func (t *MessageTree) Leaf(branchID string) *Message {
+	for i := len(t.messages) - 1; i >= 0; i-- {
+		if t.messages[i].BranchID == branchID {
+			return &t.messages[i]
+		}
+	}
+	return nil
+}
+
+// Path returns the messages from the root down to the leaf
+func (t *MessageTree) Path(leafMessageID string) ([]Message, error) {
+	var ret []Message
+	visited := make(map[string]struct{})
+	for messageID := leafMessageID; messageID != ""; {
+		if _, ok := visited[messageID]; ok {
+			return nil, fmt.Errorf("message %s has a parent cycle", messageID)
+		}
+		visited[messageID] = struct{}{}
+		message := t.Get(messageID)
+		if message == nil {
+			return nil, fmt.Errorf("message %s not exists", messageID)
+		}
+		ret = append(ret, *message)
+		messageID = message.ParentMessageID
+	}
+	for i, j := 0, len(ret)-1; i < j; i, j = i+1, j-1 {
+		ret[i], ret[j] = ret[j], ret[i]
+	}
+	return ret, nil
+}
+
+// Siblings returns the alternatives of a message sharing its parent, itself included, in create time order
+func (t *MessageTree) Siblings(messageID string) ([]Message, error) {
+	message := t.Get(messageID)
+	if message == nil {
+		return nil, fmt.Errorf("message %s not exists", messageID)
+	}
+	var ret []Message
+	for _, v := range t.messages {
+		if v.ParentMessageID == message.ParentMessageID {
+			ret = append(ret, v)
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,59 @@
+package model
+
+import "testing"
+
+func messageIDs(messages []Message) []string {
+	ret := make([]string, 0, len(messages))
+	for _, message := range messages {
+		ret = append(ret, message.MessageID)
+	}
+	return ret
+}
+
+func TestMessageTree(t *testing.T) {
+	question := NewMessage("session_1", "q1")
+	answer := NewReply(question, "a1")
+	regenerated := NewAlternative(answer, "a2", "branch_1")
+	followUp := NewReply(regenerated, "q2")
+	if regenerated.ParentMessageID != "q1" || followUp.ParentMessageID != "a2" || followUp.BranchID != "branch_1" {
+		t.Fatalf("unexpected links: %+v, %+v", regenerated, followUp)
+	}
+	tree := NewMessageTree([]Message{*question, *answer, *regenerated, *followUp})
+
+	if leaf := tree.Leaf(""); leaf == nil || leaf.MessageID != "a1" {
+		t.Errorf("expect main branch leaf a1, got:%+v", leaf)
+	}
+	if leaf := tree.Leaf("branch_1"); leaf == nil || leaf.MessageID != "q2" {
+		t.Errorf("expect branch_1 leaf q2, got:%+v", leaf)
+	}
+	if leaf := tree.Leaf("branch_2"); leaf != nil {
+		t.Errorf("expect no leaf for unknown branch, got:%+v", leaf)
+	}
+
+	path, err := tree.Path("q2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := messageIDs(path); len(ids) != 3 || ids[0] != "q1" || ids[1] != "a2" || ids[2] != "q2" {
+		t.Errorf("unexpected path: %v", ids)
+	}
+	if _, err := tree.Path("missing"); err == nil {
+		t.Error("expect error for missing leaf")
+	}
+
+	siblings, err := tree.Siblings("a2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := messageIDs(siblings); len(ids) != 2 || ids[0] != "a1" || ids[1] != "a2" {
+		t.Errorf("unexpected siblings: %v", ids)
+	}
+}
+
+func TestMessageTree_PathCycle(t *testing.T) {
+	a := NewMessage("session_1", "a").SetParentMessageID("b")
+	b := NewMessage("session_1", "b").SetParentMessageID("a")
+	if _, err := NewMessageTree([]Message{*a, *b}).Path("a"); err == nil {
+		t.Error("expect error for parent cycle")
+	}
+}
@@ -0,0 +1,174 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cast"
+)
+
+// Role author of a chat message
+type Role string
+
+const (
+	RoleSystem    Role = "system"
+	RoleDeveloper Role = "developer"
+	RoleUser      Role = "user"
+	RoleAssistant Role = "assistant"
+	RoleTool      Role = "tool"
+)
+
+// metadata keys used to store chat attributes of a message
+const (
+	MetadataRoleKey       = "role"
+	MetadataNameKey       = "name"
+	MetadataToolCallsKey  = "tool_calls"
+	MetadataToolCallIDKey = "tool_call_id"
+	MetadataImagesKey     = "images"
+	MetadataPinnedKey     = "pinned"
+)
+
+// ToolCall a tool invocation requested by the assistant
+type ToolCall struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+	// Arguments JSON encoded arguments
+	Arguments string `json:"arguments,omitempty"`
+}
+
+// Image an image attached to a message, either by URL or inline data
+type Image struct {
+	URL       string `json:"url,omitempty"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      []byte `json:"data,omitempty"`
+}
+
+// --------------------
+// chat accessors
+// --------------------
+
+func (m *Message) Role() Role {
+	if v := m.Metadata.GetString(MetadataRoleKey); v != nil {
+		return Role(*v)
+	}
+	return ""
+}
+
+func (m *Message) SetRole(role Role) *Message {
+	m.ensureMetadata()
+	if role == "" {
+		m.Metadata.Remove(MetadataRoleKey)
+	} else {
+		m.Metadata[MetadataRoleKey] = string(role)
+	}
+	return m
+}
+
+// Name optional participant or tool name
+func (m *Message) Name() string {
+	if v := m.Metadata.GetString(MetadataNameKey); v != nil {
+		return *v
+	}
+	return ""
+}
+
+func (m *Message) SetName(name string) *Message {
+	m.ensureMetadata()
+	if name == "" {
+		m.Metadata.Remove(MetadataNameKey)
+	} else {
+		m.Metadata[MetadataNameKey] = name
+	}
+	return m
+}
+
+// ToolCallID id of the tool call a tool message answers
+func (m *Message) ToolCallID() string {
+	if v := m.Metadata.GetString(MetadataToolCallIDKey); v != nil {
+		return *v
+	}
+	return ""
+}
+
+func (m *Message) SetToolCallID(id string) *Message {
+	m.ensureMetadata()
+	if id == "" {
+		m.Metadata.Remove(MetadataToolCallIDKey)
+	} else {
+		m.Metadata[MetadataToolCallIDKey] = id
+	}
+	return m
+}
+
+// Pinned pinned messages are always kept when building a context window
+func (m *Message) Pinned() bool {
+	if v := m.Metadata.GetBool(MetadataPinnedKey); v != nil {
+		return *v
+	}
+	return false
+}
+
+func (m *Message) SetPinned(pinned bool) *Message {
+	m.ensureMetadata()
+	if pinned {
+		m.Metadata[MetadataPinnedKey] = true
+	} else {
+		m.Metadata.Remove(MetadataPinnedKey)
+	}
+	return m
+}
+
+func (m *Message) ToolCalls() ([]ToolCall, error) {
+	var calls []ToolCall
+	if err := m.getJSON(MetadataToolCallsKey, &calls); err != nil {
+		return nil, err
+	}
+	return calls, nil
+}
+
+func (m *Message) SetToolCalls(calls []ToolCall) error {
+	return m.setJSON(MetadataToolCallsKey, calls, len(calls) == 0)
+}
+
+func (m *Message) Images() ([]Image, error) {
+	var images []Image
+	if err := m.getJSON(MetadataImagesKey, &images); err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+func (m *Message) SetImages(images []Image) error {
+	return m.setJSON(MetadataImagesKey, images, len(images) == 0)
+}
+
+func (m *Message) ensureMetadata() {
+	if m.Metadata == nil {
+		m.Metadata = NewMetadata()
+	}
+}
+
+func (m *Message) getJSON(key string, v any) error {
+	raw, ok := m.Metadata.Get(key)
+	if !ok {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(cast.ToString(raw)), v); err != nil {
+		return fmt.Errorf("decode metadata '%s' failed, %w", key, err)
+	}
+	return nil
+}
+
+func (m *Message) setJSON(key string, v any, empty bool) error {
+	m.ensureMetadata()
+	if empty {
+		m.Metadata.Remove(key)
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encode metadata '%s' failed, %w", key, err)
+	}
+	m.Metadata[key] = string(data)
+	return nil
+}
@@ -0,0 +1,51 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMessage_Chat(t *testing.T) {
+	message := NewMessage("session_1", "message_1")
+	message.SetRole(RoleAssistant).SetName("bot").SetToolCallID("call_1")
+	if message.Role() != RoleAssistant {
+		t.Errorf("expect role:%s, got:%s", RoleAssistant, message.Role())
+	}
+	if message.Name() != "bot" {
+		t.Errorf("expect name:bot, got:%s", message.Name())
+	}
+	if message.ToolCallID() != "call_1" {
+		t.Errorf("expect tool call id:call_1, got:%s", message.ToolCallID())
+	}
+
+	calls := []ToolCall{{ID: "call_1", Name: "get_weather", Arguments: `{"city":"Paris"}`}}
+	if err := message.SetToolCalls(calls); err != nil {
+		t.Fatal(err)
+	}
+	images := []Image{{URL: "https://example.com/a.png"}, {MediaType: "image/png", Data: []byte{1, 2, 3}}}
+	if err := message.SetImages(images); err != nil {
+		t.Fatal(err)
+	}
+	gotCalls, err := message.ToolCalls()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotCalls, calls) {
+		t.Fatalf("tool calls not equal:\n%+v\n%+v", gotCalls, calls)
+	}
+	gotImages, err := message.Images()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotImages, images) {
+		t.Fatalf("images not equal:\n%+v\n%+v", gotImages, images)
+	}
+
+	message.SetRole("")
+	if err := message.SetToolCalls(nil); err != nil {
+		t.Fatal(err)
+	}
+	if message.Metadata.HasKey(MetadataRoleKey) || message.Metadata.HasKey(MetadataToolCallsKey) {
+		t.Errorf("expect role and tool calls removed, got:%v", message.Metadata)
+	}
+}
@@ -0,0 +1,60 @@
+package model
+
+// metadata keys recording where a cloned session or message was copied from
+const (
+	// MetadataClonedFromUserKey user id of the source session
+	MetadataClonedFromUserKey = "cloned_from_user"
+	// MetadataClonedFromSessionKey session id of the source session
+	MetadataClonedFromSessionKey = "cloned_from_session"
+	// MetadataClonedFromMessageKey message id of the source message, set on messages only
+	MetadataClonedFromMessageKey = "cloned_from_message"
+	// MetadataClonedAtKey time the copy was made in microseconds
+	MetadataClonedAtKey = "cloned_at"
+)
+
+// CloneOptions controls what CloneSession copies
+type CloneOptions struct {
+	// UntilMessageID inclusive id of the last message to copy, empty copies all messages
+	UntilMessageID string `json:"until_message_id,omitempty"`
+	// RewriteMessageIDs gives the copied messages new ids, parent pointers are rewritten to match
+	RewriteMessageIDs bool `json:"rewrite_message_ids,omitempty"`
+	// Provenance records the source of the session and of every message in their metadata
+	Provenance bool `json:"provenance,omitempty"`
+	// RowsPerSecond max messages written per second, 0 means unlimited
+	RowsPerSecond int `json:"rows_per_second,omitempty"`
+	// BatchSize rows per batch write request, capped at 200
+	BatchSize int `json:"batch_size,omitempty"`
+}
+
+// CloneReport result of a session clone
+type CloneReport struct {
+	// Session the copy
+	Session *Session `json:"session,omitempty"`
+	// Messages number of messages copied
+	Messages int `json:"messages,omitempty"`
+	// MessageIDs source message id to copy message id, only when ids are rewritten
+	MessageIDs map[string]string `json:"message_ids,omitempty"`
+	StartTime  int64             `json:"start_time,omitempty"`
+	EndTime    int64             `json:"end_time,omitempty"`
+}
+
+// SetProvenance records in the metadata that the session was copied from a source session at clonedAt
+func (s *Session) SetProvenance(userID string, sessionID string, clonedAt int64) *Session {
+	if s.Metadata == nil {
+		s.Metadata = NewMetadata()
+	}
+	s.Metadata[MetadataClonedFromUserKey] = userID
+	s.Metadata[MetadataClonedFromSessionKey] = sessionID
+	s.Metadata[MetadataClonedAtKey] = clonedAt
+	return s
+}
+
+// SetProvenance records in the metadata that the message was copied from a source message at clonedAt
+func (m *Message) SetProvenance(userID string, sessionID string, messageID string, clonedAt int64) *Message {
+	m.ensureMetadata()
+	m.Metadata[MetadataClonedFromUserKey] = userID
+	m.Metadata[MetadataClonedFromSessionKey] = sessionID
+	m.Metadata[MetadataClonedFromMessageKey] = messageID
+	m.Metadata[MetadataClonedAtKey] = clonedAt
+	return m
+}
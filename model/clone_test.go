@@ -0,0 +1,20 @@
+package model
+
+import "testing"
+
+func TestSetProvenance(t *testing.T) {
+	session := (&Session{}).SetProvenance("user_1", "session_1", 123)
+	if v := session.Metadata.GetString(MetadataClonedFromSessionKey); v == nil || *v != "session_1" {
+		t.Errorf("unexpected session provenance: %+v", session.Metadata)
+	}
+	if v := session.Metadata.GetInt64(MetadataClonedAtKey); v == nil || *v != 123 {
+		t.Errorf("unexpected session cloned at: %+v", session.Metadata)
+	}
+	message := (&Message{}).SetProvenance("user_1", "session_1", "message_1", 123)
+	if v := message.Metadata.GetString(MetadataClonedFromMessageKey); v == nil || *v != "message_1" {
+		t.Errorf("unexpected message provenance: %+v", message.Metadata)
+	}
+	if v := message.Metadata.GetString(MetadataClonedFromUserKey); v == nil || *v != "user_1" {
+		t.Errorf("unexpected message provenance: %+v", message.Metadata)
+	}
+}
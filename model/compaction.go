@@ -0,0 +1,140 @@
+package model
+
+import "errors"
+
+// metadata keys used to link summaries and the messages they replace
+const (
+	// MetadataSummaryKey JSON encoded SummaryInfo of a summary message
+	MetadataSummaryKey = "summary"
+	// MetadataCompactedByKey message id of the summary an archived message was compacted into
+	MetadataCompactedByKey = "compacted_by"
+)
+
+// Summarizer condenses a run of messages into the content of a summary message
+type Summarizer interface {
+	Summarize(messages []Message) (string, error)
+}
+
+// SummarizerFunc adapts a function to Summarizer
+type SummarizerFunc func(messages []Message) (string, error)
+
+func (f SummarizerFunc) Summarize(messages []Message) (string, error) {
+	return f(messages)
+}
+
+// SummaryInfo the range of messages a summary message replaces
+type SummaryInfo struct {
+	// StartTime create time of the first covered message
+	StartTime int64 `json:"start_time,omitempty"`
+	// EndTime create time of the last covered message
+	EndTime int64 `json:"end_time,omitempty"`
+	// MessageIDs ids of the covered messages in chronological order
+	MessageIDs []string `json:"message_ids,omitempty"`
+}
+
+// Covers reports whether message is one of the covered messages
+func (s *SummaryInfo) Covers(message *Message) bool {
+	if message.CreateTime < s.StartTime || message.CreateTime > s.EndTime {
+		return false
+	}
+	for _, id := range s.MessageIDs {
+		if id == message.MessageID {
+			return true
+		}
+	}
+	return false
+}
+
+// CompactionMode what happens to the original messages after compaction
+type CompactionMode int
+
+const (
+	// CompactionArchive keep the originals tagged with the summary id, they are skipped by
+	// BuildContext and by listings using the active messages filter
+	CompactionArchive CompactionMode = iota
+	// CompactionDelete delete the originals
+	CompactionDelete
+	// CompactionKeep leave the originals untouched
+	CompactionKeep
+)
+
+func (m CompactionMode) String() string {
+	switch m {
+	case CompactionArchive:
+		return "archive"
+	case CompactionDelete:
+		return "delete"
+	case CompactionKeep:
+		return "keep"
+	}
+	return "unknown"
+}
+
+// CompactionOptions selects the messages to compact
+type CompactionOptions struct {
+	// StartTime inclusive create time of the first message to compact, 0 for the beginning
+	StartTime int64
+	// EndTime inclusive create time of the last message to compact, required
+	EndTime int64
+	// Mode what happens to the originals, defaults to CompactionArchive
+	Mode CompactionMode
+	// SummaryMessageID id of the summary message, generated when empty
+	SummaryMessageID string
+	// SummaryRole role of the summary message, defaults to system
+	SummaryRole Role
+	// BatchSize rows per batch write when archiving or deleting originals
+	BatchSize int
+}
+
+func (o CompactionOptions) Validate() error {
+	if o.EndTime <= 0 {
+		return errors.New("compaction end time is required")
+	}
+	if o.StartTime > o.EndTime {
+		return errors.New("compaction start time is after end time")
+	}
+	return nil
+}
+
+// CompactionReport result of a compaction
+type CompactionReport struct {
+	Summary *Message `json:"summary,omitempty"`
+	Mode    string   `json:"mode,omitempty"`
+	// Compacted number of original messages archived or deleted
+	Compacted int   `json:"compacted,omitempty"`
+	StartTime int64 `json:"start_time,omitempty"`
+	EndTime   int64 `json:"end_time,omitempty"`
+}
+
+// --------------------
+// summary accessors
+// --------------------
+
+// IsSummary reports whether the message is a summary written by compaction
+func (m *Message) IsSummary() bool {
+	return m.Metadata.HasKey(MetadataSummaryKey)
+}
+
+// SummaryInfo the covered range of a summary message, nil when the message is not a summary
+func (m *Message) SummaryInfo() (*SummaryInfo, error) {
+	if !m.IsSummary() {
+		return nil, nil
+	}
+	var ret SummaryInfo
+	if err := m.getJSON(MetadataSummaryKey, &ret); err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+func (m *Message) SetSummaryInfo(info *SummaryInfo) error {
+	return m.setJSON(MetadataSummaryKey, info, info == nil)
+}
+
+// CompactedBy id of the summary this message was archived into
+func (m *Message) CompactedBy() string {
+	if v := m.Metadata.GetString(MetadataCompactedByKey); v != nil {
+		return *v
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMessage_SummaryInfo(t *testing.T) {
+	message := NewMessage("session_1", "summary_1")
+	if message.IsSummary() {
+		t.Error("expect not summary")
+	}
+	if info, err := message.SummaryInfo(); err != nil || info != nil {
+		t.Errorf("expect nil summary info, got:%v, %v", info, err)
+	}
+	info := &SummaryInfo{StartTime: 10, EndTime: 20, MessageIDs: []string{"message_1", "message_2"}}
+	if err := message.SetSummaryInfo(info); err != nil {
+		t.Fatal(err)
+	}
+	if !message.IsSummary() {
+		t.Error("expect summary")
+	}
+	got, err := message.SummaryInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, info) {
+		t.Errorf("expect summary info:%+v, got:%+v", info, got)
+	}
+	if err := message.SetSummaryInfo(nil); err != nil {
+		t.Fatal(err)
+	}
+	if message.IsSummary() {
+		t.Error("expect summary removed")
+	}
+}
+
+func TestSummaryInfo_Covers(t *testing.T) {
+	info := &SummaryInfo{StartTime: 10, EndTime: 20, MessageIDs: []string{"message_1", "message_2"}}
+	tests := []struct {
+		message *Message
+		expect  bool
+	}{
+		{NewMessage("session_1", "message_1").SetCreateTime(10), true},
+		{NewMessage("session_1", "message_2").SetCreateTime(20), true},
+		{NewMessage("session_1", "message_3").SetCreateTime(15), false},
+		{NewMessage("session_1", "message_1").SetCreateTime(21), false},
+	}
+	for _, tt := range tests {
+		if got := info.Covers(tt.message); got != tt.expect {
+			t.Errorf("message:%s@%d, expect covers:%v, got:%v", tt.message.MessageID, tt.message.CreateTime, tt.expect, got)
+		}
+	}
+}
+
+func TestCompactionOptions_Validate(t *testing.T) {
+	if err := (CompactionOptions{}).Validate(); err == nil {
+		t.Error("expect missing end time error")
+	}
+	if err := (CompactionOptions{StartTime: 5, EndTime: 4}).Validate(); err == nil {
+		t.Error("expect invalid range error")
+	}
+	if err := (CompactionOptions{EndTime: 4}).Validate(); err != nil {
+		t.Error(err)
+	}
+	if CompactionArchive.String() != "archive" || CompactionDelete.String() != "delete" || CompactionKeep.String() != "keep" {
+		t.Error("unexpected compaction mode names")
+	}
+}
@@ -0,0 +1,111 @@
+package model
+
+import (
+	"path"
+	"strings"
+	"time"
+)
+
+// Consistency how a search treats rows written by this process that the search index has not synced yet
+type Consistency int
+
+const (
+	// ConsistencyEventual returns what the search index has synced, the default
+	ConsistencyEventual Consistency = iota
+	// ConsistencyMergeWrites merges rows recently written or deleted through this store into the first
+	// page of results, matching the keyword client-side
+	ConsistencyMergeWrites
+	// ConsistencyWaitIndex waits until the search index sync timestamp passes the last write of this store
+	ConsistencyWaitIndex
+)
+
+// DefaultConsistencyTimeout max wait of ConsistencyWaitIndex
+const DefaultConsistencyTimeout = 10 * time.Second
+
+const (
+	// DefaultWriteLogTTL how long written rows stay in the in-process write log
+	DefaultWriteLogTTL = 30 * time.Second
+	// DefaultWriteLogSize max rows kept in the in-process write log
+	DefaultWriteLogSize = 10000
+)
+
+// WithConsistency enables read-your-writes for a search, timeout bounds ConsistencyWaitIndex and
+// defaults to DefaultConsistencyTimeout
+func WithConsistency(consistency Consistency, timeout time.Duration) SearchOption {
+	return func(o *SearchOptions) {
+		o.Consistency = consistency
+		o.ConsistencyTimeout = timeout
+	}
+}
+
+// MatchKeyword reports whether text matches keyword in the query mode of opts. It approximates the
+// search index analyzers with case-insensitive whitespace tokens and is used to merge rows not indexed yet.
+func MatchKeyword(text string, keyword string, opts *SearchOptions) bool {
+	text = strings.ToLower(text)
+	keyword = strings.ToLower(strings.TrimSpace(keyword))
+	if keyword == "" {
+		return true
+	}
+	if opts.Mode != QueryModeString {
+		return matchClause(text, opts.Mode, keyword, opts.Operator)
+	}
+	clauses, err := ParseQueryString(keyword)
+	if err != nil {
+		return false
+	}
+	var (
+		hasMust   bool
+		hasShould bool
+		anyShould bool
+	)
+	for _, clause := range clauses {
+		matched := matchClause(text, clause.Mode, clause.Text, opts.Operator)
+		switch {
+		case clause.Occur == ClauseMustNot:
+			if matched {
+				return false
+			}
+		case clause.Occur == ClauseMust || opts.Operator == MatchOperatorAnd:
+			hasMust = true
+			if !matched {
+				return false
+			}
+		default:
+			hasShould = true
+			anyShould = anyShould || matched
+		}
+	}
+	return !hasShould || anyShould || hasMust
+}
+
+func matchClause(text string, mode QueryMode, keyword string, operator MatchOperator) bool {
+	switch mode {
+	case QueryModeMatch:
+		tokens := strings.Fields(keyword)
+		for _, token := range tokens {
+			contains := strings.Contains(text, token)
+			if operator == MatchOperatorAnd && !contains {
+				return false
+			}
+			if operator != MatchOperatorAnd && contains {
+				return true
+			}
+		}
+		return operator == MatchOperatorAnd
+	case QueryModePrefix:
+		for _, word := range strings.Fields(text) {
+			if strings.HasPrefix(word, keyword) {
+				return true
+			}
+		}
+		return false
+	case QueryModeWildcard:
+		for _, word := range strings.Fields(text) {
+			if ok, _ := path.Match(keyword, word); ok {
+				return true
+			}
+		}
+		return false
+	}
+	return strings.Contains(text, keyword)
+}
@@ -0,0 +1,48 @@
+package model
+
+import "testing"
+
+func TestMatchKeyword(t *testing.T) {
+	text := "Password reset link expired"
+	cases := []struct {
+		keyword string
+		opts    []SearchOption
+		expect  bool
+	}{
+		{keyword: "", expect: true},
+		{keyword: "reset link", expect: true},
+		{keyword: "link reset", expect: false},
+		{keyword: "invoice reset", opts: []SearchOption{WithQueryMode(QueryModeMatch)}, expect: true},
+		{keyword: "invoice reset", opts: []SearchOption{WithQueryMode(QueryModeMatch), WithMatchOperator(MatchOperatorAnd)}, expect: false},
+		{keyword: "pass", opts: []SearchOption{WithQueryMode(QueryModePrefix)}, expect: true},
+		{keyword: "assword", opts: []SearchOption{WithQueryMode(QueryModePrefix)}, expect: false},
+		{keyword: "exp?red", opts: []SearchOption{WithQueryMode(QueryModeWildcard)}, expect: true},
+		{keyword: "+password -expired", opts: []SearchOption{WithQueryMode(QueryModeString)}, expect: false},
+		{keyword: "+password invoice", opts: []SearchOption{WithQueryMode(QueryModeString)}, expect: true},
+		{keyword: `invoice "reset link"`, opts: []SearchOption{WithQueryMode(QueryModeString)}, expect: true},
+		{keyword: "invoice billing", opts: []SearchOption{WithQueryMode(QueryModeString)}, expect: false},
+		{keyword: "-invoice", opts: []SearchOption{WithQueryMode(QueryModeString)}, expect: true},
+	}
+	for _, c := range cases {
+		opts, err := NewSearchOptions(QueryModePhrase, c.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := MatchKeyword(text, c.keyword, opts); got != c.expect {
+			t.Errorf("match %q expected:%v, got:%v", c.keyword, c.expect, got)
+		}
+	}
+}
+
+func TestWithConsistency(t *testing.T) {
+	opts, err := NewSearchOptions(QueryModePhrase, WithConsistency(ConsistencyWaitIndex, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Consistency != ConsistencyWaitIndex || opts.ConsistencyTimeout != DefaultConsistencyTimeout {
+		t.Errorf("unexpected consistency options: %+v", opts)
+	}
+	if _, err := NewSearchOptions(QueryModePhrase, WithConsistency(Consistency(9), 0)); err == nil {
+		t.Error("expect unsupported consistency error")
+	}
+}
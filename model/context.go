@@ -0,0 +1,117 @@
+package model
+
+import (
+	"math"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Tokenizer counts the tokens a message takes in a model context
+type Tokenizer interface {
+	CountTokens(message *Message) int
+}
+
+// TokenizerFunc adapts a function to Tokenizer
+type TokenizerFunc func(message *Message) int
+
+func (f TokenizerFunc) CountTokens(message *Message) int {
+	return f(message)
+}
+
+// EstimateTokenizer approximates token counts without a model vocabulary: CJK characters count
+// as one token each, other text as one token per CharsPerToken characters.
+type EstimateTokenizer struct {
+	// CharsPerToken characters per token for non CJK text, defaults to 4
+	CharsPerToken float64
+	// MessageOverhead tokens added per message for role and separators, defaults to 4
+	MessageOverhead int
+}
+
+func (t EstimateTokenizer) CountTokens(message *Message) int {
+	charsPerToken := t.CharsPerToken
+	if charsPerToken <= 0 {
+		charsPerToken = 4
+	}
+	overhead := t.MessageOverhead
+	if overhead <= 0 {
+		overhead = 4
+	}
+	var (
+		cjk   int
+		other int
+	)
+	for _, r := range message.Content {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	if raw, ok := message.Metadata.Get(MetadataToolCallsKey); ok {
+		if calls, ok := raw.(string); ok {
+			other += utf8.RuneCountInString(calls)
+		}
+	}
+	return overhead + cjk + int(math.Ceil(float64(other)/charsPerToken))
+}
+
+// ContextOptions controls how a context window is built
+type ContextOptions struct {
+	// Tokenizer counts message tokens, defaults to EstimateTokenizer
+	Tokenizer Tokenizer
+	// PageSize messages fetched per page while paging backward, defaults to 20
+	PageSize int
+	// KeepRoles roles always included regardless of age, defaults to system and developer
+	KeepRoles []Role
+}
+
+// ContextOption configures BuildContext
+type ContextOption func(*ContextOptions)
+
+func WithTokenizer(tokenizer Tokenizer) ContextOption {
+	return func(o *ContextOptions) {
+		o.Tokenizer = tokenizer
+	}
+}
+
+func WithContextPageSize(pageSize int) ContextOption {
+	return func(o *ContextOptions) {
+		o.PageSize = pageSize
+	}
+}
+
+func WithKeepRoles(roles ...Role) ContextOption {
+	return func(o *ContextOptions) {
+		o.KeepRoles = roles
+	}
+}
+
+// NewContextOptions applies opts over defaults
+func NewContextOptions(opts ...ContextOption) ContextOptions {
+	ret := ContextOptions{
+		Tokenizer: EstimateTokenizer{},
+		PageSize:  20,
+		KeepRoles: []Role{RoleSystem, RoleDeveloper},
+	}
+	for _, opt := range opts {
+		opt(&ret)
+	}
+	if ret.Tokenizer == nil {
+		ret.Tokenizer = EstimateTokenizer{}
+	}
+	if ret.PageSize <= 0 {
+		ret.PageSize = 20
+	}
+	return ret
+}
+
+// ContextWindow messages selected for a model context in chronological order
+type ContextWindow struct {
+	Messages []Message `json:"messages,omitempty"`
+	// Tokens total tokens of the selected messages
+	Tokens int `json:"tokens,omitempty"`
+	// Budget requested token budget
+	Budget int `json:"budget,omitempty"`
+	// Truncated older messages were left out because of the budget
+	Truncated bool `json:"truncated,omitempty"`
+}
@@ -0,0 +1,66 @@
+package model
+
+import "testing"
+
+func TestEstimateTokenizer(t *testing.T) {
+	tokenizer := EstimateTokenizer{}
+	tests := []struct {
+		content string
+		expect  int
+	}{
+		{"", 4},
+		{"hello", 6},
+		{"hello world!", 7},
+		{"你好世界", 8},
+		{"hi 你好", 7},
+	}
+	for _, tt := range tests {
+		message := NewMessage("session_1", "message_1")
+		message.Content = tt.content
+		if got := tokenizer.CountTokens(message); got != tt.expect {
+			t.Errorf("content:%q, expect tokens:%d, got:%d", tt.content, tt.expect, got)
+		}
+	}
+	custom := EstimateTokenizer{CharsPerToken: 1, MessageOverhead: 1}
+	message := NewMessage("session_1", "message_1")
+	message.Content = "abc"
+	if got := custom.CountTokens(message); got != 4 {
+		t.Errorf("expect tokens:4, got:%d", got)
+	}
+}
+
+func TestNewContextOptions(t *testing.T) {
+	options := NewContextOptions(WithContextPageSize(-1), WithTokenizer(nil))
+	if options.PageSize != 20 {
+		t.Errorf("expect page size:20, got:%d", options.PageSize)
+	}
+	if _, ok := options.Tokenizer.(EstimateTokenizer); !ok {
+		t.Errorf("expect default tokenizer, got:%T", options.Tokenizer)
+	}
+	if len(options.KeepRoles) != 2 {
+		t.Errorf("expect 2 kept roles, got:%v", options.KeepRoles)
+	}
+	counter := TokenizerFunc(func(*Message) int { return 1 })
+	options = NewContextOptions(WithTokenizer(counter), WithKeepRoles(), WithContextPageSize(5))
+	if options.PageSize != 5 || len(options.KeepRoles) != 0 {
+		t.Errorf("unexpected options:%+v", options)
+	}
+	if options.Tokenizer.CountTokens(nil) != 1 {
+		t.Error("expect custom tokenizer")
+	}
+}
+
+func TestMessage_Pinned(t *testing.T) {
+	message := NewMessage("session_1", "message_1")
+	if message.Pinned() {
+		t.Error("expect not pinned")
+	}
+	message.SetPinned(true)
+	if !message.Pinned() {
+		t.Error("expect pinned")
+	}
+	message.SetPinned(false)
+	if _, ok := message.Metadata.Get(MetadataPinnedKey); ok {
+		t.Error("expect pinned key removed")
+	}
+}
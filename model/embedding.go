@@ -0,0 +1,70 @@
+package model
+
+import (
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Embedder turns texts into vectors of a fixed dimension
+type Embedder interface {
+	Embed(texts []string) ([][]float32, error)
+	// Dimension length of the vectors returned by Embed
+	Dimension() int
+}
+
+// HashEmbedder deterministic bag of words embedder hashing each lower cased word into one of Dim
+// buckets. It has no notion of meaning and is meant for tests and local development.
+type HashEmbedder struct {
+	Dim int
+}
+
+func (e HashEmbedder) Dimension() int {
+	return e.Dim
+}
+
+func (e HashEmbedder) Embed(texts []string) ([][]float32, error) {
+	ret := make([][]float32, 0, len(texts))
+	for _, text := range texts {
+		vector := make([]float32, e.Dim)
+		words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for _, word := range words {
+			h := fnv.New32a()
+			h.Write([]byte(word))
+			vector[h.Sum32()%uint32(e.Dim)]++
+		}
+		var norm float64
+		for _, v := range vector {
+			norm += float64(v) * float64(v)
+		}
+		if norm > 0 {
+			norm = math.Sqrt(norm)
+			for i := range vector {
+				vector[i] = float32(float64(vector[i]) / norm)
+			}
+		}
+		ret = append(ret, vector)
+	}
+	return ret, nil
+}
+
+// EmbeddingText text of a message used to compute its embedding
+func (m *Message) EmbeddingText() string {
+	if m.SearchContent != "" {
+		return m.SearchContent
+	}
+	return m.Content
+}
+
+func (m *Message) SetEmbedding(embedding []float32) *Message {
+	m.Embedding = embedding
+	return m
+}
+
+func (s *Session) SetEmbedding(embedding []float32) *Session {
+	s.Embedding = embedding
+	return s
+}
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestHashEmbedder(t *testing.T) {
+	embedder := HashEmbedder{Dim: 16}
+	vectors, err := embedder.Embed([]string{"Reset my password", "reset MY password!", "", "invoice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) != 4 {
+		t.Fatalf("expect 4 vectors, got:%d", len(vectors))
+	}
+	for _, v := range vectors {
+		if len(v) != embedder.Dimension() {
+			t.Errorf("expect dimension:%d, got:%d", embedder.Dimension(), len(v))
+		}
+	}
+	if !reflect.DeepEqual(vectors[0], vectors[1]) {
+		t.Error("expect case and punctuation insensitive embedding")
+	}
+	var norm float64
+	for _, v := range vectors[0] {
+		norm += float64(v) * float64(v)
+	}
+	if math.Abs(norm-1) > 1e-6 {
+		t.Errorf("expect unit vector, got norm:%v", norm)
+	}
+	for _, v := range vectors[2] {
+		if v != 0 {
+			t.Error("expect zero vector for empty text")
+			break
+		}
+	}
+}
+
+func TestMessage_EmbeddingText(t *testing.T) {
+	message := NewMessage("session_1", "message_1").SetContent("content")
+	if message.EmbeddingText() != "content" {
+		t.Errorf("expect content, got:%s", message.EmbeddingText())
+	}
+	message.SetSearchContent("search")
+	if message.EmbeddingText() != "search" {
+		t.Errorf("expect search content, got:%s", message.EmbeddingText())
+	}
+	message.SetEmbedding([]float32{1})
+	if cp := message.Clone(); &cp.Embedding[0] == &message.Embedding[0] {
+		t.Error("expect clone to copy embedding")
+	}
+}
@@ -0,0 +1,56 @@
+package model
+
+// ErasureReport records the progress and result of erasing a user's data.
+// A report returned with an error can be passed back to resume the erasure.
+type ErasureReport struct {
+	// UserID user whose data is erased
+	UserID string `json:"user_id,omitempty"`
+	// Sessions erased sessions and the number of messages deleted from each
+	Sessions map[string]int `json:"sessions,omitempty"`
+	// DeletedSessions total session rows deleted
+	DeletedSessions int `json:"deleted_sessions,omitempty"`
+	// DeletedMessages total message rows deleted
+	DeletedMessages int `json:"deleted_messages,omitempty"`
+	// DeletedMemories total memory rows deleted
+	DeletedMemories int `json:"deleted_memories,omitempty"`
+	// PendingSessionID session being erased when the last attempt failed
+	PendingSessionID string `json:"pending_session_id,omitempty"`
+	// Completed every session and memory of the user has been erased
+	Completed bool `json:"completed,omitempty"`
+	// Verified every read after erasure succeeded and found no remaining sessions, messages or memories
+	Verified bool `json:"verified,omitempty"`
+	// RemainingSessions sessions still found during verification
+	RemainingSessions []string `json:"remaining_sessions,omitempty"`
+	// RemainingMessages messages still found during verification, -1 when they could not be read
+	RemainingMessages int `json:"remaining_messages,omitempty"`
+	// RemainingMemories memories still found during verification, -1 when they could not be read
+	RemainingMemories int `json:"remaining_memories,omitempty"`
+	// StartTime time the first attempt started in microseconds
+	StartTime int64 `json:"start_time,omitempty"`
+	// EndTime time the last attempt finished in microseconds
+	EndTime int64 `json:"end_time,omitempty"`
+}
+
+// NewErasureReport creates an empty report for a user
+func NewErasureReport(userID string) *ErasureReport {
+	return &ErasureReport{
+		UserID:    userID,
+		Sessions:  make(map[string]int),
+		StartTime: CurrentTimeMicroseconds(),
+	}
+}
+
+// AddSession records an erased session with its deleted messages
+func (r *ErasureReport) AddSession(sessionID string, messages int) {
+	r.AddMessages(sessionID, messages)
+	r.DeletedSessions++
+}
+
+// AddMessages records messages deleted from a session whose row is not deleted, or already gone
+func (r *ErasureReport) AddMessages(sessionID string, messages int) {
+	if r.Sessions == nil {
+		r.Sessions = make(map[string]int)
+	}
+	r.Sessions[sessionID] += messages
+	r.DeletedMessages += messages
+}
@@ -0,0 +1,27 @@
+package model
+
+import "testing"
+
+func TestErasureReport_AddSession(t *testing.T) {
+	report := NewErasureReport("user_1")
+	report.AddSession("session_1", 3)
+	report.AddSession("session_2", 0)
+	if report.DeletedSessions != 2 {
+		t.Errorf("expect deleted sessions:2, got:%d", report.DeletedSessions)
+	}
+	if report.DeletedMessages != 3 {
+		t.Errorf("expect deleted messages:3, got:%d", report.DeletedMessages)
+	}
+	if n, ok := report.Sessions["session_2"]; !ok || n != 0 {
+		t.Errorf("expect session_2 recorded with 0 messages, got:%d, %v", n, ok)
+	}
+}
+
+func TestErasureReport_AddMessages(t *testing.T) {
+	// a checkpoint decoded without sessions
+	report := &ErasureReport{UserID: "user_1"}
+	report.AddMessages("session_1", 2)
+	if report.DeletedSessions != 0 || report.DeletedMessages != 2 || report.Sessions["session_1"] != 2 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+}
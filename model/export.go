@@ -0,0 +1,167 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cast"
+)
+
+// ExportVersion version of the JSONL export format
+const ExportVersion = 1
+
+// RecordType type of an export record
+type RecordType string
+
+const (
+	SessionRecord RecordType = "session"
+	MessageRecord RecordType = "message"
+)
+
+// ExportRecord one line of a JSONL export. MetadataTypes keeps the original metadata value types
+// which JSON alone cannot represent, e.g. integers versus doubles and binary values.
+type ExportRecord struct {
+	Version       int                 `json:"version"`
+	Type          RecordType          `json:"type"`
+	Session       *Session            `json:"session,omitempty"`
+	Message       *Message            `json:"message,omitempty"`
+	MetadataTypes map[string]MetaType `json:"metadata_types,omitempty"`
+}
+
+// ConflictPolicy how an import handles rows that already exist
+type ConflictPolicy int
+
+const (
+	// ConflictSkip keeps existing rows and skips imported ones
+	ConflictSkip ConflictPolicy = iota
+	// ConflictOverwrite replaces existing rows
+	ConflictOverwrite
+	// ConflictFail stops the import at the first existing row. It is not atomic, the rows imported before
+	// and the other rows of the failed batch write stay written and are counted in the report.
+	ConflictFail
+)
+
+// ExportReport counts exported rows
+type ExportReport struct {
+	Sessions int `json:"sessions,omitempty"`
+	Messages int `json:"messages,omitempty"`
+}
+
+// ImportReport counts imported and skipped rows
+type ImportReport struct {
+	Sessions        int `json:"sessions,omitempty"`
+	Messages        int `json:"messages,omitempty"`
+	SkippedSessions int `json:"skipped_sessions,omitempty"`
+	SkippedMessages int `json:"skipped_messages,omitempty"`
+}
+
+func NewSessionRecord(session *Session) (*ExportRecord, error) {
+	types, err := metadataTypes(session.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	return &ExportRecord{
+		Version:       ExportVersion,
+		Type:          SessionRecord,
+		Session:       session,
+		MetadataTypes: types,
+	}, nil
+}
+
+func NewMessageRecord(message *Message) (*ExportRecord, error) {
+	types, err := metadataTypes(message.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	return &ExportRecord{
+		Version:       ExportVersion,
+		Type:          MessageRecord,
+		Message:       message,
+		MetadataTypes: types,
+	}, nil
+}
+
+func metadataTypes(metadata Metadata) (map[string]MetaType, error) {
+	if len(metadata) == 0 {
+		return nil, nil
+	}
+	types := make(map[string]MetaType, len(metadata))
+	for k, v := range metadata {
+		t, ok := MetaTypeOf(v)
+		if !ok {
+			return nil, fmt.Errorf("metadata key '%s' has unsupported type '%T'", k, v)
+		}
+		types[k] = t
+	}
+	return types, nil
+}
+
+// Restore validates a decoded record and converts its metadata back to the original types
+func (r *ExportRecord) Restore() error {
+	if r.Version <= 0 || r.Version > ExportVersion {
+		return fmt.Errorf("unsupported export version %d", r.Version)
+	}
+	var metadata Metadata
+	switch r.Type {
+	case SessionRecord:
+		if r.Session == nil {
+			return fmt.Errorf("missing session in %s record", r.Type)
+		}
+		if r.Session.Metadata == nil {
+			r.Session.Metadata = NewMetadata()
+		}
+		metadata = r.Session.Metadata
+	case MessageRecord:
+		if r.Message == nil {
+			return fmt.Errorf("missing message in %s record", r.Type)
+		}
+		if r.Message.Metadata == nil {
+			r.Message.Metadata = NewMetadata()
+		}
+		metadata = r.Message.Metadata
+	default:
+		return fmt.Errorf("unknown record type %s", r.Type)
+	}
+	for k, v := range metadata {
+		t, ok := r.MetadataTypes[k]
+		if !ok {
+			if n, isNumber := v.(json.Number); isNumber {
+				metadata[k] = n.String()
+			}
+			continue
+		}
+		value, err := restoreMetaValue(t, v)
+		if err != nil {
+			return fmt.Errorf("restore metadata key '%s' failed, %w", k, err)
+		}
+		metadata[k] = value
+	}
+	return nil
+}
+
+func restoreMetaValue(t MetaType, v any) (any, error) {
+	switch t {
+	case STRING:
+		return cast.ToStringE(v)
+	case INTEGER:
+		if n, ok := v.(json.Number); ok {
+			return n.Int64()
+		}
+		return cast.ToInt64E(v)
+	case DOUBLE:
+		if n, ok := v.(json.Number); ok {
+			return n.Float64()
+		}
+		return cast.ToFloat64E(v)
+	case BOOLEAN:
+		return cast.ToBoolE(v)
+	case BINARY:
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("binary value must be base64 string, got %T", v)
+		}
+		return base64.StdEncoding.DecodeString(s)
+	}
+	return nil, fmt.Errorf("unknown metadata type %s", t)
+}
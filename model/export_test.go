@@ -0,0 +1,51 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestExportRecord_RoundTrip(t *testing.T) {
+	metadata := NewMetadata()
+	metadata.Put("string", "value")
+	metadata.Put("long", int64(1<<60+1))
+	metadata.Put("double", 1.5)
+	metadata.Put("bool", true)
+	metadata.Put("bytes", []byte("hello"))
+	message := NewMessageFull("session_1", "message_1", 123, "hello world", metadata)
+
+	record, err := NewMessageRecord(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(record); err != nil {
+		t.Fatal(err)
+	}
+
+	decoder := json.NewDecoder(&buf)
+	decoder.UseNumber()
+	var decoded ExportRecord
+	if err := decoder.Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if err := decoded.Restore(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.Message, message) {
+		t.Fatalf("messages not equal:\n%+v\n%+v", decoded.Message, message)
+	}
+}
+
+func TestExportRecord_RestoreValidation(t *testing.T) {
+	record := ExportRecord{Version: ExportVersion + 1, Type: SessionRecord, Session: NewSession("user_1", "session_1")}
+	if err := record.Restore(); err == nil {
+		t.Error("expected error for unsupported version, but got nil")
+	}
+	record = ExportRecord{Version: ExportVersion, Type: MessageRecord}
+	if err := record.Restore(); err == nil {
+		t.Error("expected error for missing message, but got nil")
+	}
+}
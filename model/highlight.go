@@ -0,0 +1,47 @@
+package model
+
+const (
+	DefaultHighlightPreTag            = "<em>"
+	DefaultHighlightPostTag           = "</em>"
+	DefaultHighlightFragmentSize      = 100
+	DefaultHighlightNumberOfFragments = 3
+)
+
+// HighlightOptions highlighting of search_content in keyword searches
+type HighlightOptions struct {
+	// Disabled skips highlighting, scores are still returned
+	Disabled          bool
+	PreTag            string
+	PostTag           string
+	FragmentSize      int32
+	NumberOfFragments int32
+}
+
+func (o *HighlightOptions) Normalize() {
+	if o.PreTag == "" {
+		o.PreTag = DefaultHighlightPreTag
+	}
+	if o.PostTag == "" {
+		o.PostTag = DefaultHighlightPostTag
+	}
+	if o.FragmentSize <= 0 {
+		o.FragmentSize = DefaultHighlightFragmentSize
+	}
+	if o.NumberOfFragments <= 0 {
+		o.NumberOfFragments = DefaultHighlightNumberOfFragments
+	}
+}
+
+// HitDetail relevance of a search hit
+type HitDetail struct {
+	// Score relevance score, higher is better
+	Score float64 `json:"score,omitempty"`
+	// Highlights fragments of search_content with matched terms wrapped by the highlight tags
+	Highlights []string `json:"highlights,omitempty"`
+}
+
+// SearchHit a search result wrapped with its relevance
+type SearchHit[T any] struct {
+	Hit T `json:"hit"`
+	HitDetail
+}
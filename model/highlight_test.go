@@ -0,0 +1,31 @@
+package model
+
+import "testing"
+
+func TestHighlightOptionsNormalize(t *testing.T) {
+	opts := HighlightOptions{PreTag: "<b>"}
+	opts.Normalize()
+	if opts.PreTag != "<b>" || opts.PostTag != DefaultHighlightPostTag {
+		t.Errorf("unexpected tags: %s %s", opts.PreTag, opts.PostTag)
+	}
+	if opts.FragmentSize != DefaultHighlightFragmentSize || opts.NumberOfFragments != DefaultHighlightNumberOfFragments {
+		t.Errorf("unexpected fragment settings: %+v", opts)
+	}
+}
+
+func TestResponseSearchHits(t *testing.T) {
+	resp := Response[Message]{
+		Hits:    []Message{{MessageID: "a"}, {MessageID: "b"}},
+		Details: []HitDetail{{Score: 2, Highlights: []string{"<em>x</em>"}}},
+	}
+	hits := resp.SearchHits()
+	if len(hits) != 2 {
+		t.Fatalf("expect 2 hits, got:%d", len(hits))
+	}
+	if hits[0].Hit.MessageID != "a" || hits[0].Score != 2 || len(hits[0].Highlights) != 1 {
+		t.Errorf("unexpected first hit: %+v", hits[0])
+	}
+	if hits[1].Hit.MessageID != "b" || hits[1].Score != 0 || hits[1].Highlights != nil {
+		t.Errorf("expect missing details zero, got:%+v", hits[1])
+	}
+}
@@ -0,0 +1,20 @@
+package model
+
+// MessageVersion a version of a message and the time range it was the current one
+type MessageVersion struct {
+	Message
+	// ValidFrom time the version was written in microseconds, the create time for the first version
+	ValidFrom int64 `json:"valid_from,omitempty"`
+	// ValidTo time the version was replaced in microseconds, 0 for the current version
+	ValidTo int64 `json:"valid_to,omitempty"`
+}
+
+// IsCurrent reports whether the version has not been replaced
+func (v *MessageVersion) IsCurrent() bool {
+	return v.ValidTo == 0
+}
+
+// ValidAt reports whether the version was the current one at t in microseconds
+func (v *MessageVersion) ValidAt(t int64) bool {
+	return t >= v.ValidFrom && (v.ValidTo == 0 || t < v.ValidTo)
+}
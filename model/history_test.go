@@ -0,0 +1,22 @@
+package model
+
+import "testing"
+
+func TestMessageVersion_ValidAt(t *testing.T) {
+	past := MessageVersion{ValidFrom: 100, ValidTo: 200}
+	if past.IsCurrent() {
+		t.Error("expect replaced version not current")
+	}
+	for at, expected := range map[int64]bool{99: false, 100: true, 199: true, 200: false} {
+		if got := past.ValidAt(at); got != expected {
+			t.Errorf("expect ValidAt(%d):%v, got:%v", at, expected, got)
+		}
+	}
+	current := MessageVersion{ValidFrom: 200}
+	if !current.IsCurrent() {
+		t.Error("expect version current")
+	}
+	if current.ValidAt(199) || !current.ValidAt(200) || !current.ValidAt(1<<62) {
+		t.Error("unexpected current version validity")
+	}
+}
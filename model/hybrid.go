@@ -0,0 +1,163 @@
+package model
+
+import (
+	"errors"
+	"sort"
+)
+
+// Retriever source of a search hit
+type Retriever string
+
+const (
+	RetrieverKeyword Retriever = "keyword"
+	RetrieverVector  Retriever = "vector"
+)
+
+// FusionMethod how hybrid search merges keyword and vector results
+type FusionMethod int
+
+const (
+	// FusionRRF reciprocal rank fusion, sums 1/(RRFConstant+rank) over retrievers
+	FusionRRF FusionMethod = iota
+	// FusionWeighted sums the scores of each retriever normalized by its best score and weighted
+	FusionWeighted
+)
+
+// DefaultRRFConstant rank offset of reciprocal rank fusion
+const DefaultRRFConstant = 60
+
+// HybridSearchRequest query of HybridSearchMessages
+type HybridSearchRequest struct {
+	// SessionID restricts the search to a session, otherwise UserID restricts it to the user's sessions
+	SessionID string `json:"session_id,omitempty"`
+	UserID    string `json:"user_id,omitempty"`
+	Query     string `json:"query,omitempty"`
+	// QueryMode and MatchOperator keyword matching of the keyword retriever, defaults to phrase
+	QueryMode     QueryMode        `json:"query_mode,omitempty"`
+	MatchOperator MatchOperator    `json:"match_operator,omitempty"`
+	Filters       []MetadataFilter `json:"filters,omitempty"`
+	// TopK number of fused hits returned, defaults to 10
+	TopK int32 `json:"top_k,omitempty"`
+	// CandidateK hits fetched from each retriever, defaults to 2*TopK
+	CandidateK int32        `json:"candidate_k,omitempty"`
+	Fusion     FusionMethod `json:"fusion,omitempty"`
+	// RRFConstant rank offset of FusionRRF, defaults to DefaultRRFConstant
+	RRFConstant float64 `json:"rrf_constant,omitempty"`
+	// KeywordWeight and VectorWeight weights of FusionWeighted, both default to 1
+	KeywordWeight float64 `json:"keyword_weight,omitempty"`
+	VectorWeight  float64 `json:"vector_weight,omitempty"`
+}
+
+func (r *HybridSearchRequest) Validate() error {
+	if r.SessionID == "" && r.UserID == "" {
+		return errors.New("either session id or user id is required")
+	}
+	if r.Query == "" {
+		return errors.New("query is required")
+	}
+	for _, v := range r.Filters {
+		if err := v.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Normalize fills zero values with defaults
+func (r *HybridSearchRequest) Normalize() {
+	if r.TopK <= 0 {
+		r.TopK = 10
+	}
+	if r.CandidateK <= 0 {
+		r.CandidateK = 2 * r.TopK
+	}
+	if r.RRFConstant <= 0 {
+		r.RRFConstant = DefaultRRFConstant
+	}
+	if r.KeywordWeight <= 0 && r.VectorWeight <= 0 {
+		r.KeywordWeight = 1
+		r.VectorWeight = 1
+	}
+}
+
+// RankedMessage a message returned by one retriever with its raw score
+type RankedMessage struct {
+	Message Message `json:"message"`
+	Score   float64 `json:"score,omitempty"`
+}
+
+// HybridHit a fused search hit
+type HybridHit struct {
+	Message Message `json:"message"`
+	// Score fused score, higher is better
+	Score float64 `json:"score,omitempty"`
+	// KeywordScore and VectorScore raw scores of the retrievers that matched
+	KeywordScore float64 `json:"keyword_score,omitempty"`
+	VectorScore  float64 `json:"vector_score,omitempty"`
+	// KeywordRank and VectorRank 1 based ranks in each retriever, 0 when not matched
+	KeywordRank int         `json:"keyword_rank,omitempty"`
+	VectorRank  int         `json:"vector_rank,omitempty"`
+	Retrievers  []Retriever `json:"retrievers,omitempty"`
+}
+
+// FuseResults merges keyword and vector results deduplicated by session id and message id, ordered
+// by fused score and returning at most TopK hits
+func FuseResults(req HybridSearchRequest, keyword []RankedMessage, vector []RankedMessage) []HybridHit {
+	req.Normalize()
+	type key struct {
+		sessionID string
+		messageID string
+	}
+	var (
+		hits  []*HybridHit
+		index = make(map[key]*HybridHit)
+	)
+	add := func(retriever Retriever, results []RankedMessage, weight float64) {
+		var best float64
+		for _, v := range results {
+			best = max(best, v.Score)
+		}
+		for i, v := range results {
+			k := key{sessionID: v.Message.SessionID, messageID: v.Message.MessageID}
+			hit, ok := index[k]
+			if !ok {
+				hit = &HybridHit{Message: v.Message}
+				index[k] = hit
+				hits = append(hits, hit)
+			} else if hit.KeywordRank > 0 && retriever == RetrieverKeyword || hit.VectorRank > 0 && retriever == RetrieverVector {
+				// keep the best ranked duplicate of a retriever
+				continue
+			}
+			rank := i + 1
+			hit.Retrievers = append(hit.Retrievers, retriever)
+			if retriever == RetrieverKeyword {
+				hit.KeywordRank = rank
+				hit.KeywordScore = v.Score
+			} else {
+				hit.VectorRank = rank
+				hit.VectorScore = v.Score
+			}
+			switch req.Fusion {
+			case FusionWeighted:
+				if best > 0 {
+					hit.Score += weight * v.Score / best
+				}
+			default:
+				hit.Score += 1 / (req.RRFConstant + float64(rank))
+			}
+		}
+	}
+	add(RetrieverKeyword, keyword, req.KeywordWeight)
+	add(RetrieverVector, vector, req.VectorWeight)
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Score > hits[j].Score
+	})
+	if len(hits) > int(req.TopK) {
+		hits = hits[:req.TopK]
+	}
+	ret := make([]HybridHit, 0, len(hits))
+	for _, v := range hits {
+		ret = append(ret, *v)
+	}
+	return ret
+}
@@ -0,0 +1,88 @@
+package model
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func rankedMessages(sessionID string, scores map[string]float64, ids ...string) []RankedMessage {
+	ret := make([]RankedMessage, 0, len(ids))
+	for _, id := range ids {
+		ret = append(ret, RankedMessage{
+			Message: Message{SessionID: sessionID, MessageID: id},
+			Score:   scores[id],
+		})
+	}
+	return ret
+}
+
+func hitIDs(hits []HybridHit) []string {
+	ret := make([]string, 0, len(hits))
+	for _, v := range hits {
+		ret = append(ret, v.Message.MessageID)
+	}
+	return ret
+}
+
+func TestFuseResultsRRF(t *testing.T) {
+	keyword := rankedMessages("s1", map[string]float64{"a": 3, "b": 2}, "a", "b")
+	vector := rankedMessages("s1", map[string]float64{"b": 0.9, "c": 0.8}, "b", "c")
+	hits := FuseResults(HybridSearchRequest{TopK: 10}, keyword, vector)
+	if ids := hitIDs(hits); !reflect.DeepEqual(ids, []string{"b", "a", "c"}) {
+		t.Fatalf("unexpected order: %v", ids)
+	}
+	b := hits[0]
+	if expect := 1/(DefaultRRFConstant+2.0) + 1/(DefaultRRFConstant+1.0); math.Abs(b.Score-expect) > 1e-9 {
+		t.Errorf("expect score:%v, got:%v", expect, b.Score)
+	}
+	if b.KeywordRank != 2 || b.VectorRank != 1 || b.KeywordScore != 2 || b.VectorScore != 0.9 {
+		t.Errorf("unexpected retriever details: %+v", b)
+	}
+	if !reflect.DeepEqual(b.Retrievers, []Retriever{RetrieverKeyword, RetrieverVector}) {
+		t.Errorf("unexpected retrievers: %v", b.Retrievers)
+	}
+	if c := hits[2]; c.KeywordRank != 0 || !reflect.DeepEqual(c.Retrievers, []Retriever{RetrieverVector}) {
+		t.Errorf("expect vector only hit, got:%+v", c)
+	}
+}
+
+func TestFuseResultsDedup(t *testing.T) {
+	keyword := append(rankedMessages("s1", nil, "a"), rankedMessages("s2", nil, "a", "a")...)
+	hits := FuseResults(HybridSearchRequest{}, keyword, nil)
+	if len(hits) != 2 {
+		t.Fatalf("expect messages deduplicated by session and message id, got:%d", len(hits))
+	}
+	if hits[1].KeywordRank != 2 {
+		t.Errorf("expect best rank kept, got:%d", hits[1].KeywordRank)
+	}
+}
+
+func TestFuseResultsWeighted(t *testing.T) {
+	keyword := rankedMessages("s1", map[string]float64{"a": 10, "b": 5}, "a", "b")
+	vector := rankedMessages("s1", map[string]float64{"b": 1, "a": 0.1}, "b", "a")
+	hits := FuseResults(HybridSearchRequest{Fusion: FusionWeighted, KeywordWeight: 0.2, VectorWeight: 0.8, TopK: 1}, keyword, vector)
+	if len(hits) != 1 {
+		t.Fatalf("expect top 1, got:%d", len(hits))
+	}
+	if hits[0].Message.MessageID != "b" {
+		t.Errorf("expect vector weighted hit first, got:%s", hits[0].Message.MessageID)
+	}
+	if expect := 0.2*0.5 + 0.8; math.Abs(hits[0].Score-expect) > 1e-9 {
+		t.Errorf("expect score:%v, got:%v", expect, hits[0].Score)
+	}
+}
+
+func TestHybridSearchRequestValidate(t *testing.T) {
+	if err := (&HybridSearchRequest{Query: "x"}).Validate(); err == nil {
+		t.Error("expect scope required")
+	}
+	if err := (&HybridSearchRequest{SessionID: "s"}).Validate(); err == nil {
+		t.Error("expect query required")
+	}
+	req := HybridSearchRequest{SessionID: "s", Query: "x", TopK: 5}
+	req.Normalize()
+	if req.CandidateK != 10 || req.RRFConstant != DefaultRRFConstant || req.KeywordWeight != 1 || req.VectorWeight != 1 {
+		t.Errorf("unexpected defaults: %+v", req)
+	}
+}
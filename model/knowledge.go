@@ -0,0 +1,206 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+)
+
+// --------------------
+// Document
+// --------------------
+
+// Document a chunk of a tenant document stored in the knowledge store
+type Document struct {
+	TenantID   string `json:"tenant_id,omitempty"`
+	DocumentID string `json:"document_id,omitempty"`
+	// ChunkIndex position of the chunk within the document
+	ChunkIndex int64 `json:"chunk_index,omitempty"`
+
+	Content string `json:"content,omitempty"`
+	// Embedding vector of the content, its length must match the configured dimension
+	Embedding  []float32 `json:"embedding,omitempty"`
+	UpdateTime int64     `json:"update_time,omitempty"`
+	Metadata   Metadata  `json:"metadata,omitempty"`
+}
+
+// --------------------
+// constructors
+// --------------------
+
+// NewDocument creates a document chunk with current update time
+func NewDocument(tenantID, documentID string, chunkIndex int64) *Document {
+	return &Document{
+		TenantID:   tenantID,
+		DocumentID: documentID,
+		ChunkIndex: chunkIndex,
+		UpdateTime: CurrentTimeMicroseconds(),
+		Metadata:   NewMetadata(),
+	}
+}
+
+// Clone copy constructor
+func (d *Document) Clone() *Document {
+	if d == nil {
+		return nil
+	}
+	cp := *d
+	cp.Embedding = append([]float32(nil), d.Embedding...)
+	cp.Metadata = d.Metadata.Copy()
+	return &cp
+}
+
+// --------------------
+// fluent setters (chainable)
+// --------------------
+func (d *Document) SetContent(content string) *Document {
+	d.Content = content
+	return d
+}
+
+func (d *Document) SetEmbedding(embedding []float32) *Document {
+	d.Embedding = embedding
+	return d
+}
+
+func (d *Document) SetUpdateTime(t int64) *Document {
+	d.UpdateTime = t
+	return d
+}
+
+func (d *Document) SetMetadata(metadata Metadata) *Document {
+	if metadata == nil {
+		metadata = NewMetadata()
+	}
+	d.Metadata = metadata
+	return d
+}
+
+// --------------------
+// KnowledgeStore options
+// --------------------
+
+// IndexField a metadata column indexed by a search index so it can be filtered on
+type IndexField struct {
+	Name string   `json:"name,omitempty"`
+	Type MetaType `json:"type,omitempty"`
+}
+
+type KnowledgeOptions struct {
+	TableName       string
+	SearchIndexName string
+	TableOptions    TableOptions
+	// EmbeddingDimension dimension of document embeddings, 0 disables the vector field
+	EmbeddingDimension int32
+	// EmbeddingMetric similarity metric of the vector field, defaults to cosine
+	EmbeddingMetric tablestore.VectorMetricType
+	// MetadataFields metadata columns indexed for filtering
+	MetadataFields []IndexField
+	// Analyzer tokenizer of the content field, defaults to single_word, changing it requires recreating
+	// the search index
+	Analyzer AnalyzerOptions
+}
+
+type KnowledgeOption func(*KnowledgeOptions)
+
+func WithKnowledgeTableName(name string) KnowledgeOption {
+	return func(o *KnowledgeOptions) {
+		o.TableName = name
+	}
+}
+
+func WithKnowledgeSearchIndexName(name string) KnowledgeOption {
+	return func(o *KnowledgeOptions) {
+		o.SearchIndexName = name
+	}
+}
+
+func WithKnowledgeTableOptions(opts TableOptions) KnowledgeOption {
+	return func(o *KnowledgeOptions) {
+		o.TableOptions = opts
+	}
+}
+
+// WithEmbedding enables the vector field of the knowledge search index
+func WithEmbedding(dimension int32, metric tablestore.VectorMetricType) KnowledgeOption {
+	return func(o *KnowledgeOptions) {
+		o.EmbeddingDimension = dimension
+		o.EmbeddingMetric = metric
+	}
+}
+
+// WithKnowledgeAnalyzer selects the tokenizer of the content field
+func WithKnowledgeAnalyzer(opts AnalyzerOptions) KnowledgeOption {
+	return func(o *KnowledgeOptions) {
+		o.Analyzer = opts
+	}
+}
+
+// WithMetadataFields indexes metadata columns so SearchDocuments can filter on them
+func WithMetadataFields(fields ...IndexField) KnowledgeOption {
+	return func(o *KnowledgeOptions) {
+		o.MetadataFields = append(o.MetadataFields, fields...)
+	}
+}
+
+// --------------------
+// search
+// --------------------
+
+// MetadataFilter restricts search hits by an indexed field, either to one of Values or to the
+// range between From and To (inclusive, nil means unbounded)
+type MetadataFilter struct {
+	Field  string `json:"field,omitempty"`
+	Values []any  `json:"values,omitempty"`
+	From   any    `json:"from,omitempty"`
+	To     any    `json:"to,omitempty"`
+}
+
+// MetadataEquals matches documents whose field is one of values
+func MetadataEquals(field string, values ...any) MetadataFilter {
+	return MetadataFilter{Field: field, Values: values}
+}
+
+// MetadataRange matches documents whose field is between from and to inclusive
+func MetadataRange(field string, from, to any) MetadataFilter {
+	return MetadataFilter{Field: field, From: from, To: to}
+}
+
+func (f MetadataFilter) Validate() error {
+	if f.Field == "" {
+		return errors.New("metadata filter field is required")
+	}
+	if len(f.Values) == 0 && f.From == nil && f.To == nil {
+		return fmt.Errorf("metadata filter on %s has no condition", f.Field)
+	}
+	return nil
+}
+
+// DocumentSearchRequest query of SearchDocuments. Query runs a full text match over the content,
+// Embedding runs a KNN vector query, filters restrict both.
+type DocumentSearchRequest struct {
+	TenantID    string           `json:"tenant_id,omitempty"`
+	Query       string           `json:"query,omitempty"`
+	Embedding   []float32        `json:"embedding,omitempty"`
+	DocumentIDs []string         `json:"document_ids,omitempty"`
+	Filters     []MetadataFilter `json:"filters,omitempty"`
+	// PageSize hits per page, also the number of neighbours of a vector query
+	PageSize  int32  `json:"page_size,omitempty"`
+	NextToken []byte `json:"next_token,omitempty"`
+}
+
+func (r *DocumentSearchRequest) Validate() error {
+	if r.TenantID == "" {
+		return errors.New("tenant id is required")
+	}
+	if r.Query == "" && len(r.Embedding) == 0 {
+		return errors.New("either query or embedding is required")
+	}
+	for _, v := range r.Filters {
+		if err := v.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
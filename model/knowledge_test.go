@@ -0,0 +1,38 @@
+package model
+
+import "testing"
+
+func TestDocumentSearchRequest_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     DocumentSearchRequest
+		wantErr bool
+	}{
+		{"missing tenant", DocumentSearchRequest{Query: "password"}, true},
+		{"missing query", DocumentSearchRequest{TenantID: "tenant_1"}, true},
+		{"text", DocumentSearchRequest{TenantID: "tenant_1", Query: "password"}, false},
+		{"vector", DocumentSearchRequest{TenantID: "tenant_1", Embedding: []float32{1, 0}}, false},
+		{"empty filter", DocumentSearchRequest{TenantID: "tenant_1", Query: "password", Filters: []MetadataFilter{{Field: "category"}}}, true},
+		{"filter without field", DocumentSearchRequest{TenantID: "tenant_1", Query: "password", Filters: []MetadataFilter{MetadataEquals("", "a")}}, true},
+		{"filters", DocumentSearchRequest{TenantID: "tenant_1", Query: "password", Filters: []MetadataFilter{MetadataEquals("category", "a"), MetadataRange("year", 2020, nil)}}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.req.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: expect error:%v, got:%v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestDocument_Clone(t *testing.T) {
+	doc := NewDocument("tenant_1", "doc_1", 2).SetContent("hello").SetEmbedding([]float32{1, 2})
+	doc.Metadata.Put("category", "faq")
+	cp := doc.Clone()
+	cp.Embedding[0] = 9
+	cp.Metadata.Put("category", "other")
+	if doc.Embedding[0] != 1 || *doc.Metadata.GetString("category") != "faq" {
+		t.Error("expect clone not to share embedding or metadata")
+	}
+	if cp.ChunkIndex != 2 || cp.Content != "hello" {
+		t.Errorf("unexpected clone:%+v", cp)
+	}
+}
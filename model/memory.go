@@ -0,0 +1,143 @@
+package model
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// --------------------
+// Memory
+// --------------------
+
+// Memory a durable fact about a user extracted from conversations
+type Memory struct {
+	UserID   string `json:"user_id,omitempty"`
+	MemoryID string `json:"memory_id,omitempty"`
+
+	Content string `json:"content,omitempty"`
+	// Importance relevance of the fact, higher is more important
+	Importance float64 `json:"importance,omitempty"`
+	// Sources messages the fact was extracted from
+	Sources    []MemorySource `json:"sources,omitempty"`
+	CreateTime int64          `json:"create_time,omitempty"`
+	UpdateTime int64          `json:"update_time,omitempty"`
+	// ExpireTime time in microseconds after which the memory is ignored, 0 means never expire
+	ExpireTime int64    `json:"expire_time,omitempty"`
+	Metadata   Metadata `json:"metadata,omitempty"`
+}
+
+// MemorySource reference to the message a memory was extracted from
+type MemorySource struct {
+	SessionID string `json:"session_id,omitempty"`
+	MessageID string `json:"message_id,omitempty"`
+}
+
+// --------------------
+// constructors
+// --------------------
+
+// NewMemory creates a memory whose id is derived from its content, so the same fact stored twice
+// for a user is merged instead of duplicated
+func NewMemory(userID, content string) *Memory {
+	now := CurrentTimeMicroseconds()
+	return &Memory{
+		UserID:     userID,
+		MemoryID:   MemoryIDFor(content),
+		Content:    content,
+		CreateTime: now,
+		UpdateTime: now,
+		Metadata:   NewMetadata(),
+	}
+}
+
+// MemoryIDFor deterministic memory id of a fact, case and whitespace insensitive
+func MemoryIDFor(content string) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(content)), " ")
+	sum := sha1.Sum([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// Clone copy constructor
+func (m *Memory) Clone() *Memory {
+	if m == nil {
+		return nil
+	}
+	cp := *m
+	cp.Sources = slices.Clone(m.Sources)
+	cp.Metadata = m.Metadata.Copy()
+	return &cp
+}
+
+// --------------------
+// fluent setters (chainable)
+// --------------------
+func (m *Memory) SetImportance(importance float64) *Memory {
+	m.Importance = importance
+	return m
+}
+
+// AddSource records the message the memory was extracted from, duplicates are ignored
+func (m *Memory) AddSource(sessionID, messageID string) *Memory {
+	source := MemorySource{SessionID: sessionID, MessageID: messageID}
+	if !slices.Contains(m.Sources, source) {
+		m.Sources = append(m.Sources, source)
+	}
+	return m
+}
+
+func (m *Memory) SetExpireTime(t int64) *Memory {
+	m.ExpireTime = t
+	return m
+}
+
+// SetTTL expires the memory ttl after now
+func (m *Memory) SetTTL(ttl time.Duration) *Memory {
+	m.ExpireTime = CurrentTimeMicroseconds() + ttl.Microseconds()
+	return m
+}
+
+func (m *Memory) SetMetadata(metadata Metadata) *Memory {
+	if metadata == nil {
+		metadata = NewMetadata()
+	}
+	m.Metadata = metadata
+	return m
+}
+
+// --------------------
+// behavior
+// --------------------
+
+// Expired reports whether the memory is expired at now (microseconds)
+func (m *Memory) Expired(now int64) bool {
+	return m.ExpireTime > 0 && m.ExpireTime <= now
+}
+
+// Merge folds an existing copy of the same memory into m: the earliest create time, the highest
+// importance, the union of sources and the latest expiry are kept, metadata of m wins on conflict.
+func (m *Memory) Merge(existing *Memory) {
+	if existing == nil {
+		return
+	}
+	if existing.CreateTime > 0 && (m.CreateTime == 0 || existing.CreateTime < m.CreateTime) {
+		m.CreateTime = existing.CreateTime
+	}
+	m.Importance = max(m.Importance, existing.Importance)
+	sources := slices.Clone(existing.Sources)
+	for _, v := range m.Sources {
+		if !slices.Contains(sources, v) {
+			sources = append(sources, v)
+		}
+	}
+	m.Sources = sources
+	if m.ExpireTime > 0 && (existing.ExpireTime == 0 || existing.ExpireTime > m.ExpireTime) {
+		m.ExpireTime = existing.ExpireTime
+	}
+	metadata := existing.Metadata.Copy()
+	maps.Copy(metadata, m.Metadata)
+	m.Metadata = metadata
+}
@@ -0,0 +1,70 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMemoryIDFor(t *testing.T) {
+	if MemoryIDFor("User prefers  metric units") != MemoryIDFor(" user prefers metric\tunits ") {
+		t.Error("expect same id for case and whitespace variants")
+	}
+	if MemoryIDFor("user prefers metric units") == MemoryIDFor("user prefers imperial units") {
+		t.Error("expect different ids for different facts")
+	}
+}
+
+func TestMemory_Expired(t *testing.T) {
+	memory := NewMemory("user_1", "likes tea")
+	if memory.Expired(CurrentTimeMicroseconds()) {
+		t.Error("expect memory without expiry not expired")
+	}
+	memory.SetExpireTime(100)
+	if !memory.Expired(100) || memory.Expired(99) {
+		t.Error("expect memory expired from expire time")
+	}
+}
+
+func TestMemory_Merge(t *testing.T) {
+	existing := &Memory{
+		UserID:     "user_1",
+		MemoryID:   "memory_1",
+		Importance: 0.8,
+		Sources:    []MemorySource{{SessionID: "session_1", MessageID: "message_1"}},
+		CreateTime: 10,
+		ExpireTime: 500,
+		Metadata:   Metadata{"category": "preference", "lang": "en"},
+	}
+	memory := &Memory{
+		UserID:     "user_1",
+		MemoryID:   "memory_1",
+		Importance: 0.5,
+		CreateTime: 20,
+		ExpireTime: 300,
+		Metadata:   Metadata{"lang": "fr"},
+	}
+	memory.AddSource("session_1", "message_1").AddSource("session_2", "message_9")
+	memory.Merge(existing)
+	if memory.CreateTime != 10 {
+		t.Errorf("expect earliest create time:10, got:%d", memory.CreateTime)
+	}
+	if memory.Importance != 0.8 {
+		t.Errorf("expect highest importance:0.8, got:%v", memory.Importance)
+	}
+	if memory.ExpireTime != 500 {
+		t.Errorf("expect latest expiry:500, got:%d", memory.ExpireTime)
+	}
+	expectSources := []MemorySource{{SessionID: "session_1", MessageID: "message_1"}, {SessionID: "session_2", MessageID: "message_9"}}
+	if !reflect.DeepEqual(memory.Sources, expectSources) {
+		t.Errorf("expect sources:%v, got:%v", expectSources, memory.Sources)
+	}
+	if memory.Metadata["lang"] != "fr" || memory.Metadata["category"] != "preference" {
+		t.Errorf("unexpected merged metadata:%v", memory.Metadata)
+	}
+
+	never := &Memory{ExpireTime: 300}
+	never.Merge(&Memory{})
+	if never.ExpireTime != 0 {
+		t.Errorf("expect no expiry to win, got:%d", never.ExpireTime)
+	}
+}
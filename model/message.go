@@ -1,5 +1,7 @@
 package model
 
+import "slices"
+
 // --------------------
 // Message
 // --------------------
@@ -8,10 +10,24 @@ type Message struct {
 	SessionID  string `json:"session_id,omitempty"`
 	MessageID  string `json:"message,omitempty"`
 	CreateTime int64  `json:"create_time,omitempty"`
+	// UserID owner of the session, filled by the store when the session or its latest messages carry it
+	UserID string `json:"user_id,omitempty"`
+	// ParentMessageID message this one answers or follows, empty for a root message
+	ParentMessageID string `json:"parent_message_id,omitempty"`
+	// BranchID branch the message belongs to, empty for the main branch
+	BranchID string `json:"branch_id,omitempty"`
 
 	Content       string   `json:"content,omitempty"`
 	Metadata      Metadata `json:"metadata,omitempty"`
 	SearchContent string   `json:"search_content,omitempty"`
+	// Embedding vector of the message text, computed on put when an Embedder is configured
+	Embedding []float32 `json:"embedding,omitempty"`
+	// DeletedAt time the message was moved to the trash in microseconds, 0 if not deleted
+	DeletedAt int64 `json:"deleted_at,omitempty"`
+	// StreamStatus progress of a message written by BeginMessage, empty for messages written at once
+	StreamStatus StreamStatus `json:"stream_status,omitempty"`
+	// StreamUpdateTime time the streamed content was last written in microseconds
+	StreamUpdateTime int64 `json:"stream_update_time,omitempty"`
 }
 
 // --------------------
@@ -58,6 +74,7 @@ func (m *Message) Clone() *Message {
 	}
 	cp := *m
 	cp.Metadata = m.Metadata.Copy()
+	cp.Embedding = slices.Clone(m.Embedding)
 	return &cp
 }
 
@@ -69,6 +86,21 @@ func (m *Message) SetCreateTime(t int64) *Message {
 	return m
 }
 
+func (m *Message) SetUserID(userID string) *Message {
+	m.UserID = userID
+	return m
+}
+
+func (m *Message) SetParentMessageID(parentMessageID string) *Message {
+	m.ParentMessageID = parentMessageID
+	return m
+}
+
+func (m *Message) SetBranchID(branchID string) *Message {
+	m.BranchID = branchID
+	return m
+}
+
 func (m *Message) SetContent(content string) *Message {
 	m.Content = content
 	return m
@@ -86,3 +118,8 @@ func (m *Message) SetMetadata(metadata Metadata) *Message {
 	m.Metadata = metadata
 	return m
 }
+
+// IsDeleted reports whether the message is in the trash
+func (m *Message) IsDeleted() bool {
+	return m.DeletedAt > 0
+}
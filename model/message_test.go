@@ -20,6 +20,7 @@ func TestMessage_Simple(t *testing.T) {
 	messageSimple2.SetCreateTime(123)
 	messageSimple2.SetContent("hello world")
 	messageSimple2.SetMetadata(metadata)
+	messageSimple2.SetUserID("789")
 
 	// copy constructor
 	messageCopy := messageSimple2.Clone()
@@ -28,4 +29,7 @@ func TestMessage_Simple(t *testing.T) {
 	if !reflect.DeepEqual(messageCopy, messageSimple2) {
 		t.Fatalf("messages not equal:\n%+v\n%+v", messageCopy, messageSimple2)
 	}
+	if messageCopy.UserID != "789" {
+		t.Errorf("expect user id copied, got:%s", messageCopy.UserID)
+	}
 }
@@ -1,5 +1,7 @@
 package model
 
+import "fmt"
+
 type MetaType int
 
 const (
@@ -9,3 +11,53 @@ const (
 	DOUBLE
 	BINARY
 )
+
+var metaTypeNames = map[MetaType]string{
+	STRING:  "STRING",
+	INTEGER: "INTEGER",
+	BOOLEAN: "BOOLEAN",
+	DOUBLE:  "DOUBLE",
+	BINARY:  "BINARY",
+}
+
+func (t MetaType) String() string {
+	if name, ok := metaTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("MetaType(%d)", int(t))
+}
+
+func (t MetaType) MarshalText() ([]byte, error) {
+	name, ok := metaTypeNames[t]
+	if !ok {
+		return nil, fmt.Errorf("unknown metadata type %d", int(t))
+	}
+	return []byte(name), nil
+}
+
+func (t *MetaType) UnmarshalText(text []byte) error {
+	for k, v := range metaTypeNames {
+		if v == string(text) {
+			*t = k
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown metadata type %s", string(text))
+}
+
+// MetaTypeOf returns the metadata type of a supported value
+func MetaTypeOf(value any) (MetaType, bool) {
+	switch value.(type) {
+	case string:
+		return STRING, true
+	case int, int32, int64:
+		return INTEGER, true
+	case bool:
+		return BOOLEAN, true
+	case float32, float64:
+		return DOUBLE, true
+	case []byte:
+		return BINARY, true
+	}
+	return STRING, false
+}
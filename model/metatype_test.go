@@ -0,0 +1,44 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMetaType_Text(t *testing.T) {
+	for _, v := range []MetaType{STRING, INTEGER, BOOLEAN, DOUBLE, BINARY} {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded MetaType
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if decoded != v {
+			t.Errorf("expect %s, got:%s", v, decoded)
+		}
+	}
+	var decoded MetaType
+	if err := json.Unmarshal([]byte(`"UNKNOWN"`), &decoded); err == nil {
+		t.Error("expected error for unknown type, but got nil")
+	}
+}
+
+func TestMetaTypeOf(t *testing.T) {
+	cases := map[MetaType]any{
+		STRING:  "value",
+		INTEGER: int64(1),
+		BOOLEAN: true,
+		DOUBLE:  1.5,
+		BINARY:  []byte("value"),
+	}
+	for expected, value := range cases {
+		if got, ok := MetaTypeOf(value); !ok || got != expected {
+			t.Errorf("expect %s for %T, got:%s", expected, value, got)
+		}
+	}
+	if _, ok := MetaTypeOf(struct{}{}); ok {
+		t.Error("expect unsupported type")
+	}
+}
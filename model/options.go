@@ -1,5 +1,11 @@
 package model
 
+import (
+	"time"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+)
+
 type Options struct {
 	SessionTableName          string
 	MessageTableName          string
@@ -7,6 +13,50 @@ type Options struct {
 	SessionSearchIndexName    string
 	MessageSecondaryIndexName string
 	MessageSearchIndexName    string
+	MemoryTableName           string
+	MemorySearchIndexName     string
+	MessageHistoryTableName   string
+	SessionTableOptions       TableOptions
+	MessageTableOptions       TableOptions
+	MemoryTableOptions        TableOptions
+	// Embedder computes message and session embeddings on put, nil disables vector search
+	Embedder Embedder
+	// EmbeddingMetric similarity metric of the vector fields, defaults to cosine
+	EmbeddingMetric tablestore.VectorMetricType
+	// SessionIndexFields metadata columns indexed by the session search index for filtering and aggregation
+	SessionIndexFields []IndexField
+	// MessageIndexFields metadata columns indexed by the message search index for filtering
+	MessageIndexFields []IndexField
+	// SessionAnalyzer, MessageAnalyzer and MemoryAnalyzer tokenizers of the search_content and memory content
+	// fields, changing them requires recreating the search indexes
+	SessionAnalyzer AnalyzerOptions
+	MessageAnalyzer AnalyzerOptions
+	MemoryAnalyzer  AnalyzerOptions
+	// WriteLogTTL and WriteLogSize bound the in-process log of written rows used by ConsistencyMergeWrites
+	WriteLogTTL  time.Duration
+	WriteLogSize int
+	// Highlight highlighting of SearchSessions and SearchMessages keyword matches
+	Highlight HighlightOptions
+	// ReadyTimeout makes InitTable wait until tables and indexes are usable, 0 means do not wait
+	ReadyTimeout time.Duration
+	// ReadyPollInterval interval between readiness checks
+	ReadyPollInterval time.Duration
+	// ReadyCallback receives readiness progress while waiting
+	ReadyCallback ReadyCallback
+	// MessageHistory makes UpdateMessage keep the replaced version of a message in the history table
+	MessageHistory bool
+	// SoftDelete makes DeleteSession, DeleteMessage and DeleteSessionAndMessages move rows to the trash
+	SoftDelete bool
+	// TrashRetention grace period before PurgeTrash hard deletes trashed rows
+	TrashRetention time.Duration
+	// IncludeArchivedMessages makes listing and search APIs return messages archived by compaction
+	IncludeArchivedMessages bool
+	// WarningHandler receives problems that do not fail an API, such as an index needing a migration,
+	// nil logs them
+	WarningHandler func(err error)
+	// StreamFlushInterval and StreamFlushBytes bound the partial content AppendChunk keeps unwritten
+	StreamFlushInterval time.Duration
+	StreamFlushBytes    int
 }
 
 type Option func(*Options)
@@ -46,3 +96,169 @@ func WithMessageSearchIndexName(name string) Option {
 		o.MessageSearchIndexName = name
 	}
 }
+
+func WithMemoryTableName(name string) Option {
+	return func(o *Options) {
+		o.MemoryTableName = name
+	}
+}
+
+func WithMessageHistoryTableName(name string) Option {
+	return func(o *Options) {
+		o.MessageHistoryTableName = name
+	}
+}
+
+func WithMemorySearchIndexName(name string) Option {
+	return func(o *Options) {
+		o.MemorySearchIndexName = name
+	}
+}
+
+func WithSessionTableOptions(opts TableOptions) Option {
+	return func(o *Options) {
+		o.SessionTableOptions = opts
+	}
+}
+
+func WithMessageTableOptions(opts TableOptions) Option {
+	return func(o *Options) {
+		o.MessageTableOptions = opts
+	}
+}
+
+func WithMemoryTableOptions(opts TableOptions) Option {
+	return func(o *Options) {
+		o.MemoryTableOptions = opts
+	}
+}
+
+// WithTableOptions applies the same provisioning settings to session, message and memory tables
+func WithTableOptions(opts TableOptions) Option {
+	return func(o *Options) {
+		o.SessionTableOptions = opts
+		o.MessageTableOptions = opts
+		o.MemoryTableOptions = opts
+	}
+}
+
+// WithWaitReady makes InitTable block until tables and indexes are ready or timeout
+func WithWaitReady(timeout time.Duration, callback ReadyCallback) Option {
+	return func(o *Options) {
+		o.ReadyTimeout = timeout
+		o.ReadyCallback = callback
+	}
+}
+
+func WithReadyPollInterval(interval time.Duration) Option {
+	return func(o *Options) {
+		o.ReadyPollInterval = interval
+	}
+}
+
+// WithEmbedder enables embeddings on put and vector fields in the session and message search indexes.
+// Search indexes created without an embedder have to be recreated to add the vector field.
+func WithEmbedder(embedder Embedder, metric tablestore.VectorMetricType) Option {
+	return func(o *Options) {
+		o.Embedder = embedder
+		o.EmbeddingMetric = metric
+	}
+}
+
+// WithSessionIndexFields indexes session metadata columns so searches can filter and aggregate on them
+func WithSessionIndexFields(fields ...IndexField) Option {
+	return func(o *Options) {
+		o.SessionIndexFields = append(o.SessionIndexFields, fields...)
+	}
+}
+
+// WithMessageIndexFields indexes message metadata columns so searches can filter on them
+func WithMessageIndexFields(fields ...IndexField) Option {
+	return func(o *Options) {
+		o.MessageIndexFields = append(o.MessageIndexFields, fields...)
+	}
+}
+
+// WithHighlight configures highlighted fragments of keyword searches
+func WithHighlight(opts HighlightOptions) Option {
+	return func(o *Options) {
+		o.Highlight = opts
+	}
+}
+
+func WithSessionAnalyzer(opts AnalyzerOptions) Option {
+	return func(o *Options) {
+		o.SessionAnalyzer = opts
+	}
+}
+
+func WithMessageAnalyzer(opts AnalyzerOptions) Option {
+	return func(o *Options) {
+		o.MessageAnalyzer = opts
+	}
+}
+
+func WithMemoryAnalyzer(opts AnalyzerOptions) Option {
+	return func(o *Options) {
+		o.MemoryAnalyzer = opts
+	}
+}
+
+// WithAnalyzer applies the same analyzer to session, message and memory search indexes
+func WithAnalyzer(opts AnalyzerOptions) Option {
+	return func(o *Options) {
+		o.SessionAnalyzer = opts
+		o.MessageAnalyzer = opts
+		o.MemoryAnalyzer = opts
+	}
+}
+
+// WithWriteLog bounds the in-process log of rows written through the store, used by read-your-writes searches
+func WithWriteLog(ttl time.Duration, size int) Option {
+	return func(o *Options) {
+		o.WriteLogTTL = ttl
+		o.WriteLogSize = size
+	}
+}
+
+// WithSoftDelete enables soft delete, trashed rows are purged by PurgeTrash after retention, 0 means
+// DefaultTrashRetention. Searches exclude trashed rows through the deleted_at search index field, so
+// search indexes created before need recreating.
+func WithSoftDelete(retention time.Duration) Option {
+	return func(o *Options) {
+		o.SoftDelete = true
+		o.TrashRetention = retention
+	}
+}
+
+// WithMessageHistory keeps every version of a message replaced by UpdateMessage in the message history
+// table, InitTable creates the table
+func WithMessageHistory() Option {
+	return func(o *Options) {
+		o.MessageHistory = true
+	}
+}
+
+// WithWarningHandler sets the handler of problems that do not fail an API, they are logged by default
+func WithWarningHandler(handler func(err error)) Option {
+	return func(o *Options) {
+		o.WarningHandler = handler
+	}
+}
+
+// WithArchivedMessages makes listing and search APIs return the messages archived by compaction next to
+// their summary, by default they are skipped
+func WithArchivedMessages() Option {
+	return func(o *Options) {
+		o.IncludeArchivedMessages = true
+	}
+}
+
+// WithStreamFlush sets how often AppendChunk writes partial content, after interval or once bytes
+// are pending, 0 means the defaults
+func WithStreamFlush(interval time.Duration, bytes int) Option {
+	return func(o *Options) {
+		o.StreamFlushInterval = interval
+		o.StreamFlushBytes = bytes
+	}
+}
@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// ReadyProgress reports the readiness of a table or index while InitTable waits for it
+type ReadyProgress struct {
+	// TableName table being waited on
+	TableName string `json:"table_name,omitempty"`
+	// IndexName secondary or search index being waited on, empty for the table itself
+	IndexName string `json:"index_name,omitempty"`
+	// Phase current state reported by tablestore, e.g. LOADING, FULL, INCR
+	Phase string `json:"phase,omitempty"`
+	// Ready whether the table or index is usable
+	Ready bool `json:"ready,omitempty"`
+	// Elapsed time spent waiting so far
+	Elapsed time.Duration `json:"elapsed,omitempty"`
+}
+
+// ReadyCallback receives progress while waiting for tables and indexes
+type ReadyCallback func(progress ReadyProgress)
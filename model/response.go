@@ -13,5 +13,20 @@ type Response[T any] struct {
 	NextStartPrimaryKey *tablestore.PrimaryKey `json:"next_start_primary_key,omitempty"`
 	// NextToken indicates the starting position for the next page (for search)
 	NextToken []byte `json:"next_token,omitempty"`
+	// Details relevance of search results, aligned with Hits by index
+	Details []HitDetail `json:"details,omitempty"`
+}
+
+// SearchHits returns hits wrapped with their relevance details
+func (r *Response[T]) SearchHits() []SearchHit[T] {
+	ret := make([]SearchHit[T], 0, len(r.Hits))
+	for idx, hit := range r.Hits {
+		item := SearchHit[T]{Hit: hit}
+		if idx < len(r.Details) {
+			item.HitDetail = r.Details[idx]
+		}
+		ret = append(ret, item)
+	}
+	return ret
 }
 
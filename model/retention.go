@@ -0,0 +1,55 @@
+package model
+
+import "time"
+
+// RetentionOptions controls which messages a retention sweep deletes and how fast
+type RetentionOptions struct {
+	// MaxAge messages created before now minus MaxAge are deleted
+	MaxAge time.Duration `json:"max_age,omitempty"`
+	// Before exclusive create time cutoff in microseconds, takes precedence over MaxAge
+	Before int64 `json:"before,omitempty"`
+	// SessionID restricts the sweep to one session, empty means all sessions
+	SessionID string `json:"session_id,omitempty"`
+	// RowsPerSecond max rows deleted per second, 0 means unlimited
+	RowsPerSecond int `json:"rows_per_second,omitempty"`
+	// BatchSize rows per batch write request, capped at 200
+	BatchSize int `json:"batch_size,omitempty"`
+	// DryRun only counts matching messages without deleting them
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// Cutoff returns the exclusive create time cutoff in microseconds, 0 if none configured
+func (o RetentionOptions) Cutoff() int64 {
+	if o.Before > 0 {
+		return o.Before
+	}
+	if o.MaxAge > 0 {
+		return CurrentTimeMicroseconds() - o.MaxAge.Microseconds()
+	}
+	return 0
+}
+
+// RetentionReport summarizes a retention sweep
+type RetentionReport struct {
+	// Before exclusive create time cutoff used by the sweep
+	Before int64 `json:"before,omitempty"`
+	// DryRun whether messages were only counted
+	DryRun bool `json:"dry_run,omitempty"`
+	// Deleted total messages deleted, or matched in dry run mode
+	Deleted int `json:"deleted,omitempty"`
+	// Sessions messages deleted per session
+	Sessions map[string]int `json:"sessions,omitempty"`
+	// StartTime sweep start time in microseconds
+	StartTime int64 `json:"start_time,omitempty"`
+	// EndTime sweep end time in microseconds
+	EndTime int64 `json:"end_time,omitempty"`
+}
+
+// Add records n deleted messages for a session
+func (r *RetentionReport) Add(sessionID string, n int) {
+	if r.Sessions == nil {
+		r.Sessions = make(map[string]int)
+	}
+	r.Sessions[sessionID] += n
+	r.Deleted += n
+}
@@ -0,0 +1,33 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetentionOptions_Cutoff(t *testing.T) {
+	if cutoff := (RetentionOptions{}).Cutoff(); cutoff != 0 {
+		t.Errorf("expect cutoff:0, got:%d", cutoff)
+	}
+	if cutoff := (RetentionOptions{Before: 123, MaxAge: time.Hour}).Cutoff(); cutoff != 123 {
+		t.Errorf("expect cutoff:123, got:%d", cutoff)
+	}
+	expected := CurrentTimeMicroseconds() - time.Hour.Microseconds()
+	cutoff := (RetentionOptions{MaxAge: time.Hour}).Cutoff()
+	if diff := cutoff - expected; diff < 0 || diff >= 100_000 {
+		t.Errorf("cutoff diff too large: %d", diff)
+	}
+}
+
+func TestRetentionReport_Add(t *testing.T) {
+	var report RetentionReport
+	report.Add("session_1", 2)
+	report.Add("session_2", 1)
+	report.Add("session_1", 3)
+	if report.Deleted != 6 {
+		t.Errorf("expect deleted:6, got:%d", report.Deleted)
+	}
+	if report.Sessions["session_1"] != 5 {
+		t.Errorf("expect session_1 deleted:5, got:%d", report.Sessions["session_1"])
+	}
+}
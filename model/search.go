@@ -0,0 +1,293 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+)
+
+// QueryMode how a keyword is matched against search_content
+type QueryMode string
+
+const (
+	// QueryModeDefault uses the default of each search API, phrase for sessions and messages, match for memories
+	QueryModeDefault QueryMode = ""
+	// QueryModePhrase matches the analyzed keyword tokens in order
+	QueryModePhrase QueryMode = "phrase"
+	// QueryModeMatch matches any (or all with MatchOperatorAnd) analyzed keyword tokens
+	QueryModeMatch QueryMode = "match"
+	// QueryModePrefix matches terms starting with the keyword
+	QueryModePrefix QueryMode = "prefix"
+	// QueryModeWildcard matches terms against a pattern with * and ?
+	QueryModeWildcard QueryMode = "wildcard"
+	// QueryModeString parses the keyword as a query string, see ParseQueryString
+	QueryModeString QueryMode = "query_string"
+)
+
+// MatchOperator combines the tokens of a match query
+type MatchOperator string
+
+const (
+	MatchOperatorOr  MatchOperator = "or"
+	MatchOperatorAnd MatchOperator = "and"
+)
+
+// SortByScore sorts by relevance score
+const SortByScore = "_score"
+
+// SortField a search sorter, Field is SortByScore, a time field or an indexed metadata field
+type SortField struct {
+	Field string `json:"field"`
+	Desc  bool   `json:"desc,omitempty"`
+}
+
+func SortAsc(field string) SortField {
+	return SortField{Field: field}
+}
+
+func SortDesc(field string) SortField {
+	return SortField{Field: field, Desc: true}
+}
+
+// SearchOptions keyword matching and ordering of search APIs
+type SearchOptions struct {
+	Mode     QueryMode
+	Operator MatchOperator
+	// MinimumShouldMatch min tokens a match query requires with MatchOperatorOr, 0 means 1
+	MinimumShouldMatch int32
+	// Sort sorters applied in order, the primary key is always appended as tiebreaker so token
+	// pagination is stable. Empty sorts by score descending.
+	Sort []SortField
+	// Consistency read-your-writes behaviour, see WithConsistency
+	Consistency        Consistency
+	ConsistencyTimeout time.Duration
+}
+
+type SearchOption func(*SearchOptions)
+
+// WithQueryMode selects how the keyword is matched
+func WithQueryMode(mode QueryMode) SearchOption {
+	return func(o *SearchOptions) {
+		o.Mode = mode
+	}
+}
+
+// WithMatchOperator sets the operator of QueryModeMatch and of unprefixed QueryModeString clauses
+func WithMatchOperator(operator MatchOperator) SearchOption {
+	return func(o *SearchOptions) {
+		o.Operator = operator
+	}
+}
+
+func WithMinimumShouldMatch(n int32) SearchOption {
+	return func(o *SearchOptions) {
+		o.MinimumShouldMatch = n
+	}
+}
+
+// WithSort orders search results by fields, see SearchOptions.Sort
+func WithSort(fields ...SortField) SearchOption {
+	return func(o *SearchOptions) {
+		o.Sort = append(o.Sort, fields...)
+	}
+}
+
+// NewSearchOptions applies opts, defaultMode is used when no mode is selected
+func NewSearchOptions(defaultMode QueryMode, opts ...SearchOption) (*SearchOptions, error) {
+	ret := new(SearchOptions)
+	for _, opt := range opts {
+		opt(ret)
+	}
+	if ret.Mode == QueryModeDefault {
+		ret.Mode = defaultMode
+	}
+	if ret.Operator == "" {
+		ret.Operator = MatchOperatorOr
+	}
+	if ret.ConsistencyTimeout <= 0 {
+		ret.ConsistencyTimeout = DefaultConsistencyTimeout
+	}
+	if err := ret.Validate(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (o *SearchOptions) Validate() error {
+	switch o.Mode {
+	case QueryModePhrase, QueryModeMatch, QueryModePrefix, QueryModeWildcard, QueryModeString:
+	default:
+		return fmt.Errorf("unsupported query mode: %q", o.Mode)
+	}
+	switch o.Operator {
+	case MatchOperatorOr, MatchOperatorAnd:
+	default:
+		return fmt.Errorf("unsupported match operator: %q", o.Operator)
+	}
+	if o.MinimumShouldMatch < 0 {
+		return fmt.Errorf("invalid minimum should match: %d", o.MinimumShouldMatch)
+	}
+	switch o.Consistency {
+	case ConsistencyEventual, ConsistencyMergeWrites, ConsistencyWaitIndex:
+	default:
+		return fmt.Errorf("unsupported consistency: %d", o.Consistency)
+	}
+	for _, v := range o.Sort {
+		if v.Field == "" {
+			return errors.New("sort field is required")
+		}
+	}
+	return nil
+}
+
+// ClauseOccur how a query string clause contributes to the match
+type ClauseOccur int
+
+const (
+	// ClauseShould unprefixed clause, combined by the match operator
+	ClauseShould ClauseOccur = iota
+	// ClauseMust clause prefixed with +
+	ClauseMust
+	// ClauseMustNot clause prefixed with -
+	ClauseMustNot
+)
+
+// QueryClause a parsed query string clause, Mode is one of phrase, match, prefix and wildcard
+type QueryClause struct {
+	Occur ClauseOccur
+	Mode  QueryMode
+	Text  string
+}
+
+// ParseQueryString parses a simple query string. Clauses are separated by whitespace, "quoted text"
+// is a phrase, a term ending with a single * is a prefix, terms containing * or ? are wildcards and
+// other terms are matched. A leading + requires the clause and a leading - excludes it.
+func ParseQueryString(query string) ([]QueryClause, error) {
+	var (
+		ret   []QueryClause
+		runes = []rune(query)
+	)
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+		clause := QueryClause{Occur: ClauseShould}
+		switch runes[i] {
+		case '+':
+			clause.Occur = ClauseMust
+			i++
+		case '-':
+			clause.Occur = ClauseMustNot
+			i++
+		}
+		if i < len(runes) && runes[i] == '"' {
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("unterminated phrase in query string: %q", query)
+			}
+			clause.Mode = QueryModePhrase
+			clause.Text = strings.TrimSpace(string(runes[i+1 : end]))
+			i = end + 1
+		} else {
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) {
+				end++
+			}
+			clause.Text = string(runes[i:end])
+			i = end
+			switch {
+			case strings.HasSuffix(clause.Text, "*") && !strings.ContainsAny(strings.TrimSuffix(clause.Text, "*"), "*?"):
+				clause.Mode = QueryModePrefix
+				clause.Text = strings.TrimSuffix(clause.Text, "*")
+			case strings.ContainsAny(clause.Text, "*?"):
+				clause.Mode = QueryModeWildcard
+			default:
+				clause.Mode = QueryModeMatch
+			}
+		}
+		if clause.Text == "" {
+			continue
+		}
+		ret = append(ret, clause)
+	}
+	if len(ret) == 0 {
+		return nil, fmt.Errorf("empty query string: %q", query)
+	}
+	return ret, nil
+}
+
+// AnalyzerOptions tokenizer of a search_content field
+type AnalyzerOptions struct {
+	// Analyzer one of single_word, max_word, min_word, split and fuzzy, defaults to fuzzy
+	Analyzer tablestore.Analyzer
+	// CaseSensitive single_word analyzer only
+	CaseSensitive bool
+	// DelimitWord single_word analyzer only, splits letters from digits
+	DelimitWord bool
+	// Delimiter split analyzer only, defaults to whitespace
+	Delimiter string
+	// FuzzyMinChars and FuzzyMaxChars fuzzy analyzer only, default to 1 and 7
+	FuzzyMinChars int32
+	FuzzyMaxChars int32
+}
+
+func (o *AnalyzerOptions) Normalize() {
+	if o.Analyzer == "" {
+		o.Analyzer = tablestore.Analyzer_Fuzzy
+	}
+	if o.Analyzer == tablestore.Analyzer_Fuzzy {
+		if o.FuzzyMinChars <= 0 {
+			o.FuzzyMinChars = 1
+		}
+		if o.FuzzyMaxChars <= 0 {
+			o.FuzzyMaxChars = 7
+		}
+	}
+}
+
+func (o *AnalyzerOptions) Validate() error {
+	switch o.Analyzer {
+	case tablestore.Analyzer_SingleWord, tablestore.Analyzer_MaxWord, tablestore.Analyzer_MinWord, tablestore.Analyzer_Split:
+	case tablestore.Analyzer_Fuzzy:
+		if o.FuzzyMinChars > o.FuzzyMaxChars {
+			return fmt.Errorf("fuzzy analyzer min chars %d greater than max chars %d", o.FuzzyMinChars, o.FuzzyMaxChars)
+		}
+	default:
+		return fmt.Errorf("unsupported analyzer: %q", o.Analyzer)
+	}
+	return nil
+}
+
+// Parameter analyzer parameter of the search index field schema
+func (o *AnalyzerOptions) Parameter() any {
+	switch o.Analyzer {
+	case tablestore.Analyzer_SingleWord:
+		caseSensitive, delimitWord := o.CaseSensitive, o.DelimitWord
+		return tablestore.SingleWordAnalyzerParameter{
+			CaseSensitive: &caseSensitive,
+			DelimitWord:   &delimitWord,
+		}
+	case tablestore.Analyzer_Split:
+		if o.Delimiter == "" {
+			return nil
+		}
+		delimiter := o.Delimiter
+		return tablestore.SplitAnalyzerParameter{
+			Delimiter: &delimiter,
+		}
+	case tablestore.Analyzer_Fuzzy:
+		return tablestore.FuzzyAnalyzerParameter{
+			MinChars: o.FuzzyMinChars,
+			MaxChars: o.FuzzyMaxChars,
+		}
+	}
+	return nil
+}
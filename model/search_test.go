@@ -0,0 +1,94 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+)
+
+func TestParseQueryString(t *testing.T) {
+	clauses, err := ParseQueryString(`+reset "password link" -expired pass* in?oice  `)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []QueryClause{
+		{Occur: ClauseMust, Mode: QueryModeMatch, Text: "reset"},
+		{Occur: ClauseShould, Mode: QueryModePhrase, Text: "password link"},
+		{Occur: ClauseMustNot, Mode: QueryModeMatch, Text: "expired"},
+		{Occur: ClauseShould, Mode: QueryModePrefix, Text: "pass"},
+		{Occur: ClauseShould, Mode: QueryModeWildcard, Text: "in?oice"},
+	}
+	if !reflect.DeepEqual(clauses, expect) {
+		t.Errorf("unexpected clauses: %+v", clauses)
+	}
+	if _, err := ParseQueryString(`"unterminated`); err == nil {
+		t.Error("expect unterminated phrase error")
+	}
+	if _, err := ParseQueryString(`  "" + `); err == nil {
+		t.Error("expect empty query string error")
+	}
+}
+
+func TestNewSearchOptions(t *testing.T) {
+	opts, err := NewSearchOptions(QueryModePhrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Mode != QueryModePhrase || opts.Operator != MatchOperatorOr {
+		t.Errorf("unexpected defaults: %+v", opts)
+	}
+	opts, err = NewSearchOptions(QueryModePhrase, WithQueryMode(QueryModeMatch), WithMatchOperator(MatchOperatorAnd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Mode != QueryModeMatch || opts.Operator != MatchOperatorAnd {
+		t.Errorf("unexpected options: %+v", opts)
+	}
+	if _, err := NewSearchOptions(QueryModePhrase, WithQueryMode("regex")); err == nil {
+		t.Error("expect unsupported mode error")
+	}
+}
+
+func TestAnalyzerOptions(t *testing.T) {
+	var opts AnalyzerOptions
+	opts.Normalize()
+	if err := opts.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if param, ok := opts.Parameter().(tablestore.FuzzyAnalyzerParameter); !ok || param.MinChars != 1 || param.MaxChars != 7 {
+		t.Errorf("unexpected default fuzzy parameter: %+v", opts.Parameter())
+	}
+	opts = AnalyzerOptions{Analyzer: tablestore.Analyzer_SingleWord, DelimitWord: true}
+	opts.Normalize()
+	param, ok := opts.Parameter().(tablestore.SingleWordAnalyzerParameter)
+	if !ok || *param.CaseSensitive || !*param.DelimitWord {
+		t.Errorf("unexpected single word parameter: %+v", opts.Parameter())
+	}
+	opts = AnalyzerOptions{Analyzer: tablestore.Analyzer_MaxWord}
+	if opts.Parameter() != nil {
+		t.Error("expect no parameter for max word analyzer")
+	}
+	opts = AnalyzerOptions{Analyzer: "ngram"}
+	if err := opts.Validate(); err == nil {
+		t.Error("expect unsupported analyzer error")
+	}
+	opts = AnalyzerOptions{Analyzer: tablestore.Analyzer_Fuzzy, FuzzyMinChars: 5, FuzzyMaxChars: 2}
+	if err := opts.Validate(); err == nil {
+		t.Error("expect invalid fuzzy range error")
+	}
+}
+
+func TestSearchOptionsSort(t *testing.T) {
+	opts, err := NewSearchOptions(QueryModePhrase, WithSort(SortDesc("create_time")), WithSort(SortAsc(SortByScore)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []SortField{{Field: "create_time", Desc: true}, {Field: SortByScore}}
+	if !reflect.DeepEqual(opts.Sort, expect) {
+		t.Errorf("unexpected sort: %+v", opts.Sort)
+	}
+	if _, err := NewSearchOptions(QueryModePhrase, WithSort(SortField{Desc: true})); err == nil {
+		t.Error("expect empty sort field error")
+	}
+}
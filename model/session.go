@@ -1,5 +1,28 @@
 package model
 
+import (
+	"fmt"
+	"slices"
+)
+
+// SessionStatus lifecycle status of a session
+type SessionStatus string
+
+const (
+	SessionStatusActive   SessionStatus = "active"
+	SessionStatusArchived SessionStatus = "archived"
+	SessionStatusDeleted  SessionStatus = "deleted"
+)
+
+// Validate checks the status is a known one
+func (s SessionStatus) Validate() error {
+	switch s {
+	case SessionStatusActive, SessionStatusArchived, SessionStatusDeleted:
+		return nil
+	}
+	return fmt.Errorf("invalid session status: %q", s)
+}
+
 // --------------------
 // Session
 // --------------------
@@ -8,9 +31,25 @@ type Session struct {
 	UserID    string `json:"user_id,omitempty"`
 	SessionID string `json:"session_id,omitempty"`
 
+	Title string `json:"title,omitempty"`
+	// CreateTime creation time in microseconds, PutSession defaults it to the update time
+	CreateTime int64 `json:"create_time,omitempty"`
+	// Status lifecycle status, sessions written before it existed have none and count as active
+	Status SessionStatus `json:"status,omitempty"`
+	// Pinned sessions are listed first by ListRecentSessions with WithPinnedFirst, UpdateSession keeps it
+	// and PinSession changes it
+	Pinned bool `json:"pinned,omitempty"`
+	// DeletedAt time the session was moved to the trash in microseconds, 0 if not deleted
+	DeletedAt int64 `json:"deleted_at,omitempty"`
+	// ActiveBranchID branch whose latest message is the leaf of the active path, empty for the main branch,
+	// UpdateSession keeps it and SwitchBranch changes it
+	ActiveBranchID string `json:"active_branch_id,omitempty"`
+
 	UpdateTime    int64    `json:"update_time,omitempty"`
 	Metadata      Metadata `json:"metadata,omitempty"`
 	SearchContent string   `json:"search_content,omitempty"`
+	// Embedding vector of the search content, computed on put when an Embedder is configured
+	Embedding []float32 `json:"embedding,omitempty"`
 }
 
 // --------------------
@@ -55,12 +94,38 @@ func (s *Session) Clone() *Session {
 	}
 	cp := *s
 	cp.Metadata = s.Metadata.Copy()
+	cp.Embedding = slices.Clone(s.Embedding)
 	return &cp
 }
 
 // --------------------
 // fluent setters
 // --------------------
+func (s *Session) SetTitle(title string) *Session {
+	s.Title = title
+	return s
+}
+
+func (s *Session) SetCreateTime(t int64) *Session {
+	s.CreateTime = t
+	return s
+}
+
+func (s *Session) SetStatus(status SessionStatus) *Session {
+	s.Status = status
+	return s
+}
+
+func (s *Session) SetPinned(pinned bool) *Session {
+	s.Pinned = pinned
+	return s
+}
+
+func (s *Session) SetActiveBranchID(branchID string) *Session {
+	s.ActiveBranchID = branchID
+	return s
+}
+
 func (s *Session) SetUpdateTime(t int64) *Session {
 	s.UpdateTime = t
 	return s
@@ -83,7 +148,59 @@ func (s *Session) SetSearchContent(content string) *Session {
 // behavior
 // --------------------
 
+// IsActive reports whether the session is active, a session without status counts as active
+func (s *Session) IsActive() bool {
+	return s.Status == "" || s.Status == SessionStatusActive
+}
+
+// IsDeleted reports whether the session is in the trash
+func (s *Session) IsDeleted() bool {
+	return s.DeletedAt > 0
+}
+
 // RefreshUpdateTime updates the update time to now (microseconds)
 func (s *Session) RefreshUpdateTime() {
 	s.UpdateTime = CurrentTimeMicroseconds()
 }
+
+// --------------------
+// list options
+// --------------------
+
+// SessionListOptions options of the recent session list APIs
+type SessionListOptions struct {
+	// Statuses only lists sessions in one of the statuses, empty lists all
+	Statuses []SessionStatus
+	// PinnedFirst lists pinned sessions before the others, each group by update time descending
+	PinnedFirst bool
+}
+
+type SessionListOption func(*SessionListOptions)
+
+// WithSessionStatus only lists sessions in one of the statuses
+func WithSessionStatus(statuses ...SessionStatus) SessionListOption {
+	return func(o *SessionListOptions) {
+		o.Statuses = append(o.Statuses, statuses...)
+	}
+}
+
+// WithPinnedFirst lists pinned sessions before the others
+func WithPinnedFirst() SessionListOption {
+	return func(o *SessionListOptions) {
+		o.PinnedFirst = true
+	}
+}
+
+// NewSessionListOptions applies opts and validates the result
+func NewSessionListOptions(opts ...SessionListOption) (*SessionListOptions, error) {
+	ret := new(SessionListOptions)
+	for _, opt := range opts {
+		opt(ret)
+	}
+	for _, v := range ret.Statuses {
+		if err := v.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
@@ -36,3 +36,33 @@ func TestSession_Simple(t *testing.T) {
 		t.Fatalf("sessions not equal:\n%+v\n%+v", sessionCopy, sessionSimple3)
 	}
 }
+
+func TestSession_Lifecycle(t *testing.T) {
+	session := NewSessionWithTime("user_1", "session_1", 100)
+	if session.CreateTime != 0 || session.Status != "" || session.Pinned {
+		t.Fatalf("expect constructor to leave lifecycle fields unset: %+v", session)
+	}
+	if !session.IsActive() {
+		t.Error("expect session without status active")
+	}
+	session.SetTitle("title").SetPinned(true).SetStatus(SessionStatusArchived)
+	if session.IsActive() {
+		t.Error("expect archived session not active")
+	}
+	if err := SessionStatus("closed").Validate(); err == nil {
+		t.Error("expect invalid status error")
+	}
+}
+
+func TestNewSessionListOptions(t *testing.T) {
+	opts, err := NewSessionListOptions(WithSessionStatus(SessionStatusActive), WithSessionStatus(SessionStatusArchived), WithPinnedFirst())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(opts.Statuses, []SessionStatus{SessionStatusActive, SessionStatusArchived}) || !opts.PinnedFirst {
+		t.Errorf("unexpected options: %+v", opts)
+	}
+	if _, err := NewSessionListOptions(WithSessionStatus("closed")); err == nil {
+		t.Error("expect invalid status error")
+	}
+}
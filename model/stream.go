@@ -0,0 +1,41 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// StreamStatus progress of a message written by a stream
+type StreamStatus string
+
+const (
+	// StreamStatusStreaming the content is still being appended
+	StreamStatusStreaming StreamStatus = "streaming"
+	// StreamStatusComplete the stream finished, the content is final
+	StreamStatusComplete StreamStatus = "complete"
+	// StreamStatusAborted the stream was abandoned, the content is partial
+	StreamStatusAborted StreamStatus = "aborted"
+)
+
+const (
+	// DefaultStreamFlushInterval max time partial content stays unwritten
+	DefaultStreamFlushInterval = time.Second
+	// DefaultStreamFlushBytes max bytes of partial content kept unwritten
+	DefaultStreamFlushBytes = 4096
+	// DefaultStreamStaleAfter time without flush after which a stream counts as abandoned
+	DefaultStreamStaleAfter = 5 * time.Minute
+)
+
+// Validate checks the status is a known one
+func (s StreamStatus) Validate() error {
+	switch s {
+	case StreamStatusStreaming, StreamStatusComplete, StreamStatusAborted:
+		return nil
+	}
+	return fmt.Errorf("invalid stream status: %q", s)
+}
+
+// IsStreaming reports whether the message content is still being appended
+func (m *Message) IsStreaming() bool {
+	return m.StreamStatus == StreamStatusStreaming
+}
@@ -0,0 +1,22 @@
+package model
+
+import "testing"
+
+func TestStreamStatus_Validate(t *testing.T) {
+	for _, status := range []StreamStatus{StreamStatusStreaming, StreamStatusComplete, StreamStatusAborted} {
+		if err := status.Validate(); err != nil {
+			t.Errorf("expect %s valid, got:%v", status, err)
+		}
+	}
+	if err := StreamStatus("paused").Validate(); err == nil {
+		t.Error("expect unknown status invalid")
+	}
+	message := NewMessage("session_1", "message_1")
+	if message.IsStreaming() {
+		t.Error("expect new message not streaming")
+	}
+	message.StreamStatus = StreamStatusStreaming
+	if !message.IsStreaming() {
+		t.Error("expect message streaming")
+	}
+}
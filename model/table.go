@@ -0,0 +1,63 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+)
+
+// TableOptions provisioning settings applied by InitTable. Zero values are left out when InitTable
+// reconciles an existing table, so settings tuned outside the library are kept.
+type TableOptions struct {
+	// TimeToAlive data time to live in seconds, -1 means never expire
+	TimeToAlive int `json:"time_to_alive,omitempty"`
+	// MaxVersion max versions kept for each attribute column
+	MaxVersion int `json:"max_version,omitempty"`
+	// DeviationCellVersionInSec max deviation between written version and current time in seconds, 0 uses server default
+	DeviationCellVersionInSec int64 `json:"deviation_cell_version_in_sec,omitempty"`
+	// ReservedReadCU reserved read capacity units, reconciled when either reserved CU is set
+	ReservedReadCU int `json:"reserved_read_cu,omitempty"`
+	// ReservedWriteCU reserved write capacity units, reconciled when either reserved CU is set
+	ReservedWriteCU int `json:"reserved_write_cu,omitempty"`
+	// SSE server side encryption, can only be set when the table is created
+	SSE *tablestore.SSESpecification `json:"sse,omitempty"`
+	// DisableUpdate rejects row updates on the table, required by SearchIndexTTL. APIs updating rows in
+	// place, such as UpdateMessage or soft delete, fail on such a table.
+	DisableUpdate bool `json:"disable_update,omitempty"`
+	// SearchIndexTTL search index time to live in seconds, -1 means never expire.
+	// It must not exceed TimeToAlive and requires DisableUpdate.
+	SearchIndexTTL int32 `json:"search_index_ttl,omitempty"`
+}
+
+// DefaultTableOptions never expire, single version and no reserved throughput
+func DefaultTableOptions() TableOptions {
+	return TableOptions{
+		TimeToAlive:    -1,
+		MaxVersion:     1,
+		SearchIndexTTL: -1,
+	}
+}
+
+// Validate checks the options can be applied together
+func (o TableOptions) Validate() error {
+	if o.SearchIndexTTL > 0 && !o.DisableUpdate {
+		return errors.New("search index ttl requires updates to be disabled on the table")
+	}
+	if o.SearchIndexTTL > 0 && o.TimeToAlive > 0 && int(o.SearchIndexTTL) > o.TimeToAlive {
+		return errors.New("search index ttl exceeds table time to live")
+	}
+	return nil
+}
+
+// Normalize fills zero values with defaults
+func (o *TableOptions) Normalize() {
+	if o.TimeToAlive == 0 {
+		o.TimeToAlive = -1
+	}
+	if o.MaxVersion <= 0 {
+		o.MaxVersion = 1
+	}
+	if o.SearchIndexTTL == 0 {
+		o.SearchIndexTTL = -1
+	}
+}
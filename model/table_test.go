@@ -0,0 +1,42 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTableOptions_Normalize(t *testing.T) {
+	var opts TableOptions
+	opts.Normalize()
+	if !reflect.DeepEqual(opts, DefaultTableOptions()) {
+		t.Fatalf("table options not equal:\n%+v\n%+v", opts, DefaultTableOptions())
+	}
+
+	opts = TableOptions{
+		TimeToAlive:     86400,
+		MaxVersion:      3,
+		ReservedReadCU:  1,
+		ReservedWriteCU: 2,
+		SearchIndexTTL:  3600,
+	}
+	expected := opts
+	opts.Normalize()
+	if !reflect.DeepEqual(opts, expected) {
+		t.Fatalf("table options not equal:\n%+v\n%+v", opts, expected)
+	}
+}
+
+func TestTableOptions_Validate(t *testing.T) {
+	if err := (TableOptions{SearchIndexTTL: 3600}).Validate(); err == nil {
+		t.Error("expect search index ttl without disabled updates invalid")
+	}
+	if err := (TableOptions{SearchIndexTTL: 3600, DisableUpdate: true, TimeToAlive: 60}).Validate(); err == nil {
+		t.Error("expect search index ttl above table ttl invalid")
+	}
+	if err := (TableOptions{SearchIndexTTL: 3600, DisableUpdate: true, TimeToAlive: 86400}).Validate(); err != nil {
+		t.Errorf("expect options valid, got:%v", err)
+	}
+	if err := DefaultTableOptions().Validate(); err != nil {
+		t.Errorf("expect default options valid, got:%v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package model
+
+import "time"
+
+// DefaultTrashRetention grace period before trashed sessions and messages are purged
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+// Trash soft deleted sessions and messages of a user
+type Trash struct {
+	Sessions []Session `json:"sessions,omitempty"`
+	Messages []Message `json:"messages,omitempty"`
+}
+
+// PurgeOptions controls which trashed rows a purge hard deletes and how fast
+type PurgeOptions struct {
+	// Before exclusive deleted at cutoff in microseconds, 0 means now minus the store trash retention
+	Before int64 `json:"before,omitempty"`
+	// RowsPerSecond max rows deleted per second, 0 means unlimited
+	RowsPerSecond int `json:"rows_per_second,omitempty"`
+	// BatchSize rows per batch write request, capped at 200
+	BatchSize int `json:"batch_size,omitempty"`
+	// DryRun only counts purgeable rows without deleting them
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// Cutoff returns the exclusive deleted at cutoff in microseconds given the store trash retention
+func (o PurgeOptions) Cutoff(retention time.Duration) int64 {
+	if o.Before > 0 {
+		return o.Before
+	}
+	if retention <= 0 {
+		retention = DefaultTrashRetention
+	}
+	return CurrentTimeMicroseconds() - retention.Microseconds()
+}
+
+// PurgeReport summarizes a trash purge
+type PurgeReport struct {
+	// Before exclusive deleted at cutoff used by the purge
+	Before int64 `json:"before,omitempty"`
+	// DryRun whether rows were only counted
+	DryRun bool `json:"dry_run,omitempty"`
+	// Sessions sessions purged, or matched in dry run mode
+	Sessions int `json:"sessions,omitempty"`
+	// Messages messages purged, or matched in dry run mode
+	Messages int `json:"messages,omitempty"`
+	// StartTime purge start time in microseconds
+	StartTime int64 `json:"start_time,omitempty"`
+	// EndTime purge end time in microseconds
+	EndTime int64 `json:"end_time,omitempty"`
+}
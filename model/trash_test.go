@@ -0,0 +1,35 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPurgeOptions_Cutoff(t *testing.T) {
+	if cutoff := (PurgeOptions{Before: 123}).Cutoff(time.Hour); cutoff != 123 {
+		t.Errorf("expect cutoff:123, got:%d", cutoff)
+	}
+	expected := CurrentTimeMicroseconds() - time.Hour.Microseconds()
+	cutoff := (PurgeOptions{}).Cutoff(time.Hour)
+	if diff := cutoff - expected; diff < 0 || diff >= 100_000 {
+		t.Errorf("cutoff diff too large: %d", diff)
+	}
+	expected = CurrentTimeMicroseconds() - DefaultTrashRetention.Microseconds()
+	cutoff = (PurgeOptions{}).Cutoff(0)
+	if diff := cutoff - expected; diff < 0 || diff >= 100_000 {
+		t.Errorf("default cutoff diff too large: %d", diff)
+	}
+}
+
+func TestSession_IsDeleted(t *testing.T) {
+	session := NewSession("user_1", "session_1")
+	message := NewMessage("session_1", "message_1")
+	if session.IsDeleted() || message.IsDeleted() {
+		t.Error("expect new session and message not deleted")
+	}
+	session.DeletedAt = CurrentTimeMicroseconds()
+	message.DeletedAt = session.DeletedAt
+	if !session.IsDeleted() || !message.IsDeleted() {
+		t.Error("expect session and message deleted")
+	}
+}
@@ -0,0 +1,37 @@
+package protocol
+
+import (
+	"github.com/bububa/tablestore-memory/model"
+)
+
+// KnowledgeStore defines the interface for document chunk persistence and retrieval.
+type KnowledgeStore interface {
+	// PutDocuments write document chunks, existing chunks are replaced
+	PutDocuments(documents []*model.Document) error
+
+	// ReplaceDocument write the chunks of a document and delete its other chunks
+	ReplaceDocument(tenantID string, documentID string, chunks []*model.Document) (int, error)
+
+	// GetDocument get a document chunk
+	GetDocument(document *model.Document) error
+
+	// ListDocumentChunks list the chunks of a document in order
+	ListDocumentChunks(tenantID string, documentID string) ([]model.Document, error)
+
+	// DeleteDocument delete all chunks of a document
+	DeleteDocument(tenantID string, documentID string) (int, error)
+
+	// SearchDocuments full text or vector search document chunks of a tenant
+	SearchDocuments(req *model.DocumentSearchRequest) (*model.Response[model.Document], error)
+
+	// <-------- Infra -------->
+
+	// InitTable create the knowledge table and search index if not exist
+	InitTable() error
+
+	// InitSearchIndex create the knowledge search index if not exist
+	InitSearchIndex() error
+
+	// DeleteTableAndIndex delete the knowledge search index and table
+	DeleteTableAndIndex() error
+}
@@ -1,6 +1,9 @@
 package protocol
 
 import (
+	"io"
+	"time"
+
 	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
 
 	"github.com/bububa/tablestore-memory/model"
@@ -13,21 +16,27 @@ type MemoryStore interface {
 	// PutSession insert (overwrite) a session
 	PutSession(session *model.Session) error
 
-	// UpdateSession update a session
+	// UpdateSession update a session, keeping its pin and active branch
 	UpdateSession(session *model.Session) error
 
-	// DeleteSession delete a session
+	// PinSession pin or unpin a session
+	PinSession(userID, sessionID string, pinned bool) error
+
+	// DeleteSession delete a session, or move it to the trash when soft delete is enabled
 	DeleteSession(userID, sessionID string) error
 
 	// DeleteSessions delete all sessions for a user
 	DeleteSessions(userID string) (int, error)
 
-	// DeleteSessionAndMessages delete a session and its messages
+	// DeleteSessionAndMessages delete a session and its messages, or move them to the trash when soft delete is enabled
 	DeleteSessionAndMessages(userID, sessionID string) error
 
 	// DeleteAllSessions delete all sessions for all users
 	DeleteAllSessions() (int, error)
 
+	// DeleteUserData erase all sessions and messages of a user, resumable with the returned report
+	DeleteUserData(userID string, checkpoint *model.ErasureReport) (*model.ErasureReport, error)
+
 	// GetSession get a session
 	GetSession(session *model.Session) error
 
@@ -42,7 +51,7 @@ type MemoryStore interface {
 		batchSize int,
 	) <-chan model.Session
 
-	// ListRecentSessions list recent sessions sorted by update time
+	// ListRecentSessions list recent sessions sorted by update time, opts filter by status and list pinned first
 	ListRecentSessions(
 		userID string,
 		filter tablestore.ColumnFilter,
@@ -50,6 +59,7 @@ type MemoryStore interface {
 		inclusiveEndUpdateTime int64,
 		maxCount int,
 		batchSize int,
+		opts ...model.SessionListOption,
 	) ([]model.Session, error)
 
 	// ListRecentSessionsPaginated paginated recent sessions
@@ -60,6 +70,7 @@ type MemoryStore interface {
 		inclusiveEndUpdateTime int64,
 		pageSize int,
 		nextStartPrimaryKey *tablestore.PrimaryKey,
+		opts ...model.SessionListOption,
 	) (*model.Response[model.Session], error)
 
 	SearchSessions(
@@ -69,8 +80,12 @@ type MemoryStore interface {
 		inclusiveEndUpdateTime int64,
 		pageSize int32,
 		nextToken []byte,
+		opts ...model.SearchOption,
 	) (*model.Response[model.Session], error)
 
+	// AggregateSessions computes count, metric, terms and histogram aggregations over indexed session fields
+	AggregateSessions(req model.AggregationRequest) (*model.AggregationResponse, error)
+
 	// <-------- Message related -------->
 
 	// PutMessage insert (overwrite) a message
@@ -79,7 +94,7 @@ type MemoryStore interface {
 	// UpdateMessage update a message
 	UpdateMessage(message *model.Message) error
 
-	// DeleteMessage delete a message
+	// DeleteMessage delete a message, or move it to the trash when soft delete is enabled
 	DeleteMessage(sessionID string, messageID string, createTime int64) error
 
 	// DeleteMessages delete all messages for a session
@@ -88,6 +103,12 @@ type MemoryStore interface {
 	// DeleteAllMessages delete all messages
 	DeleteAllMessages() (int, error)
 
+	// DeleteMessagesBefore delete messages of a session created before createTime
+	DeleteMessagesBefore(sessionID string, createTime int64) (int, error)
+
+	// SweepMessages delete messages older than the retention cutoff
+	SweepMessages(opts model.RetentionOptions) (*model.RetentionReport, error)
+
 	// GetMessage get a message
 	GetMessage(message *model.Message) error
 
@@ -126,16 +147,135 @@ type MemoryStore interface {
 		inclusiveEndCreateTime int64,
 		pageSize int32,
 		nextToken []byte,
+		opts ...model.SearchOption,
 	) (*model.Response[model.Message], error)
 
+	// SemanticSearchMessages KNN search messages of a session or of a user's sessions by embedding
+	SemanticSearchMessages(sessionID string, userID string, queryText string, k int32, filters ...model.MetadataFilter) (*model.Response[model.Message], error)
+	// HybridSearchMessages fuses full-text and vector search of messages, hits carry per retriever scores
+	HybridSearchMessages(req model.HybridSearchRequest) (*model.Response[model.HybridHit], error)
+	// AggregateMessages computes count, metric, terms and histogram aggregations over indexed message fields
+	AggregateMessages(req model.AggregationRequest) (*model.AggregationResponse, error)
+	// SearchUserMessages searches messages across all sessions of a user
+	SearchUserMessages(
+		userID string,
+		keyword string,
+		inclusiveStartCreateTime int64,
+		inclusiveEndCreateTime int64,
+		pageSize int32,
+		nextToken []byte,
+		opts ...model.SearchOption,
+	) (*model.Response[model.Message], error)
+	// BackfillMessageUserIDs stamps messages of the sessions of a user, or of all sessions, with the session user id
+	BackfillMessageUserIDs(userID string, rowsPerSecond int) (int, error)
+
+	// BuildContext select the latest messages of a session that fit in a token budget
+	BuildContext(sessionID string, budget int, opts ...model.ContextOption) (*model.ContextWindow, error)
+
+	// CompactMessages replace a range of messages with a summary message
+	CompactMessages(sessionID string, summarizer model.Summarizer, opts model.CompactionOptions) (*model.CompactionReport, error)
+
+	// ListSummarizedMessages list the remaining originals covered by a summary message
+	ListSummarizedMessages(summary *model.Message) ([]model.Message, error)
+
+	// <-------- Trash related -------->
+
+	// RestoreSession move a session and the messages trashed with it out of the trash
+	RestoreSession(userID, sessionID string) error
+
+	// RestoreMessage move a message out of the trash
+	RestoreMessage(sessionID string, messageID string, createTime int64) error
+
+	// ListTrash list trashed sessions and messages of a user
+	ListTrash(userID string) (*model.Trash, error)
+
+	// PurgeTrash hard delete sessions and messages trashed before the purge cutoff
+	PurgeTrash(opts model.PurgeOptions) (*model.PurgeReport, error)
+
+	// <-------- History related -------->
+
+	// ListMessageVersions list the edit history of a message, oldest first, ending with the current version
+	ListMessageVersions(sessionID string, messageID string) ([]model.MessageVersion, error)
+
+	// GetMessageAt read a message as it was at the given time
+	GetMessageAt(message *model.Message, at int64) error
+
+	// <-------- Stream related -------->
+
+	// BeginMessage write a message whose content is streamed by AppendChunk and made final by FinishMessage
+	BeginMessage(message *model.Message) error
+
+	// AppendChunk append a chunk to the content of a streaming message, flushing partial content periodically
+	AppendChunk(sessionID string, messageID string, chunk string) error
+
+	// FinishMessage write the full content of a streaming message, searchable from then on, and mark it complete
+	FinishMessage(sessionID string, messageID string) error
+
+	// AbortMessage write the partial content of a streaming message and mark it aborted
+	AbortMessage(sessionID string, messageID string) error
+
+	// AbortStaleStreams mark streaming messages without a write for staleAfter as aborted
+	AbortStaleStreams(sessionID string, staleAfter time.Duration) (int, error)
+
+	// <-------- Branch related -------->
+
+	// ListMessagePath list the messages from the root down to the leaf message
+	ListMessagePath(sessionID string, leafMessageID string) ([]model.Message, error)
+
+	// ListActivePath list the messages from the root down to the latest message of the active branch of a session
+	ListActivePath(userID string, sessionID string) ([]model.Message, error)
+
+	// ListMessageSiblings list the alternatives of a message sharing its parent, itself included
+	ListMessageSiblings(sessionID string, messageID string) ([]model.Message, error)
+
+	// SwitchBranch make a branch the active branch of a session
+	SwitchBranch(userID string, sessionID string, branchID string) error
+
+	// ForkSession copy the path from the root down to the leaf message into a new session
+	ForkSession(userID string, sessionID string, leafMessageID string, newSessionID string) (*model.Session, error)
+
+	// CloneSession copy a session and its messages to another session, possibly of another user
+	CloneSession(srcUserID string, srcSessionID string, dstUserID string, dstSessionID string, opts model.CloneOptions) (*model.CloneReport, error)
+
+	// <-------- Memory related -------->
+
+	// PutMemory store a long-term memory, merging it with an existing copy of the same fact
+	PutMemory(memory *model.Memory) error
+
+	// GetMemory get a memory
+	GetMemory(memory *model.Memory) error
+
+	// ListMemories list unexpired memories of a user, most important first
+	ListMemories(userID string) ([]model.Memory, error)
+
+	// SearchMemories full text search unexpired memories of a user
+	SearchMemories(userID string, keyword string, pageSize int32, nextToken []byte, opts ...model.SearchOption) (*model.Response[model.Memory], error)
+
+	// ForgetMemory delete a memory
+	ForgetMemory(userID string, memoryID string) error
+
+	// ForgetMemories delete all memories of a user
+	ForgetMemories(userID string) (int, error)
+
+	// <-------- Export -------->
+
+	// ExportUser write all sessions and messages of a user as JSONL records
+	ExportUser(userID string, w io.Writer) (*model.ExportReport, error)
+
+	// ExportSession write a session and its messages as JSONL records
+	ExportSession(userID string, sessionID string, w io.Writer) (*model.ExportReport, error)
+
+	// Import restore sessions and messages from JSONL records
+	Import(r io.Reader, policy model.ConflictPolicy) (*model.ImportReport, error)
+
 	// <-------- Infra -------->
 
 	// InitTable initialize table
 	InitTable() error
 
 	// InitSearchIndex initialize search index
-	// InitSearchIndex() error
+	InitSearchIndex() error
 
 	// DeleteTableAndIndex delete table and index
-	// DeleteTableAndIndex() error
+	DeleteTableAndIndex() error
 }
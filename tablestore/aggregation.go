@@ -0,0 +1,296 @@
+package tablestore
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore/search"
+	searchmodel "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore/search/model"
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/cast"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+// AggregateSessions computes aggregations over the sessions of req.UserID, or all sessions when empty,
+// whose update_time is within the request time range
+func (s *MemoryStore) AggregateSessions(req model.AggregationRequest) (*model.AggregationResponse, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if err := checkAggregationFields(req.Aggregations, s.sessionIndexedFields()); err != nil {
+		return nil, err
+	}
+	queries := make([]search.Query, 0, len(req.Filters)+2)
+	if req.UserID != "" {
+		queries = append(queries, &search.TermQuery{
+			FieldName: SessionUserIDField,
+			Term:      req.UserID,
+		})
+	}
+	if query := timeRangeQuery(SessionUpdateTimeField, req.StartTime, req.EndTime); query != nil {
+		queries = append(queries, query)
+	}
+	for _, v := range req.Filters {
+		queries = append(queries, metadataFilterQuery(v))
+	}
+	ret, err := s.aggregate(s.SessionTableName, s.SessionSearchIndexName, SessionDeletedAtField, queries, req.Aggregations)
+	if err != nil {
+		return nil, fmt.Errorf("aggregate sessions failed, %w", err)
+	}
+	return ret, nil
+}
+
+// AggregateMessages computes aggregations over the messages of req.SessionID, of the sessions of
+// req.UserID, or all messages when both are empty, whose create_time is within the request time range
+func (s *MemoryStore) AggregateMessages(req model.AggregationRequest) (*model.AggregationResponse, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if err := checkAggregationFields(req.Aggregations, s.messageIndexedFields()); err != nil {
+		return nil, err
+	}
+	queries := make([]search.Query, 0, len(req.Filters)+2)
+	if req.SessionID != "" || req.UserID != "" {
+		scope, err := s.messageScopeQuery(req.SessionID, req.UserID, req.Filters)
+		if err != nil {
+			return nil, fmt.Errorf("aggregate messages failed, %w", err)
+		}
+		queries = append(queries, scope)
+	} else {
+		for _, v := range req.Filters {
+			queries = append(queries, metadataFilterQuery(v))
+		}
+	}
+	if query := timeRangeQuery(MessageCreateTimeField, req.StartTime, req.EndTime); query != nil {
+		queries = append(queries, query)
+	}
+	ret, err := s.aggregate(s.MessageTableName, s.MessageSearchIndexName, MessageDeletedAtField, queries, req.Aggregations)
+	if err != nil {
+		return nil, fmt.Errorf("aggregate messages failed, %w", err)
+	}
+	return ret, nil
+}
+
+func (s *MemoryStore) aggregate(tableName string, indexName string, deletedAtField string, queries []search.Query, aggregations []model.Aggregation) (*model.AggregationResponse, error) {
+	searchQuery := search.NewSearchQuery()
+	if len(queries) == 0 {
+		searchQuery.SetQuery(s.excludeDeleted(&search.MatchAllQuery{}, deletedAtField))
+	} else {
+		searchQuery.SetQuery(s.excludeDeleted(&search.BoolQuery{
+			FilterQueries: queries,
+		}, deletedAtField))
+	}
+	metrics, groupBys := searchAggregations(aggregations)
+	if len(metrics) > 0 {
+		searchQuery.Aggregation(metrics...)
+	}
+	if len(groupBys) > 0 {
+		searchQuery.GroupBy(groupBys...)
+	}
+	searchQuery.SetLimit(0)
+	searchQuery.SetGetTotalCount(true)
+	searchReq := new(tablestore.SearchRequest)
+	searchReq.SetTableName(tableName)
+	searchReq.SetIndexName(indexName)
+	searchReq.SetSearchQuery(searchQuery)
+	resp, err := s.clt.Search(searchReq)
+	if err != nil {
+		return nil, err
+	}
+	results, err := parseAggregationResults(aggregations, resp.AggregationResults, resp.GroupByResults)
+	if err != nil {
+		return nil, err
+	}
+	return &model.AggregationResponse{
+		Total:   resp.TotalCount,
+		Results: results,
+	}, nil
+}
+
+// timeRangeQuery inclusive range of a time field, nil when both bounds are 0
+func timeRangeQuery(fieldName string, inclusiveStart int64, inclusiveEnd int64) search.Query {
+	if inclusiveStart <= 0 && inclusiveEnd <= 0 {
+		return nil
+	}
+	rangeQuery := &search.RangeQuery{
+		FieldName: fieldName,
+		From:      tablestore.MIN,
+		To:        tablestore.MAX,
+	}
+	if inclusiveStart > 0 {
+		rangeQuery.From = inclusiveStart
+		rangeQuery.IncludeLower = true
+	}
+	if inclusiveEnd > 0 {
+		rangeQuery.To = inclusiveEnd
+		rangeQuery.IncludeUpper = true
+	}
+	return rangeQuery
+}
+
+func checkAggregationFields(aggregations []model.Aggregation, indexed []string) error {
+	for _, v := range aggregations {
+		if !slices.Contains(indexed, v.Field) {
+			return fmt.Errorf("aggregation %s field %s is not indexed, indexed fields: %v", v.Name, v.Field, indexed)
+		}
+		if err := checkAggregationFields(v.SubAggregations, indexed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// searchAggregations splits aggregations into search index metric aggregations and group bys
+func searchAggregations(aggregations []model.Aggregation) ([]search.Aggregation, []search.GroupBy) {
+	var (
+		metrics  []search.Aggregation
+		groupBys []search.GroupBy
+	)
+	for _, v := range aggregations {
+		switch v.Type {
+		case model.AggregationCount:
+			metrics = append(metrics, &search.CountAggregation{AggName: v.Name, Field: v.Field})
+		case model.AggregationDistinctCount:
+			metrics = append(metrics, &search.DistinctCountAggregation{AggName: v.Name, Field: v.Field})
+		case model.AggregationMin:
+			metrics = append(metrics, &search.MinAggregation{AggName: v.Name, Field: v.Field})
+		case model.AggregationMax:
+			metrics = append(metrics, &search.MaxAggregation{AggName: v.Name, Field: v.Field})
+		case model.AggregationAvg:
+			metrics = append(metrics, &search.AvgAggregation{AggName: v.Name, Field: v.Field})
+		case model.AggregationSum:
+			metrics = append(metrics, &search.SumAggregation{AggName: v.Name, Field: v.Field})
+		case model.AggregationTerms:
+			size := v.Size
+			if size == 0 {
+				size = model.DefaultTermsSize
+			}
+			subMetrics, subGroupBys := searchAggregations(v.SubAggregations)
+			groupBys = append(groupBys, &search.GroupByField{
+				AggName:        v.Name,
+				Field:          v.Field,
+				Sz:             proto.Int32(size),
+				SubAggList:     subMetrics,
+				SubGroupByList: subGroupBys,
+			})
+		case model.AggregationHistogram:
+			subMetrics, subGroupBys := searchAggregations(v.SubAggregations)
+			groupBy := &search.GroupByHistogram{
+				GroupByName: v.Name,
+				Field:       v.Field,
+				Interval:    v.Interval,
+				FieldRange: searchmodel.FiledRange{
+					Min: v.From,
+					Max: v.To,
+				},
+				Sorters: []search.GroupBySorter{
+					&search.GroupKeyGroupBySort{Order: search.SortOrder_ASC.Enum()},
+				},
+				SubAggList:     subMetrics,
+				SubGroupByList: subGroupBys,
+			}
+			if v.MinCount > 0 {
+				groupBy.MinDocCount = proto.Int64(v.MinCount)
+			}
+			groupBys = append(groupBys, groupBy)
+		}
+	}
+	return metrics, groupBys
+}
+
+func parseAggregationResults(aggregations []model.Aggregation, metrics search.AggregationResults, groupBys search.GroupByResults) (map[string]model.AggregationResult, error) {
+	ret := make(map[string]model.AggregationResult, len(aggregations))
+	for _, v := range aggregations {
+		result := model.AggregationResult{Name: v.Name, Type: v.Type}
+		switch v.Type {
+		case model.AggregationCount:
+			if metrics.Empty() {
+				break
+			}
+			value, err := metrics.Count(v.Name)
+			if err != nil {
+				return nil, err
+			}
+			result.Count = value.Value
+		case model.AggregationDistinctCount:
+			if metrics.Empty() {
+				break
+			}
+			value, err := metrics.DistinctCount(v.Name)
+			if err != nil {
+				return nil, err
+			}
+			result.Count = value.Value
+		case model.AggregationMin:
+			if metrics.Empty() {
+				break
+			}
+			value, err := metrics.Min(v.Name)
+			if err != nil {
+				return nil, err
+			}
+			result.Value, result.HasValue = value.Value, value.HasValue()
+		case model.AggregationMax:
+			if metrics.Empty() {
+				break
+			}
+			value, err := metrics.Max(v.Name)
+			if err != nil {
+				return nil, err
+			}
+			result.Value, result.HasValue = value.Value, value.HasValue()
+		case model.AggregationAvg:
+			if metrics.Empty() {
+				break
+			}
+			value, err := metrics.Avg(v.Name)
+			if err != nil {
+				return nil, err
+			}
+			result.Value, result.HasValue = value.Value, value.HasValue()
+		case model.AggregationSum:
+			if metrics.Empty() {
+				break
+			}
+			value, err := metrics.Sum(v.Name)
+			if err != nil {
+				return nil, err
+			}
+			result.Value, result.HasValue = value.Value, true
+		case model.AggregationTerms:
+			if groupBys.Empty() {
+				break
+			}
+			value, err := groupBys.GroupByField(v.Name)
+			if err != nil {
+				return nil, err
+			}
+			for _, item := range value.Items {
+				bucket := model.AggregationBucket{Key: item.Key, Count: item.RowCount}
+				if bucket.SubAggregations, err = parseAggregationResults(v.SubAggregations, item.SubAggregations, item.SubGroupBys); err != nil {
+					return nil, err
+				}
+				result.Buckets = append(result.Buckets, bucket)
+			}
+		case model.AggregationHistogram:
+			if groupBys.Empty() {
+				break
+			}
+			value, err := groupBys.GroupByHistogram(v.Name)
+			if err != nil {
+				return nil, err
+			}
+			for _, item := range value.Items {
+				bucket := model.AggregationBucket{NumericKey: cast.ToInt64(item.Key.Value), Count: item.Value}
+				if bucket.SubAggregations, err = parseAggregationResults(v.SubAggregations, item.SubAggregations, item.SubGroupBys); err != nil {
+					return nil, err
+				}
+				result.Buckets = append(result.Buckets, bucket)
+			}
+		}
+		ret[v.Name] = result
+	}
+	return ret, nil
+}
@@ -0,0 +1,127 @@
+package tablestore
+
+import (
+	"fmt"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+)
+
+const (
+	// maxBatchWriteRows max rows in one BatchWriteRow request
+	maxBatchWriteRows = 200
+	// conditionCheckFailCode error code of a row rejected by its existence condition
+	conditionCheckFailCode = "OTSConditionCheckFail"
+)
+
+// batchWriter buffers row changes and writes them with BatchWriteRow
+type batchWriter struct {
+	clt       *tablestore.TableStoreClient
+	batchSize int
+	limiter   *rateLimiter
+	// skipConditionFailed reports rows rejected by their condition to callbacks instead of failing the batch
+	skipConditionFailed bool
+	// flushed runs after the callbacks of every batch write, even a partially failed one
+	flushed   func() error
+	req       *tablestore.BatchWriteRowRequest
+	callbacks map[string][]func(written bool)
+	count     int
+	written   int
+}
+
+func (s *tableClient) newBatchWriter(batchSize int, rowsPerSecond int) *batchWriter {
+	if batchSize <= 0 || batchSize > maxBatchWriteRows {
+		batchSize = maxBatchWriteRows
+	}
+	return &batchWriter{
+		clt:       s.clt,
+		batchSize: batchSize,
+		limiter:   newRateLimiter(rowsPerSecond),
+		req:       new(tablestore.BatchWriteRowRequest),
+		callbacks: make(map[string][]func(bool)),
+	}
+}
+
+// Add buffers a row change, done is called once the row is written or skipped
+func (w *batchWriter) Add(change tablestore.RowChange, done func(written bool)) error {
+	w.req.AddRowChange(change)
+	tableName := change.GetTableName()
+	w.callbacks[tableName] = append(w.callbacks[tableName], done)
+	w.count++
+	if w.count >= w.batchSize {
+		return w.Flush()
+	}
+	return nil
+}
+
+// Flush writes buffered row changes. When some rows fail the rows written are still reported to their
+// callbacks before the error is returned, and the buffer is emptied.
+func (w *batchWriter) Flush() error {
+	if w.count == 0 {
+		return nil
+	}
+	w.limiter.Wait(w.count)
+	resp, err := w.clt.BatchWriteRow(w.req)
+	if err != nil {
+		return fmt.Errorf("batch write rows failed, %w", err)
+	}
+	var (
+		failed   int
+		firstErr tablestore.Error
+		skipped  = make(map[string]map[int32]struct{})
+		rejected = make(map[string]map[int32]struct{})
+	)
+	for tableName, results := range resp.TableToRowsResult {
+		for _, result := range results {
+			if result.IsSucceed {
+				continue
+			}
+			if w.skipConditionFailed && result.Error.Code == conditionCheckFailCode {
+				if skipped[tableName] == nil {
+					skipped[tableName] = make(map[int32]struct{})
+				}
+				skipped[tableName][result.Index] = struct{}{}
+				continue
+			}
+			if rejected[tableName] == nil {
+				rejected[tableName] = make(map[int32]struct{})
+			}
+			rejected[tableName][result.Index] = struct{}{}
+			if failed == 0 {
+				firstErr = result.Error
+			}
+			failed++
+		}
+	}
+	count := w.count
+	for tableName, callbacks := range w.callbacks {
+		for idx, done := range callbacks {
+			if _, isRejected := rejected[tableName][int32(idx)]; isRejected {
+				continue
+			}
+			_, isSkipped := skipped[tableName][int32(idx)]
+			if !isSkipped {
+				w.written++
+			}
+			if done != nil {
+				done(!isSkipped)
+			}
+		}
+	}
+	w.count = 0
+	w.callbacks = make(map[string][]func(bool))
+	w.req = new(tablestore.BatchWriteRowRequest)
+	if w.flushed != nil {
+		if err := w.flushed(); err != nil && failed == 0 {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("batch write rows failed, %d of %d rows failed, %s %s", failed, count, firstErr.Code, firstErr.Message)
+	}
+	return nil
+}
+
+// Written rows written successfully so far
+func (w *batchWriter) Written() int {
+	return w.written
+}
@@ -0,0 +1,146 @@
+package tablestore
+
+import (
+	"fmt"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+// messageTree loads the messages of a session not in the trash as a tree
+func (s *MemoryStore) messageTree(sessionID string) *model.MessageTree {
+	var messages []model.Message
+	for message := range s.listStoredMessages(sessionID, nil, 0, 0, tablestore.FORWARD, -1, 5000) {
+		messages = append(messages, message)
+	}
+	return model.NewMessageTree(messages)
+}
+
+// ListMessagePath list the messages from the root of the conversation down to the leaf message.
+// Messages written without a parent are roots.
+func (s *MemoryStore) ListMessagePath(sessionID string, leafMessageID string) ([]model.Message, error) {
+	path, err := s.messageTree(sessionID).Path(leafMessageID)
+	if err != nil {
+		return nil, fmt.Errorf("list message path failed, %w", err)
+	}
+	return path, nil
+}
+
+// ListActivePath list the messages from the root down to the latest message of the active branch of
+// the session, empty when the branch has no message
+func (s *MemoryStore) ListActivePath(userID string, sessionID string) ([]model.Message, error) {
+	session := model.Session{
+		UserID:    userID,
+		SessionID: sessionID,
+	}
+	if err := s.GetSession(&session); err != nil {
+		return nil, fmt.Errorf("list active path failed, %w", err)
+	}
+	tree := s.messageTree(sessionID)
+	leaf := tree.Leaf(session.ActiveBranchID)
+	if leaf == nil {
+		return nil, nil
+	}
+	path, err := tree.Path(leaf.MessageID)
+	if err != nil {
+		return nil, fmt.Errorf("list active path failed, %w", err)
+	}
+	return path, nil
+}
+
+// ListMessageSiblings list the alternatives of a message, the messages sharing its parent including
+// itself, oldest first
+func (s *MemoryStore) ListMessageSiblings(sessionID string, messageID string) ([]model.Message, error) {
+	siblings, err := s.messageTree(sessionID).Siblings(messageID)
+	if err != nil {
+		return nil, fmt.Errorf("list message siblings failed, %w", err)
+	}
+	return siblings, nil
+}
+
+// SwitchBranch make branchID the active branch of a session, an empty branchID selects the main branch
+func (s *MemoryStore) SwitchBranch(userID string, sessionID string, branchID string) error {
+	session := model.Session{
+		UserID:    userID,
+		SessionID: sessionID,
+	}
+	if err := s.GetSession(&session); err != nil {
+		return fmt.Errorf("switch branch failed, %w", err)
+	}
+	if branchID != "" && s.messageTree(sessionID).Leaf(branchID) == nil {
+		return fmt.Errorf("switch branch failed, branch %s has no message", branchID)
+	}
+	pk := new(tablestore.PrimaryKey)
+	pk.AddPrimaryKeyColumn(SessionUserIDField, userID)
+	pk.AddPrimaryKeyColumn(SessionSessionIDField, sessionID)
+	updateReq := new(tablestore.UpdateRowRequest)
+	updateReq.UpdateRowChange = new(tablestore.UpdateRowChange)
+	updateReq.UpdateRowChange.TableName = s.SessionTableName
+	updateReq.UpdateRowChange.PrimaryKey = pk
+	if branchID != "" {
+		updateReq.UpdateRowChange.PutColumn(SessionActiveBranchField, branchID)
+	} else {
+		updateReq.UpdateRowChange.DeleteColumn(SessionActiveBranchField)
+	}
+	updateReq.UpdateRowChange.SetCondition(tablestore.RowExistenceExpectation_EXPECT_EXIST)
+	if _, err := s.clt.UpdateRow(updateReq); err != nil {
+		return fmt.Errorf("switch branch failed, %w", err)
+	}
+	session.ActiveBranchID = branchID
+	s.writes.record(s.SessionTableName, sessionWriteKey(userID, sessionID), session.Clone())
+	return nil
+}
+
+// ForkSession copy the path from the root down to the leaf message into a new session newSessionID
+// of the same user, where it becomes the main branch. The new session keeps the title and metadata of
+// the source session and fails to be created if it already exists.
+func (s *MemoryStore) ForkSession(userID string, sessionID string, leafMessageID string, newSessionID string) (*model.Session, error) {
+	source := model.Session{
+		UserID:    userID,
+		SessionID: sessionID,
+	}
+	if err := s.GetSession(&source); err != nil {
+		return nil, fmt.Errorf("fork session failed, %w", err)
+	}
+	path, err := s.messageTree(sessionID).Path(leafMessageID)
+	if err != nil {
+		return nil, fmt.Errorf("fork session failed, %w", err)
+	}
+	now := model.CurrentTimeMicroseconds()
+	fork := source.Clone()
+	fork.SessionID = newSessionID
+	fork.SetCreateTime(now).
+		SetUpdateTime(now).
+		SetStatus(model.SessionStatusActive).
+		SetPinned(false).
+		SetActiveBranchID("")
+	putReq := new(tablestore.PutRowRequest)
+	putReq.PutRowChange = s.sessionPutRowChange(fork)
+	putReq.PutRowChange.SetCondition(tablestore.RowExistenceExpectation_EXPECT_NOT_EXIST)
+	if _, err := s.clt.PutRow(putReq); err != nil {
+		return nil, fmt.Errorf("fork session failed, %w", err)
+	}
+	s.writes.record(s.SessionTableName, sessionWriteKey(userID, newSessionID), fork.Clone())
+	s.rememberSessionOwner(userID, newSessionID)
+	writer := s.newBatchWriter(0, 0)
+	for _, v := range path {
+		message := v.Clone()
+		message.SessionID = newSessionID
+		message.UserID = userID
+		message.BranchID = ""
+		rowChange := s.messagePutRowChange(message)
+		rowChange.SetCondition(tablestore.RowExistenceExpectation_IGNORE)
+		if err := writer.Add(rowChange, func(written bool) {
+			if written {
+				s.writes.record(s.MessageTableName, messageWriteKey(message.SessionID, message.MessageID), message)
+			}
+		}); err != nil {
+			return fork, fmt.Errorf("fork session failed, %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return fork, fmt.Errorf("fork session failed, %w", err)
+	}
+	return fork, nil
+}
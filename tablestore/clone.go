@@ -0,0 +1,110 @@
+package tablestore
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/google/uuid"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+// CloneSession copy a session and its messages not in the trash to dstSessionID of dstUserID, the
+// destination session must not exist. Messages are streamed in create time order through batch writes
+// and keep their create time, embedding and parent pointers. The copy does not keep the trash state,
+// and only parent pointers are rewritten when message ids are.
+func (s *MemoryStore) CloneSession(srcUserID string, srcSessionID string, dstUserID string, dstSessionID string, opts model.CloneOptions) (*model.CloneReport, error) {
+	if dstUserID == "" || dstSessionID == "" {
+		return nil, errors.New("destination user id and session id are required")
+	}
+	if srcUserID == dstUserID && srcSessionID == dstSessionID {
+		return nil, errors.New("destination session is the source session")
+	}
+	source := model.Session{
+		UserID:    srcUserID,
+		SessionID: srcSessionID,
+	}
+	if err := s.GetSession(&source); err != nil {
+		return nil, fmt.Errorf("clone session failed, %w", err)
+	}
+	// the last copied message bounds the range listed
+	var endCreateTime int64
+	if opts.UntilMessageID != "" {
+		until := model.Message{
+			SessionID: srcSessionID,
+			MessageID: opts.UntilMessageID,
+		}
+		if err := s.GetMessage(&until); err != nil {
+			return nil, fmt.Errorf("clone session failed, %w", err)
+		}
+		endCreateTime = until.CreateTime
+	}
+	report := &model.CloneReport{
+		StartTime: model.CurrentTimeMicroseconds(),
+	}
+	clonedAt := report.StartTime
+	session := source.Clone()
+	session.UserID = dstUserID
+	session.SessionID = dstSessionID
+	session.CreateTime = clonedAt
+	session.UpdateTime = clonedAt
+	session.DeletedAt = 0
+	if opts.Provenance {
+		session.SetProvenance(srcUserID, srcSessionID, clonedAt)
+	}
+	putReq := new(tablestore.PutRowRequest)
+	putReq.PutRowChange = s.sessionPutRowChange(session)
+	putReq.PutRowChange.SetCondition(tablestore.RowExistenceExpectation_EXPECT_NOT_EXIST)
+	if _, err := s.clt.PutRow(putReq); err != nil {
+		return nil, fmt.Errorf("clone session failed, %w", err)
+	}
+	s.writes.record(s.SessionTableName, sessionWriteKey(dstUserID, dstSessionID), session.Clone())
+	s.rememberSessionOwner(dstUserID, dstSessionID)
+	report.Session = session
+	if opts.RewriteMessageIDs {
+		report.MessageIDs = make(map[string]string)
+	}
+
+	writer := s.newBatchWriter(opts.BatchSize, opts.RowsPerSecond)
+	var (
+		addErr error
+		done   bool
+	)
+	for message := range s.listStoredMessages(srcSessionID, nil, 0, endCreateTime, tablestore.FORWARD, -1, 5000) {
+		if addErr != nil || done {
+			// drain the channel so the producer is not blocked
+			continue
+		}
+		done = opts.UntilMessageID != "" && message.MessageID == opts.UntilMessageID
+		cp := message.Clone()
+		cp.UserID = dstUserID
+		cp.SessionID = dstSessionID
+		if opts.RewriteMessageIDs {
+			cp.MessageID = uuid.NewString()
+			report.MessageIDs[message.MessageID] = cp.MessageID
+			if parentID, ok := report.MessageIDs[message.ParentMessageID]; ok {
+				cp.ParentMessageID = parentID
+			}
+		}
+		if opts.Provenance {
+			cp.SetProvenance(srcUserID, srcSessionID, message.MessageID, clonedAt)
+		}
+		rowChange := s.messagePutRowChange(cp)
+		rowChange.SetCondition(tablestore.RowExistenceExpectation_IGNORE)
+		addErr = writer.Add(rowChange, func(written bool) {
+			if written {
+				s.writes.record(s.MessageTableName, messageWriteKey(cp.SessionID, cp.MessageID), cp)
+			}
+		})
+	}
+	if addErr == nil {
+		addErr = writer.Flush()
+	}
+	report.Messages = writer.Written()
+	report.EndTime = model.CurrentTimeMicroseconds()
+	if addErr != nil {
+		return report, fmt.Errorf("clone session failed, %w", addErr)
+	}
+	return report, nil
+}
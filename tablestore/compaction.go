@@ -0,0 +1,146 @@
+package tablestore
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore/search"
+	"github.com/google/uuid"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+// ActiveMessagesFilter column filter skipping messages archived by compaction. Listing APIs apply it
+// unless the store is created with model.WithArchivedMessages.
+func ActiveMessagesFilter() tablestore.ColumnFilter {
+	return tablestore.NewSingleColumnCondition(model.MetadataCompactedByKey, tablestore.CT_EQUAL, "")
+}
+
+// activeMessagesFilter the filter listing APIs add to skip archived messages, nil when they are included
+func (s *MemoryStore) activeMessagesFilter() tablestore.ColumnFilter {
+	if s.IncludeArchivedMessages {
+		return nil
+	}
+	return ActiveMessagesFilter()
+}
+
+// excludeArchived restricts a message search query to messages not archived by compaction. Message
+// search indexes created before compacted_by was indexed keep returning archived messages.
+func (s *MemoryStore) excludeArchived(query search.Query) search.Query {
+	if s.IncludeArchivedMessages || !s.searchIndexHasField(s.MessageTableName, s.MessageSearchIndexName, model.MetadataCompactedByKey) {
+		return query
+	}
+	return &search.BoolQuery{
+		MustQueries:    []search.Query{query},
+		MustNotQueries: []search.Query{&search.ExistsQuery{FieldName: model.MetadataCompactedByKey}},
+	}
+}
+
+// CompactMessages replace the active messages of a session in the create time range of opts with a
+// summary message written at the create time of the last covered message. The summary is written
+// before the originals are archived or deleted, so a failure never loses history.
+func (s *MemoryStore) CompactMessages(sessionID string, summarizer model.Summarizer, opts model.CompactionOptions) (*model.CompactionReport, error) {
+	if sessionID == "" {
+		return nil, errors.New("session id is required")
+	}
+	if summarizer == nil {
+		return nil, errors.New("summarizer is required")
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	var messages []model.Message
+	for msg := range s.ListMessagesWithFilter(sessionID, ActiveMessagesFilter(), opts.StartTime, opts.EndTime, tablestore.FORWARD, -1, 5000) {
+		messages = append(messages, msg)
+	}
+	if len(messages) == 0 {
+		return nil, errors.New("no messages to compact")
+	}
+	content, err := summarizer.Summarize(messages)
+	if err != nil {
+		return nil, fmt.Errorf("summarize messages failed, %w", err)
+	}
+	info := &model.SummaryInfo{
+		StartTime:  messages[0].CreateTime,
+		EndTime:    messages[len(messages)-1].CreateTime,
+		MessageIDs: make([]string, 0, len(messages)),
+	}
+	for _, msg := range messages {
+		info.MessageIDs = append(info.MessageIDs, msg.MessageID)
+	}
+	summaryID := opts.SummaryMessageID
+	if summaryID == "" {
+		summaryID = "summary_" + uuid.NewString()
+	}
+	role := opts.SummaryRole
+	if role == "" {
+		role = model.RoleSystem
+	}
+	summary := model.NewMessage(sessionID, summaryID)
+	summary.SetCreateTime(info.EndTime).SetContent(content)
+	summary.SetRole(role)
+	if err := summary.SetSummaryInfo(info); err != nil {
+		return nil, fmt.Errorf("compact messages failed, %w", err)
+	}
+	if err := s.PutMessage(summary); err != nil {
+		return nil, fmt.Errorf("compact messages failed, %w", err)
+	}
+	report := &model.CompactionReport{
+		Summary:   summary,
+		Mode:      opts.Mode.String(),
+		StartTime: info.StartTime,
+		EndTime:   info.EndTime,
+	}
+	if opts.Mode == model.CompactionKeep {
+		return report, nil
+	}
+	writer := s.newBatchWriter(opts.BatchSize, 0)
+	for _, msg := range messages {
+		var rowChange tablestore.RowChange
+		pk := messagePrimaryKey(msg.SessionID, msg.CreateTime, msg.MessageID)
+		if opts.Mode == model.CompactionDelete {
+			change := new(tablestore.DeleteRowChange)
+			change.TableName = s.MessageTableName
+			change.PrimaryKey = pk
+			change.SetCondition(tablestore.RowExistenceExpectation_IGNORE)
+			rowChange = change
+		} else {
+			change := new(tablestore.UpdateRowChange)
+			change.TableName = s.MessageTableName
+			change.PrimaryKey = pk
+			change.PutColumn(model.MetadataCompactedByKey, summaryID)
+			change.SetCondition(tablestore.RowExistenceExpectation_EXPECT_EXIST)
+			rowChange = change
+		}
+		if err := writer.Add(rowChange, nil); err != nil {
+			report.Compacted = writer.Written()
+			return report, fmt.Errorf("compact messages failed, %w", err)
+		}
+	}
+	err = writer.Flush()
+	report.Compacted = writer.Written()
+	if err != nil {
+		return report, fmt.Errorf("compact messages failed, %w", err)
+	}
+	return report, nil
+}
+
+// ListSummarizedMessages list the originals covered by a summary message that were archived or
+// kept, deleted originals are not returned
+func (s *MemoryStore) ListSummarizedMessages(summary *model.Message) ([]model.Message, error) {
+	info, err := summary.SummaryInfo()
+	if err != nil {
+		return nil, fmt.Errorf("list summarized messages failed, %w", err)
+	}
+	if info == nil {
+		return nil, errors.New("message is not a summary")
+	}
+	var ret []model.Message
+	for msg := range s.listStoredMessages(summary.SessionID, nil, info.StartTime, info.EndTime, tablestore.FORWARD, -1, 5000) {
+		if msg.MessageID != summary.MessageID && info.Covers(&msg) {
+			ret = append(ret, msg)
+		}
+	}
+	return ret, nil
+}
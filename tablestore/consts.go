@@ -7,19 +7,63 @@ const (
 	DefaultMessageTableName          = "message"
 	DefaultMessageSearchIndexName    = "message_search_index"
 	DefaultMessageSecondaryIndexName = "message_secondary_index"
+	DefaultMessageHistoryTableName   = "message_history"
+	DefaultMemoryTableName           = "memory"
+	DefaultMemorySearchIndexName     = "memory_search_index"
+	DefaultKnowledgeTableName        = "knowledge"
+	DefaultKnowledgeSearchIndexName  = "knowledge_search_index"
 )
 
 const (
 	SessionUserIDField        = "user_id"
 	SessionSessionIDField     = "session_id"
 	SessionUpdateTimeField    = "update_time"
+	SessionTitleField         = "title"
+	SessionCreateTimeField    = "create_time"
+	SessionStatusField        = "status"
+	SessionPinnedField        = "pinned"
+	SessionDeletedAtField     = "deleted_at"
+	SessionActiveBranchField  = "active_branch_id"
 	SessionSearchContentField = "search_content"
+	SessionEmbeddingField     = "embedding"
 )
 
 const (
 	MessageSessionIDField     = "session_id"
+	MessageUserIDField        = "user_id"
 	MessageMessageIDField     = "message_id"
 	MessageCreateTimeField    = "create_time"
 	MessageContentField       = "content"
 	MessageSearchContentField = "search_content"
+	MessageEmbeddingField     = "embedding"
+	MessageDeletedAtField     = "deleted_at"
+	MessageParentIDField      = "parent_message_id"
+	MessageBranchIDField      = "branch_id"
+	MessageStreamStatusField  = "stream_status"
+	MessageStreamUpdateField  = "stream_update_time"
+)
+
+const (
+	MessageHistoryValidToField   = "valid_to"
+	MessageHistoryValidFromField = "valid_from"
+)
+
+const (
+	MemoryUserIDField     = "user_id"
+	MemoryMemoryIDField   = "memory_id"
+	MemoryContentField    = "content"
+	MemoryImportanceField = "importance"
+	MemorySourcesField    = "sources"
+	MemoryCreateTimeField = "create_time"
+	MemoryUpdateTimeField = "update_time"
+	MemoryExpireTimeField = "expire_time"
+)
+
+const (
+	KnowledgeTenantIDField   = "tenant_id"
+	KnowledgeDocumentIDField = "document_id"
+	KnowledgeChunkIndexField = "chunk_index"
+	KnowledgeContentField    = "content"
+	KnowledgeEmbeddingField  = "embedding"
+	KnowledgeUpdateTimeField = "update_time"
 )
@@ -0,0 +1,120 @@
+package tablestore
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+// BuildContext select the latest messages of a session that fit in budget tokens. Pinned messages
+// and messages with one of the kept roles are always included and counted first, the rest is
+// paged backward from the newest message and stops at the first message that no longer fits, so
+// no more than one page beyond the budget is read. Messages archived by compaction, or covered by
+// a summary in the window, are skipped. Messages are returned in chronological order.
+func (s *MemoryStore) BuildContext(sessionID string, budget int, opts ...model.ContextOption) (*model.ContextWindow, error) {
+	if sessionID == "" {
+		return nil, errors.New("session id is required")
+	}
+	if budget <= 0 {
+		return nil, errors.New("token budget must be positive")
+	}
+	options := model.NewContextOptions(opts...)
+	window := &model.ContextWindow{
+		Budget: budget,
+	}
+	var (
+		seen      = make(map[string]struct{})
+		summaries []*model.SummaryInfo
+	)
+	covered := func(msg *model.Message) bool {
+		for _, info := range summaries {
+			if info.Covers(msg) {
+				return true
+			}
+		}
+		return false
+	}
+	addSummary := func(msg *model.Message) {
+		if info, err := msg.SummaryInfo(); err == nil && info != nil {
+			summaries = append(summaries, info)
+		}
+	}
+	var kept []model.Message
+	for msg := range s.ListMessagesWithFilter(sessionID, keptMessagesFilter(options.KeepRoles), 0, 0, tablestore.FORWARD, -1, options.PageSize) {
+		kept = append(kept, msg)
+		addSummary(&msg)
+	}
+	for i := range kept {
+		msg := &kept[i]
+		if covered(msg) {
+			continue
+		}
+		seen[msg.MessageID] = struct{}{}
+		window.Messages = append(window.Messages, *msg)
+		window.Tokens += options.Tokenizer.CountTokens(msg)
+	}
+
+	// page by the full primary key so messages sharing a create time are neither lost nor repeated
+	var nextStartPrimaryKey *tablestore.PrimaryKey
+	for {
+		page, err := s.ListMessagesPaginated(sessionID, ActiveMessagesFilter(), 0, 0, tablestore.BACKWARD, options.PageSize, nextStartPrimaryKey)
+		if err != nil {
+			return nil, fmt.Errorf("build context failed, %w", err)
+		}
+		for i := range page.Hits {
+			msg := &page.Hits[i]
+			if _, ok := seen[msg.MessageID]; ok || covered(msg) {
+				continue
+			}
+			tokens := options.Tokenizer.CountTokens(msg)
+			if window.Tokens+tokens > budget {
+				window.Truncated = true
+				sortMessages(window.Messages)
+				return window, nil
+			}
+			seen[msg.MessageID] = struct{}{}
+			window.Messages = append(window.Messages, *msg)
+			window.Tokens += tokens
+			addSummary(msg)
+		}
+		if page.NextStartPrimaryKey == nil {
+			break
+		}
+		nextStartPrimaryKey = page.NextStartPrimaryKey
+	}
+	sortMessages(window.Messages)
+	return window, nil
+}
+
+func keptMessagesFilter(roles []model.Role) tablestore.ColumnFilter {
+	pinned := tablestore.NewSingleColumnCondition(model.MetadataPinnedKey, tablestore.CT_EQUAL, true)
+	pinned.FilterIfMissing = true
+	filter := tablestore.NewCompositeColumnCondition(tablestore.LO_AND)
+	filter.AddFilter(ActiveMessagesFilter())
+	if len(roles) == 0 {
+		filter.AddFilter(pinned)
+		return filter
+	}
+	anyOf := tablestore.NewCompositeColumnCondition(tablestore.LO_OR)
+	anyOf.AddFilter(pinned)
+	for _, role := range roles {
+		cond := tablestore.NewSingleColumnCondition(model.MetadataRoleKey, tablestore.CT_EQUAL, string(role))
+		cond.FilterIfMissing = true
+		anyOf.AddFilter(cond)
+	}
+	filter.AddFilter(anyOf)
+	return filter
+}
+
+func sortMessages(messages []model.Message) {
+	sort.SliceStable(messages, func(i, j int) bool {
+		if messages[i].CreateTime != messages[j].CreateTime {
+			return messages[i].CreateTime < messages[j].CreateTime
+		}
+		return messages[i].MessageID < messages[j].MessageID
+	})
+}
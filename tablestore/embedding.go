@@ -0,0 +1,171 @@
+package tablestore
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore/search"
+	"github.com/golang/protobuf/proto"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+func vectorFieldSchema(fieldName string, dimension int32, metric tablestore.VectorMetricType) *tablestore.FieldSchema {
+	return &tablestore.FieldSchema{
+		FieldName: proto.String(fieldName),
+		FieldType: tablestore.FieldType_VECTOR,
+		Index:     proto.Bool(true),
+		VectorOptions: &tablestore.VectorOptions{
+			VectorDataType:   tablestore.VectorDataType_FLOAT_32.Enum(),
+			VectorMetricType: metric.Enum(),
+			Dimension:        proto.Int32(dimension),
+		},
+	}
+}
+
+// embed computes the embedding of a single text with the configured embedder
+func (s *MemoryStore) embed(text string) ([]float32, error) {
+	vectors, err := s.Embedder.Embed([]string{text})
+	if err != nil {
+		return nil, fmt.Errorf("embed text failed, %w", err)
+	}
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("embedder returned %d vectors for 1 text", len(vectors))
+	}
+	return vectors[0], s.checkDimension(vectors[0])
+}
+
+func (s *MemoryStore) checkDimension(vector []float32) error {
+	if s.Embedder == nil {
+		return errors.New("no embedder configured")
+	}
+	if len(vector) != s.Embedder.Dimension() {
+		return fmt.Errorf("embedding dimension %d, expected %d", len(vector), s.Embedder.Dimension())
+	}
+	return nil
+}
+
+// ensureMessageEmbedding fills the message embedding when an embedder is configured and it is missing
+func (s *MemoryStore) ensureMessageEmbedding(message *model.Message) error {
+	if s.Embedder == nil {
+		return nil
+	}
+	if len(message.Embedding) > 0 {
+		return s.checkDimension(message.Embedding)
+	}
+	text := message.EmbeddingText()
+	if text == "" {
+		return nil
+	}
+	embedding, err := s.embed(text)
+	if err != nil {
+		return err
+	}
+	message.Embedding = embedding
+	return nil
+}
+
+// ensureSessionEmbedding fills the session embedding from its search content when an embedder is configured
+func (s *MemoryStore) ensureSessionEmbedding(session *model.Session) error {
+	if s.Embedder == nil {
+		return nil
+	}
+	if len(session.Embedding) > 0 {
+		return s.checkDimension(session.Embedding)
+	}
+	if session.SearchContent == "" {
+		return nil
+	}
+	embedding, err := s.embed(session.SearchContent)
+	if err != nil {
+		return err
+	}
+	session.Embedding = embedding
+	return nil
+}
+
+// SemanticSearchMessages returns the k messages nearest to queryText by embedding, within a session
+// when sessionID is given, otherwise within the sessions of userID. Filters restrict the candidates
+// by indexed message metadata.
+func (s *MemoryStore) SemanticSearchMessages(sessionID string, userID string, queryText string, k int32, filters ...model.MetadataFilter) (*model.Response[model.Message], error) {
+	if s.Embedder == nil {
+		return nil, errors.New("semantic search requires an embedder")
+	}
+	if queryText == "" {
+		return nil, errors.New("query text is required")
+	}
+	if k <= 0 {
+		k = 10
+	}
+	filter, err := s.messageScopeQuery(sessionID, userID, filters)
+	if err != nil {
+		return nil, fmt.Errorf("semantic search messages failed, %w", err)
+	}
+	embedding, err := s.embed(queryText)
+	if err != nil {
+		return nil, fmt.Errorf("semantic search messages failed, %w", err)
+	}
+	searchQuery := search.NewSearchQuery()
+	searchQuery.SetQuery(&search.KnnVectorQuery{
+		FieldName:          MessageEmbeddingField,
+		TopK:               proto.Int32(k),
+		Float32QueryVector: embedding,
+		Filter:             filter,
+	})
+	searchQuery.SetSort(&search.Sort{
+		Sorters: []search.Sorter{
+			&search.ScoreSort{
+				Order: search.SortOrder_DESC.Enum(),
+			},
+		},
+	})
+	searchQuery.SetLimit(k)
+	searchReq := new(tablestore.SearchRequest)
+	searchReq.SetTableName(s.MessageTableName)
+	searchReq.SetIndexName(s.MessageSearchIndexName)
+	searchReq.SetSearchQuery(searchQuery)
+	searchReq.SetColumnsToGet(&tablestore.ColumnsToGet{
+		ReturnAll: true,
+	})
+	resp, err := s.clt.Search(searchReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to semantic search messages, %w", err)
+	}
+	ret := new(model.Response[model.Message])
+	ret.Total = int64(len(resp.Rows))
+	ret.Hits = make([]model.Message, 0, len(resp.Rows))
+	for _, row := range resp.Rows {
+		var msg model.Message
+		parseMessageFromRow(&msg, row.Columns, row.PrimaryKey)
+		ret.Hits = append(ret.Hits, msg)
+	}
+	return ret, nil
+}
+
+// messageScopeQuery restricts message searches to a session or to the messages stamped with the
+// user id, see SearchUserMessages
+func (s *MemoryStore) messageScopeQuery(sessionID string, userID string, filters []model.MetadataFilter) (search.Query, error) {
+	queries := make([]search.Query, 0, len(filters)+1)
+	switch {
+	case sessionID != "":
+		queries = append(queries, &search.TermQuery{
+			FieldName: MessageSessionIDField,
+			Term:      sessionID,
+		})
+	case userID != "":
+		queries = append(queries, &search.TermQuery{
+			FieldName: MessageUserIDField,
+			Term:      userID,
+		})
+	default:
+		return nil, errors.New("either session id or user id is required")
+	}
+	for _, v := range filters {
+		if err := v.Validate(); err != nil {
+			return nil, err
+		}
+		queries = append(queries, metadataFilterQuery(v))
+	}
+	return s.excludeArchived(s.excludeDeleted(&search.BoolQuery{FilterQueries: queries}, MessageDeletedAtField)), nil
+}
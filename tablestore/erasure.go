@@ -0,0 +1,196 @@
+package tablestore
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore/search"
+	"github.com/spf13/cast"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+// DeleteUserData erase all sessions of a user together with their messages, the messages stamped with
+// the user id whose session is already gone, then the user's memories.
+// Messages of a session are deleted before the session row, so a failed attempt leaves the
+// session listed and a later call with the returned report resumes where it stopped. Reads fail the
+// erasure instead of skipping rows, and a failed verification read returns an unverified report with
+// the error.
+func (s *MemoryStore) DeleteUserData(userID string, checkpoint *model.ErasureReport) (*model.ErasureReport, error) {
+	if userID == "" {
+		return nil, errors.New("user id is required")
+	}
+	report := checkpoint
+	if report == nil {
+		report = model.NewErasureReport(userID)
+	} else if report.UserID != userID {
+		return nil, fmt.Errorf("checkpoint belongs to user %s, not %s", report.UserID, userID)
+	}
+	report.Completed = false
+	report.Verified = false
+	report.RemainingSessions = nil
+	report.RemainingMessages = 0
+	report.RemainingMemories = 0
+
+	var sessionIDs []string
+	if err := s.scanSessions(userID, nil, func(session *model.Session) error {
+		sessionIDs = append(sessionIDs, session.SessionID)
+		return nil
+	}); err != nil {
+		report.EndTime = model.CurrentTimeMicroseconds()
+		return report, fmt.Errorf("list sessions to erase failed, %w", err)
+	}
+	// the pending session row may be gone if its delete failed on the client side only, finish its messages first
+	if report.PendingSessionID != "" && !slices.Contains(sessionIDs, report.PendingSessionID) {
+		n, err := s.DeleteMessages(report.PendingSessionID)
+		if err != nil {
+			report.EndTime = model.CurrentTimeMicroseconds()
+			return report, fmt.Errorf("erase messages of session %s failed, %w", report.PendingSessionID, err)
+		}
+		report.AddMessages(report.PendingSessionID, n)
+		report.PendingSessionID = ""
+	}
+	for _, sessionID := range sessionIDs {
+		report.PendingSessionID = sessionID
+		n, err := s.DeleteMessages(sessionID)
+		if err != nil {
+			report.EndTime = model.CurrentTimeMicroseconds()
+			return report, fmt.Errorf("erase messages of session %s failed, %w", sessionID, err)
+		}
+		if err := s.deleteSession(userID, sessionID); err != nil {
+			report.AddMessages(sessionID, n)
+			report.EndTime = model.CurrentTimeMicroseconds()
+			return report, fmt.Errorf("erase session %s failed, %w", sessionID, err)
+		}
+		report.AddSession(sessionID, n)
+		report.PendingSessionID = ""
+	}
+	// messages of sessions deleted earlier without them are found by their user id
+	orphans, err := s.userMessageSessionIDs(userID)
+	if err != nil {
+		report.EndTime = model.CurrentTimeMicroseconds()
+		return report, fmt.Errorf("find orphaned messages failed, %w", err)
+	}
+	for _, sessionID := range orphans {
+		n, err := s.deleteUserSessionMessages(userID, sessionID)
+		report.AddMessages(sessionID, n)
+		if err != nil {
+			report.EndTime = model.CurrentTimeMicroseconds()
+			return report, fmt.Errorf("erase orphaned messages of session %s failed, %w", sessionID, err)
+		}
+	}
+	n, err := s.ForgetMemories(userID)
+	report.DeletedMemories += n
+	if err != nil {
+		report.EndTime = model.CurrentTimeMicroseconds()
+		return report, fmt.Errorf("erase memories failed, %w", err)
+	}
+	report.Completed = true
+	err = s.verifyErasure(report)
+	report.EndTime = model.CurrentTimeMicroseconds()
+	if err != nil {
+		return report, fmt.Errorf("verify erasure failed, %w", err)
+	}
+	return report, nil
+}
+
+// userMessageSessionIDs sessions of the messages stamped with the user id in the message search index
+func (s *MemoryStore) userMessageSessionIDs(userID string) ([]string, error) {
+	var ret []string
+	query := &search.TermQuery{
+		FieldName: MessageUserIDField,
+		Term:      userID,
+	}
+	err := s.parallelScan(s.MessageTableName, s.MessageSearchIndexName, query, nil, func(row *tablestore.Row) error {
+		for _, col := range row.PrimaryKey.PrimaryKeys {
+			if col.ColumnName != MessageSessionIDField {
+				continue
+			}
+			if sessionID := cast.ToString(col.Value); !slices.Contains(ret, sessionID) {
+				ret = append(ret, sessionID)
+			}
+		}
+		return nil
+	})
+	return ret, err
+}
+
+// deleteUserSessionMessages delete the messages of a session stamped with the user id, including
+// messages in the trash and their history
+func (s *MemoryStore) deleteUserSessionMessages(userID string, sessionID string) (int, error) {
+	filter := tablestore.NewSingleColumnCondition(MessageUserIDField, tablestore.CT_EQUAL, userID)
+	filter.FilterIfMissing = true
+	writer := s.newBatchWriter(0, 0)
+	deleteHistory := s.deleteHistoryAfterFlush(writer)
+	addErr := s.scanMessages(sessionID, filter, func(message *model.Message) error {
+		rowChange := new(tablestore.DeleteRowChange)
+		rowChange.TableName = s.MessageTableName
+		rowChange.PrimaryKey = messagePrimaryKey(message.SessionID, message.CreateTime, message.MessageID)
+		rowChange.SetCondition(tablestore.RowExistenceExpectation_IGNORE)
+		key := messageWriteKey(message.SessionID, message.MessageID)
+		messageID := message.MessageID
+		return writer.Add(rowChange, func(bool) {
+			s.writes.record(s.MessageTableName, key, nil)
+			deleteHistory(sessionID, messageID)
+		})
+	})
+	if addErr == nil {
+		addErr = writer.Flush()
+	}
+	return writer.Written(), addErr
+}
+
+// verifyErasure reads back the user's sessions, memories, the messages of erased sessions and the
+// messages still indexed with the user id in other sessions. A failed read leaves the report unverified,
+// with -1 for a count it could not read, and returns the first error.
+func (s *MemoryStore) verifyErasure(report *model.ErasureReport) error {
+	var readErr error
+	if err := s.scanSessions(report.UserID, nil, func(session *model.Session) error {
+		report.RemainingSessions = append(report.RemainingSessions, session.SessionID)
+		return nil
+	}); err != nil {
+		readErr = err
+	}
+	for sessionID := range report.Sessions {
+		if err := s.scanMessages(sessionID, nil, func(*model.Message) error {
+			report.RemainingMessages++
+			return nil
+		}); err != nil {
+			readErr = cmp.Or(readErr, err)
+			report.RemainingMessages = -1
+			break
+		}
+	}
+	// the search index lags behind deletes, so only sessions not erased from the table are counted
+	query := &search.TermQuery{
+		FieldName: MessageUserIDField,
+		Term:      report.UserID,
+	}
+	if report.RemainingMessages >= 0 {
+		if err := s.parallelScan(s.MessageTableName, s.MessageSearchIndexName, query, nil, func(row *tablestore.Row) error {
+			for _, col := range row.PrimaryKey.PrimaryKeys {
+				if col.ColumnName != MessageSessionIDField {
+					continue
+				}
+				if _, ok := report.Sessions[cast.ToString(col.Value)]; !ok {
+					report.RemainingMessages++
+				}
+			}
+			return nil
+		}); err != nil {
+			readErr = cmp.Or(readErr, err)
+			report.RemainingMessages = -1
+		}
+	}
+	if memories, err := s.listMemories(report.UserID, nil); err != nil {
+		readErr = cmp.Or(readErr, err)
+		report.RemainingMemories = -1
+	} else {
+		report.RemainingMemories = len(memories)
+	}
+	report.Verified = readErr == nil && len(report.RemainingSessions) == 0 && report.RemainingMessages == 0 && report.RemainingMemories == 0
+	return readErr
+}
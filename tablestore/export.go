@@ -0,0 +1,133 @@
+package tablestore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+// ExportUser write all sessions of a user and their messages to w as JSONL records, rows in the trash
+// included with their deleted_at
+func (s *MemoryStore) ExportUser(userID string, w io.Writer) (*model.ExportReport, error) {
+	if userID == "" {
+		return nil, errors.New("user id is required")
+	}
+	var sessions []model.Session
+	for v := range s.listSessions(userID, nil, -1, 5000) {
+		sessions = append(sessions, v)
+	}
+	report := new(model.ExportReport)
+	encoder := json.NewEncoder(w)
+	for idx := range sessions {
+		if err := s.exportSession(encoder, &sessions[idx], report); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// ExportSession write a session and its messages to w as JSONL records, rows in the trash included
+// with their deleted_at
+func (s *MemoryStore) ExportSession(userID string, sessionID string, w io.Writer) (*model.ExportReport, error) {
+	session := model.Session{
+		UserID:    userID,
+		SessionID: sessionID,
+	}
+	if err := s.getSession(&session); err != nil {
+		return nil, fmt.Errorf("export session failed, %w", err)
+	}
+	report := new(model.ExportReport)
+	if err := s.exportSession(json.NewEncoder(w), &session, report); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+func (s *MemoryStore) exportSession(encoder *json.Encoder, session *model.Session, report *model.ExportReport) error {
+	record, err := model.NewSessionRecord(session)
+	if err != nil {
+		return fmt.Errorf("export session %s failed, %w", session.SessionID, err)
+	}
+	if err := encoder.Encode(record); err != nil {
+		return fmt.Errorf("write session %s failed, %w", session.SessionID, err)
+	}
+	report.Sessions++
+	var exportErr error
+	for v := range s.listMessagesWithFilter(session.SessionID, nil, 0, 0, tablestore.FORWARD, -1, 5000) {
+		if exportErr != nil {
+			// drain the channel so the producer is not blocked
+			continue
+		}
+		record, err := model.NewMessageRecord(&v)
+		if err != nil {
+			exportErr = fmt.Errorf("export message %s failed, %w", v.MessageID, err)
+			continue
+		}
+		if err := encoder.Encode(record); err != nil {
+			exportErr = fmt.Errorf("write message %s failed, %w", v.MessageID, err)
+			continue
+		}
+		report.Messages++
+	}
+	return exportErr
+}
+
+// Import restore sessions and messages from JSONL records produced by ExportUser or ExportSession. Rows
+// are written in batches, with ConflictFail the report counts the rows written before the import failed.
+func (s *MemoryStore) Import(r io.Reader, policy model.ConflictPolicy) (*model.ImportReport, error) {
+	condition := tablestore.RowExistenceExpectation_EXPECT_NOT_EXIST
+	if policy == model.ConflictOverwrite {
+		condition = tablestore.RowExistenceExpectation_IGNORE
+	}
+	report := new(model.ImportReport)
+	writer := s.newBatchWriter(maxBatchWriteRows, 0)
+	writer.skipConditionFailed = policy == model.ConflictSkip
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	for line := 1; ; line++ {
+		var record model.ExportRecord
+		if err := decoder.Decode(&record); err == io.EOF {
+			break
+		} else if err != nil {
+			return report, fmt.Errorf("decode import record #%d failed, %w", line, err)
+		}
+		if err := record.Restore(); err != nil {
+			return report, fmt.Errorf("invalid import record #%d, %w", line, err)
+		}
+		var err error
+		switch record.Type {
+		case model.SessionRecord:
+			rowChange := s.sessionPutRowChange(record.Session)
+			rowChange.SetCondition(condition)
+			err = writer.Add(rowChange, func(written bool) {
+				if written {
+					report.Sessions++
+				} else {
+					report.SkippedSessions++
+				}
+			})
+		case model.MessageRecord:
+			rowChange := s.messagePutRowChange(record.Message)
+			rowChange.SetCondition(condition)
+			err = writer.Add(rowChange, func(written bool) {
+				if written {
+					report.Messages++
+				} else {
+					report.SkippedMessages++
+				}
+			})
+		}
+		if err != nil {
+			return report, fmt.Errorf("import records failed, %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return report, fmt.Errorf("import records failed, %w", err)
+	}
+	return report, nil
+}
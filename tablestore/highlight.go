@@ -0,0 +1,43 @@
+package tablestore
+
+import (
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore/search"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+// searchHighlight highlight of a text field, nil when highlighting is disabled
+func searchHighlight(fieldName string, opts model.HighlightOptions) *search.Highlight {
+	if opts.Disabled {
+		return nil
+	}
+	param := search.NewHighlightParameter().
+		SetPreTag(opts.PreTag).
+		SetPostTag(opts.PostTag).
+		SetFragmentSize(opts.FragmentSize).
+		SetNumberOfFragments(opts.NumberOfFragments).
+		SetHighlightFragmentOrder(search.Score)
+	return search.NewHighlight().AddFieldHighlightParameter(fieldName, param)
+}
+
+// hitDetails relevance of search rows, aligned with resp.Rows
+func hitDetails(resp *tablestore.SearchResponse, fieldName string) []model.HitDetail {
+	ret := make([]model.HitDetail, len(resp.Rows))
+	for idx := range ret {
+		if idx >= len(resp.SearchHits) || resp.SearchHits[idx] == nil {
+			continue
+		}
+		hit := resp.SearchHits[idx]
+		if hit.Score != nil {
+			ret[idx].Score = *hit.Score
+		}
+		if hit.HighlightResultItem == nil {
+			continue
+		}
+		if field, ok := hit.HighlightResultItem.HighlightFields[fieldName]; ok && field != nil {
+			ret[idx].Highlights = field.Fragments
+		}
+	}
+	return ret
+}
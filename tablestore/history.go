@@ -0,0 +1,333 @@
+package tablestore
+
+import (
+	"fmt"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/spf13/cast"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+// InitMessageHistoryTable creates the message history table if it does not exist yet, it shares the
+// table options of the message table
+func (s *MemoryStore) InitMessageHistoryTable() error {
+	describeResp, err := s.describeTableIfExists(s.MessageHistoryTableName)
+	if err != nil {
+		return fmt.Errorf("describe message history table failed during init message history table, %w", err)
+	}
+	if describeResp != nil {
+		if err := s.reconcileTable(s.MessageHistoryTableName, s.MessageTableOptions, describeResp); err != nil {
+			return fmt.Errorf("reconcile message history table failed during init message history table, %w", err)
+		}
+		return nil
+	}
+	tableMeta := new(tablestore.TableMeta)
+	tableMeta.TableName = s.MessageHistoryTableName
+	tableMeta.AddPrimaryKeyColumn(MessageSessionIDField, tablestore.PrimaryKeyType_STRING)
+	tableMeta.AddPrimaryKeyColumn(MessageMessageIDField, tablestore.PrimaryKeyType_STRING)
+	tableMeta.AddPrimaryKeyColumn(MessageHistoryValidToField, tablestore.PrimaryKeyType_INTEGER)
+	tableMeta.AddDefinedColumn(MessageContentField, tablestore.DefinedColumn_STRING)
+	createTableRequest := new(tablestore.CreateTableRequest)
+	createTableRequest.TableMeta = tableMeta
+	createTableRequest.TableOption = newTableOption(s.MessageTableOptions)
+	createTableRequest.ReservedThroughput = newReservedThroughput(s.MessageTableOptions)
+	createTableRequest.SSESpecification = s.MessageTableOptions.SSE
+	if _, err := s.clt.CreateTable(createTableRequest); err != nil {
+		return fmt.Errorf("create message history table failed, %w", err)
+	}
+	return nil
+}
+
+// DeleteMessageHistoryTable deletes the message history table.
+// It refuses to touch a table whose schema does not match the one created by InitMessageHistoryTable.
+func (s *MemoryStore) DeleteMessageHistoryTable() error {
+	describeResp, err := s.describeTableIfExists(s.MessageHistoryTableName)
+	if err != nil {
+		return fmt.Errorf("describe message history table failed during delete message history table, %w", err)
+	}
+	if describeResp == nil {
+		return nil
+	}
+	if err := checkMessageHistoryTableSchema(describeResp.TableMeta); err != nil {
+		return fmt.Errorf("refuse to delete message history table %s, %w", s.MessageHistoryTableName, err)
+	}
+	deleteReq := new(tablestore.DeleteTableRequest)
+	deleteReq.TableName = s.MessageHistoryTableName
+	if _, err := s.clt.DeleteTable(deleteReq); err != nil {
+		return fmt.Errorf("delete message history table failed, %w", err)
+	}
+	return nil
+}
+
+func messageHistoryPrimaryKey(sessionID string, messageID string, validTo int64) *tablestore.PrimaryKey {
+	pk := new(tablestore.PrimaryKey)
+	pk.AddPrimaryKeyColumn(MessageSessionIDField, sessionID)
+	pk.AddPrimaryKeyColumn(MessageMessageIDField, messageID)
+	pk.AddPrimaryKeyColumn(MessageHistoryValidToField, validTo)
+	return pk
+}
+
+func parseMessageVersionFromRow(version *model.MessageVersion, columns []*tablestore.AttributeColumn, primaryKey *tablestore.PrimaryKey) {
+	if primaryKey != nil {
+		for _, col := range primaryKey.PrimaryKeys {
+			if col.ColumnName == MessageHistoryValidToField {
+				version.ValidTo = cast.ToInt64(col.Value)
+			}
+		}
+	}
+	messageColumns := make([]*tablestore.AttributeColumn, 0, len(columns))
+	for _, col := range columns {
+		switch col.ColumnName {
+		case MessageHistoryValidFromField:
+			version.ValidFrom = cast.ToInt64(col.Value)
+		case MessageCreateTimeField:
+			version.CreateTime = cast.ToInt64(col.Value)
+		default:
+			messageColumns = append(messageColumns, col)
+		}
+	}
+	parseMessageFromRow(&version.Message, messageColumns, primaryKey)
+}
+
+// lastMessageVersion returns the latest version of a message in the history table, nil if it was never updated
+func (s *MemoryStore) lastMessageVersion(sessionID string, messageID string) (*model.MessageVersion, error) {
+	startPk := new(tablestore.PrimaryKey)
+	startPk.AddPrimaryKeyColumn(MessageSessionIDField, sessionID)
+	startPk.AddPrimaryKeyColumn(MessageMessageIDField, messageID)
+	startPk.AddPrimaryKeyColumnWithMaxValue(MessageHistoryValidToField)
+	endPk := new(tablestore.PrimaryKey)
+	endPk.AddPrimaryKeyColumn(MessageSessionIDField, sessionID)
+	endPk.AddPrimaryKeyColumn(MessageMessageIDField, messageID)
+	endPk.AddPrimaryKeyColumnWithMinValue(MessageHistoryValidToField)
+	criteria := new(tablestore.RangeRowQueryCriteria)
+	criteria.TableName = s.MessageHistoryTableName
+	criteria.StartPrimaryKey = startPk
+	criteria.EndPrimaryKey = endPk
+	criteria.Direction = tablestore.BACKWARD
+	criteria.MaxVersion = 1
+	criteria.Limit = 1
+	rangeReq := new(tablestore.GetRangeRequest)
+	rangeReq.RangeRowQueryCriteria = criteria
+	resp, err := s.clt.GetRange(rangeReq)
+	if err != nil {
+		return nil, fmt.Errorf("get last message version failed, %w", err)
+	}
+	if len(resp.Rows) == 0 {
+		return nil, nil
+	}
+	version := new(model.MessageVersion)
+	parseMessageVersionFromRow(version, resp.Rows[0].Columns, resp.Rows[0].PrimaryKey)
+	return version, nil
+}
+
+// saveMessageVersion writes current, the version of a message replaced at validTo, to the history table.
+// It is written before the update, so a failed update may leave a version equal to the current message.
+func (s *MemoryStore) saveMessageVersion(current *model.Message, validTo int64) error {
+	validFrom := current.CreateTime
+	last, err := s.lastMessageVersion(current.SessionID, current.MessageID)
+	if err != nil {
+		return err
+	}
+	if last != nil {
+		validFrom = last.ValidTo
+	}
+	rowChange := new(tablestore.PutRowChange)
+	rowChange.TableName = s.MessageHistoryTableName
+	rowChange.PrimaryKey = messageHistoryPrimaryKey(current.SessionID, current.MessageID, validTo)
+	rowChange.AddColumn(MessageHistoryValidFromField, validFrom)
+	rowChange.AddColumn(MessageCreateTimeField, current.CreateTime)
+	if current.UserID != "" {
+		rowChange.AddColumn(MessageUserIDField, current.UserID)
+	}
+	if current.Content != "" {
+		rowChange.AddColumn(MessageContentField, current.Content)
+	}
+	if current.SearchContent != "" {
+		rowChange.AddColumn(MessageSearchContentField, current.SearchContent)
+	}
+	for k, v := range current.Metadata {
+		rowChange.AddColumn(k, v)
+	}
+	rowChange.SetCondition(tablestore.RowExistenceExpectation_IGNORE)
+	putReq := new(tablestore.PutRowRequest)
+	putReq.PutRowChange = rowChange
+	if _, err := s.clt.PutRow(putReq); err != nil {
+		return fmt.Errorf("save message version failed, %w", err)
+	}
+	return nil
+}
+
+// ListMessageVersions list the versions of a message oldest first, the last one is the current message.
+// Embeddings are not kept in the history.
+func (s *MemoryStore) ListMessageVersions(sessionID string, messageID string) ([]model.MessageVersion, error) {
+	current := model.Message{
+		SessionID: sessionID,
+		MessageID: messageID,
+	}
+	if err := s.GetMessage(&current); err != nil {
+		return nil, fmt.Errorf("list message versions failed, %w", err)
+	}
+	startPk := new(tablestore.PrimaryKey)
+	startPk.AddPrimaryKeyColumn(MessageSessionIDField, sessionID)
+	startPk.AddPrimaryKeyColumn(MessageMessageIDField, messageID)
+	startPk.AddPrimaryKeyColumnWithMinValue(MessageHistoryValidToField)
+	endPk := new(tablestore.PrimaryKey)
+	endPk.AddPrimaryKeyColumn(MessageSessionIDField, sessionID)
+	endPk.AddPrimaryKeyColumn(MessageMessageIDField, messageID)
+	endPk.AddPrimaryKeyColumnWithMaxValue(MessageHistoryValidToField)
+	criteria := new(tablestore.RangeRowQueryCriteria)
+	criteria.TableName = s.MessageHistoryTableName
+	criteria.StartPrimaryKey = startPk
+	criteria.EndPrimaryKey = endPk
+	criteria.Direction = tablestore.FORWARD
+	criteria.MaxVersion = 1
+	criteria.Limit = 5000
+	rangeReq := new(tablestore.GetRangeRequest)
+	rangeReq.RangeRowQueryCriteria = criteria
+	var ret []model.MessageVersion
+	for {
+		resp, err := s.clt.GetRange(rangeReq)
+		if err != nil {
+			return nil, fmt.Errorf("list message versions failed, %w", err)
+		}
+		for _, row := range resp.Rows {
+			var version model.MessageVersion
+			parseMessageVersionFromRow(&version, row.Columns, row.PrimaryKey)
+			ret = append(ret, version)
+		}
+		if resp.NextStartPrimaryKey == nil {
+			break
+		}
+		rangeReq.RangeRowQueryCriteria.StartPrimaryKey = resp.NextStartPrimaryKey
+	}
+	currentVersion := model.MessageVersion{
+		Message:   current,
+		ValidFrom: current.CreateTime,
+	}
+	if l := len(ret); l > 0 {
+		currentVersion.ValidFrom = ret[l-1].ValidTo
+	}
+	return append(ret, currentVersion), nil
+}
+
+// GetMessageAt get a message as it was at time at in microseconds, message identifies it by session id,
+// message id and optionally create time. Embeddings of past versions are not kept.
+func (s *MemoryStore) GetMessageAt(message *model.Message, at int64) error {
+	// the first version replaced after at is the one current at at
+	startPk := messageHistoryPrimaryKey(message.SessionID, message.MessageID, at+1)
+	endPk := new(tablestore.PrimaryKey)
+	endPk.AddPrimaryKeyColumn(MessageSessionIDField, message.SessionID)
+	endPk.AddPrimaryKeyColumn(MessageMessageIDField, message.MessageID)
+	endPk.AddPrimaryKeyColumnWithMaxValue(MessageHistoryValidToField)
+	criteria := new(tablestore.RangeRowQueryCriteria)
+	criteria.TableName = s.MessageHistoryTableName
+	criteria.StartPrimaryKey = startPk
+	criteria.EndPrimaryKey = endPk
+	criteria.Direction = tablestore.FORWARD
+	criteria.MaxVersion = 1
+	criteria.Limit = 1
+	rangeReq := new(tablestore.GetRangeRequest)
+	rangeReq.RangeRowQueryCriteria = criteria
+	resp, err := s.clt.GetRange(rangeReq)
+	if err != nil {
+		return fmt.Errorf("get message at %d failed, %w", at, err)
+	}
+	if len(resp.Rows) > 0 {
+		var version model.MessageVersion
+		parseMessageVersionFromRow(&version, resp.Rows[0].Columns, resp.Rows[0].PrimaryKey)
+		if !version.ValidAt(at) {
+			return fmt.Errorf("message not exists at %d", at)
+		}
+		*message = version.Message
+		return nil
+	}
+	if err := s.GetMessage(message); err != nil {
+		return fmt.Errorf("get message at %d failed, %w", at, err)
+	}
+	if at < message.CreateTime {
+		return fmt.Errorf("message not exists at %d", at)
+	}
+	return nil
+}
+
+// deleteHistoryAfterFlush makes writer delete the versions of the messages passed to the returned
+// function once their batch is written, so sweeps only keep one batch of deleted messages in memory.
+// It does nothing when message history is disabled.
+func (s *MemoryStore) deleteHistoryAfterFlush(writer *batchWriter) func(sessionID string, messageID string) {
+	if !s.MessageHistory {
+		return func(string, string) {}
+	}
+	var deleted [][2]string
+	writer.flushed = func() error {
+		defer func() {
+			deleted = deleted[:0]
+		}()
+		for _, v := range deleted {
+			if err := s.deleteMessageHistory(v[0], v[1]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return func(sessionID string, messageID string) {
+		deleted = append(deleted, [2]string{sessionID, messageID})
+	}
+}
+
+// deleteMessageHistory deletes the versions of a message, of all messages of a session when messageID
+// is empty, or of all messages when sessionID is empty too
+func (s *MemoryStore) deleteMessageHistory(sessionID string, messageID string) error {
+	startPk := new(tablestore.PrimaryKey)
+	endPk := new(tablestore.PrimaryKey)
+	if sessionID != "" {
+		startPk.AddPrimaryKeyColumn(MessageSessionIDField, sessionID)
+		endPk.AddPrimaryKeyColumn(MessageSessionIDField, sessionID)
+	} else {
+		startPk.AddPrimaryKeyColumnWithMinValue(MessageSessionIDField)
+		endPk.AddPrimaryKeyColumnWithMaxValue(MessageSessionIDField)
+	}
+	if sessionID != "" && messageID != "" {
+		startPk.AddPrimaryKeyColumn(MessageMessageIDField, messageID)
+		endPk.AddPrimaryKeyColumn(MessageMessageIDField, messageID)
+	} else {
+		startPk.AddPrimaryKeyColumnWithMinValue(MessageMessageIDField)
+		endPk.AddPrimaryKeyColumnWithMaxValue(MessageMessageIDField)
+	}
+	startPk.AddPrimaryKeyColumnWithMinValue(MessageHistoryValidToField)
+	endPk.AddPrimaryKeyColumnWithMaxValue(MessageHistoryValidToField)
+	criteria := new(tablestore.RangeRowQueryCriteria)
+	criteria.TableName = s.MessageHistoryTableName
+	criteria.StartPrimaryKey = startPk
+	criteria.EndPrimaryKey = endPk
+	criteria.Direction = tablestore.FORWARD
+	criteria.MaxVersion = 1
+	criteria.ColumnsToGet = []string{MessageHistoryValidToField}
+	criteria.Limit = 5000
+	rangeReq := new(tablestore.GetRangeRequest)
+	rangeReq.RangeRowQueryCriteria = criteria
+	writer := s.newBatchWriter(0, 0)
+	for {
+		resp, err := s.clt.GetRange(rangeReq)
+		if err != nil {
+			return fmt.Errorf("delete message history failed, %w", err)
+		}
+		for _, row := range resp.Rows {
+			rowChange := new(tablestore.DeleteRowChange)
+			rowChange.TableName = s.MessageHistoryTableName
+			rowChange.PrimaryKey = row.PrimaryKey
+			rowChange.SetCondition(tablestore.RowExistenceExpectation_IGNORE)
+			if err := writer.Add(rowChange, nil); err != nil {
+				return fmt.Errorf("delete message history failed, %w", err)
+			}
+		}
+		if resp.NextStartPrimaryKey == nil {
+			break
+		}
+		rangeReq.RangeRowQueryCriteria.StartPrimaryKey = resp.NextStartPrimaryKey
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("delete message history failed, %w", err)
+	}
+	return nil
+}
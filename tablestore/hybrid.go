@@ -0,0 +1,109 @@
+package tablestore
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore/search"
+	"github.com/golang/protobuf/proto"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+// HybridSearchMessages runs the full-text query of SearchMessages and a KNN vector query in parallel
+// and fuses both rankings, hits are deduplicated by session id and message id
+func (s *MemoryStore) HybridSearchMessages(req model.HybridSearchRequest) (*model.Response[model.HybridHit], error) {
+	if s.Embedder == nil {
+		return nil, errors.New("hybrid search requires an embedder")
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	req.Normalize()
+	searchOpts, err := model.NewSearchOptions(model.QueryModePhrase, model.WithQueryMode(req.QueryMode), model.WithMatchOperator(req.MatchOperator))
+	if err != nil {
+		return nil, err
+	}
+	keywordMatch, err := keywordQuery(MessageSearchContentField, req.Query, searchOpts)
+	if err != nil {
+		return nil, fmt.Errorf("hybrid search messages failed, %w", err)
+	}
+	filter, err := s.messageScopeQuery(req.SessionID, req.UserID, req.Filters)
+	if err != nil {
+		return nil, fmt.Errorf("hybrid search messages failed, %w", err)
+	}
+	embedding, err := s.embed(req.Query)
+	if err != nil {
+		return nil, fmt.Errorf("hybrid search messages failed, %w", err)
+	}
+	var (
+		wg                    sync.WaitGroup
+		keyword, vector       []model.RankedMessage
+		keywordErr, vectorErr error
+	)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		keyword, keywordErr = s.rankMessages(&search.BoolQuery{
+			MustQueries:   []search.Query{keywordMatch},
+			FilterQueries: []search.Query{filter},
+		}, req.CandidateK)
+	}()
+	go func() {
+		defer wg.Done()
+		vector, vectorErr = s.rankMessages(&search.KnnVectorQuery{
+			FieldName:          MessageEmbeddingField,
+			TopK:               proto.Int32(req.CandidateK),
+			Float32QueryVector: embedding,
+			Filter:             filter,
+		}, req.CandidateK)
+	}()
+	wg.Wait()
+	if keywordErr != nil {
+		return nil, fmt.Errorf("hybrid search messages keyword retriever failed, %w", keywordErr)
+	}
+	if vectorErr != nil {
+		return nil, fmt.Errorf("hybrid search messages vector retriever failed, %w", vectorErr)
+	}
+	ret := new(model.Response[model.HybridHit])
+	ret.Hits = model.FuseResults(req, keyword, vector)
+	ret.Total = int64(len(ret.Hits))
+	return ret, nil
+}
+
+// rankMessages runs a single retriever query and returns messages ordered by score
+func (s *MemoryStore) rankMessages(query search.Query, limit int32) ([]model.RankedMessage, error) {
+	searchQuery := search.NewSearchQuery()
+	searchQuery.SetQuery(query)
+	searchQuery.SetSort(&search.Sort{
+		Sorters: []search.Sorter{
+			&search.ScoreSort{
+				Order: search.SortOrder_DESC.Enum(),
+			},
+		},
+	})
+	searchQuery.SetLimit(limit)
+	searchReq := new(tablestore.SearchRequest)
+	searchReq.SetTableName(s.MessageTableName)
+	searchReq.SetIndexName(s.MessageSearchIndexName)
+	searchReq.SetSearchQuery(searchQuery)
+	searchReq.SetColumnsToGet(&tablestore.ColumnsToGet{
+		ReturnAll: true,
+	})
+	resp, err := s.clt.Search(searchReq)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]model.RankedMessage, 0, len(resp.Rows))
+	for idx, row := range resp.Rows {
+		var ranked model.RankedMessage
+		parseMessageFromRow(&ranked.Message, row.Columns, row.PrimaryKey)
+		if idx < len(resp.SearchHits) && resp.SearchHits[idx].Score != nil {
+			ranked.Score = *resp.SearchHits[idx].Score
+		}
+		ret = append(ret, ranked)
+	}
+	return ret, nil
+}
@@ -0,0 +1,465 @@
+package tablestore
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore/search"
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/cast"
+
+	"github.com/bububa/tablestore-memory/model"
+	"github.com/bububa/tablestore-memory/protocol"
+)
+
+// KnowledgeStore stores document chunks of tenants for retrieval augmented generation
+type KnowledgeStore struct {
+	model.KnowledgeOptions
+	tableClient
+}
+
+func NewKnowledgeStore(clt *tablestore.TableStoreClient, opts ...model.KnowledgeOption) *KnowledgeStore {
+	ret := &KnowledgeStore{
+		tableClient: tableClient{clt: clt},
+	}
+	for _, opt := range opts {
+		opt(&ret.KnowledgeOptions)
+	}
+	if ret.TableName == "" {
+		ret.TableName = DefaultKnowledgeTableName
+	}
+	if ret.SearchIndexName == "" {
+		ret.SearchIndexName = DefaultKnowledgeSearchIndexName
+	}
+	if ret.EmbeddingMetric == "" {
+		ret.EmbeddingMetric = tablestore.VectorMetricType_COSINE
+	}
+	if ret.Analyzer.Analyzer == "" {
+		ret.Analyzer = model.AnalyzerOptions{
+			Analyzer:    tablestore.Analyzer_SingleWord,
+			DelimitWord: true,
+		}
+	}
+	ret.Analyzer.Normalize()
+	return ret
+}
+
+var _ protocol.KnowledgeStore = (*KnowledgeStore)(nil)
+
+// InitTable creates the knowledge table and search index if not exist
+func (s *KnowledgeStore) InitTable() error {
+	if err := s.TableOptions.Validate(); err != nil {
+		return fmt.Errorf("invalid table options of %s, %w", s.TableName, err)
+	}
+	describeResp, err := s.describeTableIfExists(s.TableName)
+	if err != nil {
+		return fmt.Errorf("describe knowledge table failed during init knowledge table, %w", err)
+	}
+	if describeResp != nil {
+		if err := s.reconcileTable(s.TableName, s.TableOptions, describeResp); err != nil {
+			return fmt.Errorf("reconcile knowledge table failed during init knowledge table, %w", err)
+		}
+		return s.InitSearchIndex()
+	}
+	tableMeta := new(tablestore.TableMeta)
+	tableMeta.TableName = s.TableName
+	tableMeta.AddPrimaryKeyColumn(KnowledgeTenantIDField, tablestore.PrimaryKeyType_STRING)
+	tableMeta.AddPrimaryKeyColumn(KnowledgeDocumentIDField, tablestore.PrimaryKeyType_STRING)
+	tableMeta.AddPrimaryKeyColumn(KnowledgeChunkIndexField, tablestore.PrimaryKeyType_INTEGER)
+	tableMeta.AddDefinedColumn(KnowledgeContentField, tablestore.DefinedColumn_STRING)
+	createTableRequest := new(tablestore.CreateTableRequest)
+	createTableRequest.TableMeta = tableMeta
+	createTableRequest.TableOption = newTableOption(s.TableOptions)
+	createTableRequest.ReservedThroughput = newReservedThroughput(s.TableOptions)
+	createTableRequest.SSESpecification = s.TableOptions.SSE
+	if _, err := s.clt.CreateTable(createTableRequest); err != nil {
+		return fmt.Errorf("create knowledge table failed, %w", err)
+	}
+	return s.createSearchIndex()
+}
+
+// InitSearchIndex creates the knowledge search index if it does not exist yet
+func (s *KnowledgeStore) InitSearchIndex() error {
+	exists, err := s.searchIndexExists(s.TableName, s.SearchIndexName)
+	if err != nil {
+		return fmt.Errorf("list knowledge search index failed during init knowledge search index, %w", err)
+	}
+	if exists {
+		return s.reconcileSearchIndexTTL(s.TableName, s.SearchIndexName, s.TableOptions)
+	}
+	return s.createSearchIndex()
+}
+
+func (s *KnowledgeStore) createSearchIndex() error {
+	contentSchema, err := textFieldSchema(KnowledgeContentField, s.Analyzer)
+	if err != nil {
+		return fmt.Errorf("create knowledge search index failed, %w", err)
+	}
+	fieldSchemas := []*tablestore.FieldSchema{
+		{
+			FieldName: proto.String(KnowledgeTenantIDField),
+			FieldType: tablestore.FieldType_KEYWORD,
+			Index:     proto.Bool(true),
+		},
+		{
+			FieldName: proto.String(KnowledgeDocumentIDField),
+			FieldType: tablestore.FieldType_KEYWORD,
+			Index:     proto.Bool(true),
+		},
+		{
+			FieldName: proto.String(KnowledgeChunkIndexField),
+			FieldType: tablestore.FieldType_LONG,
+			Index:     proto.Bool(true),
+		},
+		{
+			FieldName: proto.String(KnowledgeUpdateTimeField),
+			FieldType: tablestore.FieldType_LONG,
+			Index:     proto.Bool(true),
+		},
+		contentSchema,
+	}
+	if s.EmbeddingDimension > 0 {
+		fieldSchemas = append(fieldSchemas, vectorFieldSchema(KnowledgeEmbeddingField, s.EmbeddingDimension, s.EmbeddingMetric))
+	}
+	for _, field := range s.MetadataFields {
+		schema, err := indexFieldSchema(field)
+		if err != nil {
+			return fmt.Errorf("create knowledge search index failed, %w", err)
+		}
+		fieldSchemas = append(fieldSchemas, schema)
+	}
+	createReq := new(tablestore.CreateSearchIndexRequest)
+	createReq.TableName = s.TableName
+	createReq.IndexName = s.SearchIndexName
+	createReq.TimeToLive = searchIndexTTL(s.TableOptions)
+	createReq.IndexSchema = &tablestore.IndexSchema{
+		FieldSchemas: fieldSchemas,
+	}
+	if _, err := s.clt.CreateSearchIndex(createReq); err != nil {
+		return fmt.Errorf("create knowledge search index failed, %w", err)
+	}
+	return nil
+}
+
+// DeleteTableAndIndex deletes the knowledge search index and table in order.
+// It refuses to touch a table whose schema does not match the one created by InitTable.
+func (s *KnowledgeStore) DeleteTableAndIndex() error {
+	describeResp, err := s.describeTableIfExists(s.TableName)
+	if err != nil {
+		return fmt.Errorf("describe knowledge table failed during delete knowledge table, %w", err)
+	}
+	if describeResp == nil {
+		return nil
+	}
+	if err := checkKnowledgeTableSchema(describeResp.TableMeta); err != nil {
+		return fmt.Errorf("refuse to delete knowledge table %s, %w", s.TableName, err)
+	}
+	if err := s.deleteSearchIndexIfExists(s.TableName, s.SearchIndexName); err != nil {
+		return fmt.Errorf("delete knowledge search index failed, %w", err)
+	}
+	deleteReq := new(tablestore.DeleteTableRequest)
+	deleteReq.TableName = s.TableName
+	if _, err := s.clt.DeleteTable(deleteReq); err != nil {
+		return fmt.Errorf("delete knowledge table failed, %w", err)
+	}
+	return nil
+}
+
+// PutDocuments writes document chunks in batches, existing chunks are replaced. Chunks of a previous
+// version of a document beyond the new chunks are kept, ReplaceDocument removes them.
+func (s *KnowledgeStore) PutDocuments(documents []*model.Document) error {
+	writer := s.newBatchWriter(0, 0)
+	for _, doc := range documents {
+		if doc.TenantID == "" || doc.DocumentID == "" {
+			return errors.New("tenant id and document id are required")
+		}
+		if len(doc.Embedding) > 0 && int32(len(doc.Embedding)) != s.EmbeddingDimension {
+			return fmt.Errorf("document %s chunk %d has embedding dimension %d, expected %d", doc.DocumentID, doc.ChunkIndex, len(doc.Embedding), s.EmbeddingDimension)
+		}
+		if err := writer.Add(s.documentPutRowChange(doc), nil); err != nil {
+			return fmt.Errorf("put documents failed, %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("put documents failed, %w", err)
+	}
+	return nil
+}
+
+func (s *KnowledgeStore) documentPutRowChange(doc *model.Document) *tablestore.PutRowChange {
+	rowChange := new(tablestore.PutRowChange)
+	rowChange.TableName = s.TableName
+	rowChange.PrimaryKey = documentPrimaryKey(doc.TenantID, doc.DocumentID, doc.ChunkIndex)
+	rowChange.SetCondition(tablestore.RowExistenceExpectation_IGNORE)
+	if doc.Content != "" {
+		rowChange.AddColumn(KnowledgeContentField, doc.Content)
+	}
+	if len(doc.Embedding) > 0 {
+		rowChange.AddColumn(KnowledgeEmbeddingField, encodeVector(doc.Embedding))
+	}
+	updateTime := doc.UpdateTime
+	if updateTime == 0 {
+		updateTime = model.CurrentTimeMicroseconds()
+	}
+	rowChange.AddColumn(KnowledgeUpdateTimeField, updateTime)
+	for k, v := range doc.Metadata {
+		rowChange.AddColumn(k, v)
+	}
+	return rowChange
+}
+
+// ReplaceDocument writes the chunks of a document and deletes the chunks of its previous version that
+// are not among them, so re-ingesting a document with fewer chunks leaves none behind. It returns the
+// number of deleted chunks.
+func (s *KnowledgeStore) ReplaceDocument(tenantID string, documentID string, chunks []*model.Document) (int, error) {
+	if tenantID == "" || documentID == "" {
+		return 0, errors.New("tenant id and document id are required")
+	}
+	current := make(map[int64]struct{}, len(chunks))
+	for _, v := range chunks {
+		if v.TenantID != tenantID || v.DocumentID != documentID {
+			return 0, fmt.Errorf("replace document failed, chunk %d belongs to document %s of tenant %s", v.ChunkIndex, v.DocumentID, v.TenantID)
+		}
+		current[v.ChunkIndex] = struct{}{}
+	}
+	if err := s.PutDocuments(chunks); err != nil {
+		return 0, fmt.Errorf("replace document failed, %w", err)
+	}
+	existing, err := s.ListDocumentChunks(tenantID, documentID)
+	if err != nil {
+		return 0, fmt.Errorf("replace document failed, %w", err)
+	}
+	writer := s.newBatchWriter(0, 0)
+	for _, v := range existing {
+		if _, ok := current[v.ChunkIndex]; ok {
+			continue
+		}
+		rowChange := new(tablestore.DeleteRowChange)
+		rowChange.TableName = s.TableName
+		rowChange.PrimaryKey = documentPrimaryKey(v.TenantID, v.DocumentID, v.ChunkIndex)
+		rowChange.SetCondition(tablestore.RowExistenceExpectation_IGNORE)
+		if err := writer.Add(rowChange, nil); err != nil {
+			return writer.Written(), fmt.Errorf("replace document failed, %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return writer.Written(), fmt.Errorf("replace document failed, %w", err)
+	}
+	return writer.Written(), nil
+}
+
+// GetDocument get a document chunk
+func (s *KnowledgeStore) GetDocument(doc *model.Document) error {
+	getReq := new(tablestore.GetRowRequest)
+	getReq.SingleRowQueryCriteria = new(tablestore.SingleRowQueryCriteria)
+	getReq.SingleRowQueryCriteria.TableName = s.TableName
+	getReq.SingleRowQueryCriteria.PrimaryKey = documentPrimaryKey(doc.TenantID, doc.DocumentID, doc.ChunkIndex)
+	getReq.SingleRowQueryCriteria.MaxVersion = 1
+	resp, err := s.clt.GetRow(getReq)
+	if err != nil {
+		return fmt.Errorf("failed to get document in knowledge store, %w", err)
+	}
+	if len(resp.PrimaryKey.PrimaryKeys) == 0 {
+		return fmt.Errorf("document not exists")
+	}
+	parseDocumentFromRow(doc, resp.Columns, &resp.PrimaryKey)
+	return nil
+}
+
+// ListDocumentChunks list the chunks of a document in order
+func (s *KnowledgeStore) ListDocumentChunks(tenantID string, documentID string) ([]model.Document, error) {
+	startPk := new(tablestore.PrimaryKey)
+	startPk.AddPrimaryKeyColumn(KnowledgeTenantIDField, tenantID)
+	startPk.AddPrimaryKeyColumn(KnowledgeDocumentIDField, documentID)
+	startPk.AddPrimaryKeyColumnWithMinValue(KnowledgeChunkIndexField)
+	endPk := new(tablestore.PrimaryKey)
+	endPk.AddPrimaryKeyColumn(KnowledgeTenantIDField, tenantID)
+	endPk.AddPrimaryKeyColumn(KnowledgeDocumentIDField, documentID)
+	endPk.AddPrimaryKeyColumnWithMaxValue(KnowledgeChunkIndexField)
+	criteria := new(tablestore.RangeRowQueryCriteria)
+	criteria.TableName = s.TableName
+	criteria.StartPrimaryKey = startPk
+	criteria.EndPrimaryKey = endPk
+	criteria.Direction = tablestore.FORWARD
+	criteria.MaxVersion = 1
+	criteria.Limit = 5000
+	rangeReq := new(tablestore.GetRangeRequest)
+	rangeReq.RangeRowQueryCriteria = criteria
+	var ret []model.Document
+	for {
+		resp, err := s.clt.GetRange(rangeReq)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get list of document chunks, %w", err)
+		}
+		for _, row := range resp.Rows {
+			var doc model.Document
+			parseDocumentFromRow(&doc, row.Columns, row.PrimaryKey)
+			ret = append(ret, doc)
+		}
+		if resp.NextStartPrimaryKey == nil {
+			return ret, nil
+		}
+		rangeReq.RangeRowQueryCriteria.StartPrimaryKey = resp.NextStartPrimaryKey
+	}
+}
+
+// DeleteDocument delete all chunks of a document
+func (s *KnowledgeStore) DeleteDocument(tenantID string, documentID string) (int, error) {
+	if tenantID == "" || documentID == "" {
+		return 0, errors.New("tenant id and document id are required")
+	}
+	chunks, err := s.ListDocumentChunks(tenantID, documentID)
+	if err != nil {
+		return 0, fmt.Errorf("delete document failed, %w", err)
+	}
+	writer := s.newBatchWriter(0, 0)
+	for _, v := range chunks {
+		rowChange := new(tablestore.DeleteRowChange)
+		rowChange.TableName = s.TableName
+		rowChange.PrimaryKey = documentPrimaryKey(v.TenantID, v.DocumentID, v.ChunkIndex)
+		rowChange.SetCondition(tablestore.RowExistenceExpectation_IGNORE)
+		if err := writer.Add(rowChange, nil); err != nil {
+			return writer.Written(), fmt.Errorf("delete document failed, %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return writer.Written(), fmt.Errorf("delete document failed, %w", err)
+	}
+	return writer.Written(), nil
+}
+
+// SearchDocuments search document chunks of a tenant. A text query runs a full text match over the
+// content, an embedding runs a KNN vector query, when both are given a chunk matching either is returned.
+func (s *KnowledgeStore) SearchDocuments(req *model.DocumentSearchRequest) (*model.Response[model.Document], error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if len(req.Embedding) > 0 && int32(len(req.Embedding)) != s.EmbeddingDimension {
+		return nil, fmt.Errorf("query embedding dimension %d, expected %d", len(req.Embedding), s.EmbeddingDimension)
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 10
+	}
+	filters := []search.Query{
+		&search.TermQuery{
+			FieldName: KnowledgeTenantIDField,
+			Term:      req.TenantID,
+		},
+	}
+	if len(req.DocumentIDs) > 0 {
+		terms := make([]any, 0, len(req.DocumentIDs))
+		for _, v := range req.DocumentIDs {
+			terms = append(terms, v)
+		}
+		filters = append(filters, &search.TermsQuery{
+			FieldName: KnowledgeDocumentIDField,
+			Terms:     terms,
+		})
+	}
+	for _, v := range req.Filters {
+		filters = append(filters, metadataFilterQuery(v))
+	}
+	queries := make([]search.Query, 0, 2)
+	if req.Query != "" {
+		queries = append(queries, &search.MatchQuery{
+			FieldName: KnowledgeContentField,
+			Text:      req.Query,
+		})
+	}
+	if len(req.Embedding) > 0 {
+		queries = append(queries, &search.KnnVectorQuery{
+			FieldName:          KnowledgeEmbeddingField,
+			TopK:               proto.Int32(pageSize),
+			Float32QueryVector: req.Embedding,
+			Filter: &search.BoolQuery{
+				FilterQueries: filters,
+			},
+		})
+	}
+	boolQuery := &search.BoolQuery{
+		FilterQueries: filters,
+	}
+	if len(queries) == 1 {
+		boolQuery.MustQueries = queries
+	} else {
+		boolQuery.ShouldQueries = queries
+		boolQuery.MinimumShouldMatch = proto.Int32(1)
+	}
+	searchQuery := search.NewSearchQuery()
+	searchQuery.SetQuery(boolQuery)
+	searchQuery.SetSort(&search.Sort{
+		Sorters: []search.Sorter{
+			&search.ScoreSort{
+				Order: search.SortOrder_DESC.Enum(),
+			},
+		},
+	})
+	searchQuery.SetGetTotalCount(true)
+	searchQuery.SetLimit(pageSize)
+	if req.NextToken != nil {
+		searchQuery.SetToken(req.NextToken)
+	}
+	searchReq := new(tablestore.SearchRequest)
+	searchReq.SetTableName(s.TableName)
+	searchReq.SetIndexName(s.SearchIndexName)
+	searchReq.SetSearchQuery(searchQuery)
+	searchReq.SetColumnsToGet(&tablestore.ColumnsToGet{
+		ReturnAll: true,
+	})
+	resp, err := s.clt.Search(searchReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search documents, %w", err)
+	}
+	ret := new(model.Response[model.Document])
+	ret.Total = resp.TotalCount
+	ret.Hits = make([]model.Document, 0, len(resp.Rows))
+	for _, row := range resp.Rows {
+		var doc model.Document
+		parseDocumentFromRow(&doc, row.Columns, row.PrimaryKey)
+		ret.Hits = append(ret.Hits, doc)
+	}
+	if resp.NextToken != nil {
+		ret.NextToken = resp.NextToken
+	}
+	return ret, nil
+}
+
+func documentPrimaryKey(tenantID string, documentID string, chunkIndex int64) *tablestore.PrimaryKey {
+	pk := new(tablestore.PrimaryKey)
+	pk.AddPrimaryKeyColumn(KnowledgeTenantIDField, tenantID)
+	pk.AddPrimaryKeyColumn(KnowledgeDocumentIDField, documentID)
+	pk.AddPrimaryKeyColumn(KnowledgeChunkIndexField, chunkIndex)
+	return pk
+}
+
+func parseDocumentFromRow(doc *model.Document, columns []*tablestore.AttributeColumn, primaryKey *tablestore.PrimaryKey) {
+	if primaryKey != nil {
+		for _, col := range primaryKey.PrimaryKeys {
+			switch col.ColumnName {
+			case KnowledgeTenantIDField:
+				doc.TenantID = cast.ToString(col.Value)
+			case KnowledgeDocumentIDField:
+				doc.DocumentID = cast.ToString(col.Value)
+			case KnowledgeChunkIndexField:
+				doc.ChunkIndex = cast.ToInt64(col.Value)
+			}
+		}
+	}
+	for _, col := range columns {
+		switch col.ColumnName {
+		case KnowledgeContentField:
+			doc.Content = cast.ToString(col.Value)
+		case KnowledgeEmbeddingField:
+			doc.Embedding = decodeVector(col.Value)
+		case KnowledgeUpdateTimeField:
+			doc.UpdateTime = cast.ToInt64(col.Value)
+		default:
+			if doc.Metadata == nil {
+				doc.Metadata = model.NewMetadata()
+			}
+			doc.Metadata[col.ColumnName] = col.Value
+		}
+	}
+}
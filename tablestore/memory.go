@@ -1,20 +1,39 @@
 package tablestore
 
 import (
+	"fmt"
+	"log"
+	"sync"
+
 	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
 
 	"github.com/bububa/tablestore-memory/model"
 	"github.com/bububa/tablestore-memory/protocol"
 )
 
+// tableClient holds the TableStore client and table maintenance helpers shared by stores
+type tableClient struct {
+	clt *tablestore.TableStoreClient
+}
+
 type MemoryStore struct {
 	model.Options
-	clt *tablestore.TableStoreClient
+	tableClient
+	// writes rows written through this store, read by read-your-writes searches
+	writes *writeLog
+	// owners users of sessions recently written through this store, used to stamp messages with their user
+	owners *sessionOwners
+	// indexedFields search index name to the set of its fields, filled on first use
+	indexedFields sync.Map
+	// sessionIndex state of the session secondary index, checked on first use
+	sessionIndex sessionIndexState
+	// streams messages being written by BeginMessage in this process, keyed by message write key
+	streams sync.Map
 }
 
 func NewMemoryStore(clt *tablestore.TableStoreClient, opts ...model.Option) *MemoryStore {
 	ret := &MemoryStore{
-		clt: clt,
+		tableClient: tableClient{clt: clt},
 	}
 	for _, opt := range opts {
 		opt(&ret.Options)
@@ -37,20 +56,148 @@ func NewMemoryStore(clt *tablestore.TableStoreClient, opts ...model.Option) *Mem
 	if ret.MessageSearchIndexName == "" {
 		ret.MessageSearchIndexName = DefaultMessageSearchIndexName
 	}
+	if ret.MessageHistoryTableName == "" {
+		ret.MessageHistoryTableName = DefaultMessageHistoryTableName
+	}
+	if ret.MemoryTableName == "" {
+		ret.MemoryTableName = DefaultMemoryTableName
+	}
+	if ret.MemorySearchIndexName == "" {
+		ret.MemorySearchIndexName = DefaultMemorySearchIndexName
+	}
+	if ret.EmbeddingMetric == "" {
+		ret.EmbeddingMetric = tablestore.VectorMetricType_COSINE
+	}
+	if ret.MemoryAnalyzer.Analyzer == "" {
+		ret.MemoryAnalyzer = model.AnalyzerOptions{
+			Analyzer:    tablestore.Analyzer_SingleWord,
+			DelimitWord: true,
+		}
+	}
+	ret.SessionAnalyzer.Normalize()
+	ret.MessageAnalyzer.Normalize()
+	ret.MemoryAnalyzer.Normalize()
+	if ret.WriteLogTTL <= 0 {
+		ret.WriteLogTTL = model.DefaultWriteLogTTL
+	}
+	if ret.WriteLogSize <= 0 {
+		ret.WriteLogSize = model.DefaultWriteLogSize
+	}
+	if ret.TrashRetention <= 0 {
+		ret.TrashRetention = model.DefaultTrashRetention
+	}
+	if ret.StreamFlushInterval <= 0 {
+		ret.StreamFlushInterval = model.DefaultStreamFlushInterval
+	}
+	if ret.StreamFlushBytes <= 0 {
+		ret.StreamFlushBytes = model.DefaultStreamFlushBytes
+	}
+	ret.writes = newWriteLog(ret.WriteLogTTL, ret.WriteLogSize)
+	ret.owners = newSessionOwners(sessionOwnerCacheSize)
+	ret.Highlight.Normalize()
 	return ret
 }
 
 var _ protocol.MemoryStore = (*MemoryStore)(nil)
 
+// warn reports a problem that does not fail the call to WarningHandler, or logs it
+func (s *MemoryStore) warn(err error) {
+	if s.WarningHandler != nil {
+		s.WarningHandler(err)
+		return
+	}
+	log.Printf("tablestore-memory: %v", err)
+}
+
 func (s *MemoryStore) InitTable() error {
+	for name, opts := range map[string]model.TableOptions{
+		s.SessionTableName: s.SessionTableOptions,
+		s.MessageTableName: s.MessageTableOptions,
+		s.MemoryTableName:  s.MemoryTableOptions,
+	} {
+		if err := opts.Validate(); err != nil {
+			return fmt.Errorf("invalid table options of %s, %w", name, err)
+		}
+	}
 	if err := s.InitSessionTable(); err != nil {
 		return err
 	}
-	return s.InitMessageTable()
+	if err := s.InitMessageTable(); err != nil {
+		return err
+	}
+	if err := s.InitMemoryTable(); err != nil {
+		return err
+	}
+	if s.MessageHistory {
+		if err := s.InitMessageHistoryTable(); err != nil {
+			return err
+		}
+	}
+	if err := s.InitSearchIndex(); err != nil {
+		return err
+	}
+	if s.ReadyTimeout > 0 {
+		return s.WaitForReady(s.ReadyTimeout)
+	}
+	return nil
+}
+
+// InitSearchIndex create session, message and memory search indexes if not exist
+func (s *MemoryStore) InitSearchIndex() error {
+	if err := s.InitSessionSearchIndex(); err != nil {
+		return err
+	}
+	if err := s.InitMessageSearchIndex(); err != nil {
+		return err
+	}
+	return s.InitMemorySearchIndex()
 }
 
-// DeleteSessionAndMessages delete a session and its messages
+// DeleteTableAndIndex delete search indexes, secondary indexes and tables of sessions, messages and memories.
+// Nothing is deleted unless every existing table matches the schema created by InitTable.
+func (s *MemoryStore) DeleteTableAndIndex() error {
+	for tableName, check := range map[string]func(*tablestore.TableMeta) error{
+		s.SessionTableName:        checkSessionTableSchema,
+		s.MessageTableName:        checkMessageTableSchema,
+		s.MemoryTableName:         checkMemoryTableSchema,
+		s.MessageHistoryTableName: checkMessageHistoryTableSchema,
+	} {
+		describeResp, err := s.describeTableIfExists(tableName)
+		if err != nil {
+			return fmt.Errorf("describe table %s failed during delete table and index, %w", tableName, err)
+		}
+		if describeResp == nil {
+			continue
+		}
+		if err := check(describeResp.TableMeta); err != nil {
+			return fmt.Errorf("refuse to delete table %s, %w", tableName, err)
+		}
+	}
+	if err := s.DeleteMemoryTableAndIndex(); err != nil {
+		return err
+	}
+	if err := s.DeleteMessageHistoryTable(); err != nil {
+		return err
+	}
+	if err := s.DeleteMessageTableAndIndex(); err != nil {
+		return err
+	}
+	return s.DeleteSessionTableAndIndex()
+}
+
+// DeleteSessionAndMessages delete a session and its messages, or move them to the trash together when
+// soft delete is enabled so RestoreSession brings both back
 func (s *MemoryStore) DeleteSessionAndMessages(userID, sessionID string) error {
+	if s.SoftDelete {
+		deletedAt := model.CurrentTimeMicroseconds()
+		if err := s.trashSession(userID, sessionID, deletedAt); err != nil {
+			return err
+		}
+		if _, err := s.trashMessages(sessionID, deletedAt); err != nil {
+			return err
+		}
+		return nil
+	}
 	if err := s.DeleteSession(userID, sessionID); err != nil {
 		return err
 	}
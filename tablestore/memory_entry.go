@@ -0,0 +1,470 @@
+package tablestore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore/search"
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/cast"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+// maxPutMemoryAttempts retries of PutMemory when a concurrent writer updated the same memory
+const maxPutMemoryAttempts = 3
+
+var errMemoryNotExists = errors.New("memory not exists")
+
+func (s *MemoryStore) InitMemoryTable() error {
+	describeResp, err := s.describeTableIfExists(s.MemoryTableName)
+	if err != nil {
+		return fmt.Errorf("describe memory table failed during init memory table, %w", err)
+	}
+	if describeResp != nil {
+		searchIndexExists, err := s.searchIndexExists(s.MemoryTableName, s.MemorySearchIndexName)
+		if err != nil {
+			return fmt.Errorf("list memory search index failed during init memory table, %w", err)
+		}
+		if !searchIndexExists {
+			if err := s.createMemorySearchIndex(); err != nil {
+				return fmt.Errorf("create memory table search index failed during init memory table, %w", err)
+			}
+		} else if err := s.reconcileSearchIndexTTL(s.MemoryTableName, s.MemorySearchIndexName, s.MemoryTableOptions); err != nil {
+			return fmt.Errorf("reconcile memory search index failed during init memory table, %w", err)
+		}
+		if err := s.reconcileTable(s.MemoryTableName, s.MemoryTableOptions, describeResp); err != nil {
+			return fmt.Errorf("reconcile memory table failed during init memory table, %w", err)
+		}
+		return nil
+	}
+	tableMeta := new(tablestore.TableMeta)
+	tableMeta.TableName = s.MemoryTableName
+	tableMeta.AddPrimaryKeyColumn(MemoryUserIDField, tablestore.PrimaryKeyType_STRING)
+	tableMeta.AddPrimaryKeyColumn(MemoryMemoryIDField, tablestore.PrimaryKeyType_STRING)
+	tableMeta.AddDefinedColumn(MemoryContentField, tablestore.DefinedColumn_STRING)
+	createTableRequest := new(tablestore.CreateTableRequest)
+	createTableRequest.TableMeta = tableMeta
+	createTableRequest.TableOption = newTableOption(s.MemoryTableOptions)
+	createTableRequest.ReservedThroughput = newReservedThroughput(s.MemoryTableOptions)
+	createTableRequest.SSESpecification = s.MemoryTableOptions.SSE
+	if _, err := s.clt.CreateTable(createTableRequest); err != nil {
+		return fmt.Errorf("create memory table failed, %w", err)
+	}
+	if err := s.createMemorySearchIndex(); err != nil {
+		return fmt.Errorf("create memory table search index failed during init memory table, %w", err)
+	}
+	return nil
+}
+
+func (s *MemoryStore) createMemorySearchIndex() error {
+	contentSchema, err := textFieldSchema(MemoryContentField, s.MemoryAnalyzer)
+	if err != nil {
+		return fmt.Errorf("create memory search index failed, %w", err)
+	}
+	createReq := new(tablestore.CreateSearchIndexRequest)
+	createReq.TableName = s.MemoryTableName
+	createReq.IndexName = s.MemorySearchIndexName
+	createReq.TimeToLive = searchIndexTTL(s.MemoryTableOptions)
+	createReq.IndexSchema = &tablestore.IndexSchema{
+		FieldSchemas: []*tablestore.FieldSchema{
+			{
+				FieldName: proto.String(MemoryUserIDField),
+				FieldType: tablestore.FieldType_KEYWORD,
+				Index:     proto.Bool(true),
+			},
+			contentSchema,
+			{
+				FieldName: proto.String(MemoryImportanceField),
+				FieldType: tablestore.FieldType_DOUBLE,
+				Index:     proto.Bool(true),
+			},
+			{
+				FieldName: proto.String(MemoryUpdateTimeField),
+				FieldType: tablestore.FieldType_LONG,
+				Index:     proto.Bool(true),
+			},
+			{
+				FieldName: proto.String(MemoryExpireTimeField),
+				FieldType: tablestore.FieldType_LONG,
+				Index:     proto.Bool(true),
+			},
+		},
+	}
+	if _, err := s.clt.CreateSearchIndex(createReq); err != nil {
+		return fmt.Errorf("create memory search index failed, %w", err)
+	}
+	return nil
+}
+
+// InitMemorySearchIndex creates the memory search index if it does not exist yet
+func (s *MemoryStore) InitMemorySearchIndex() error {
+	exists, err := s.searchIndexExists(s.MemoryTableName, s.MemorySearchIndexName)
+	if err != nil {
+		return fmt.Errorf("list memory search index failed during init memory search index, %w", err)
+	}
+	if exists {
+		return nil
+	}
+	return s.createMemorySearchIndex()
+}
+
+// DeleteMemoryTableAndIndex deletes the memory search index and table in order.
+// It refuses to touch a table whose schema does not match the one created by InitMemoryTable.
+func (s *MemoryStore) DeleteMemoryTableAndIndex() error {
+	describeResp, err := s.describeTableIfExists(s.MemoryTableName)
+	if err != nil {
+		return fmt.Errorf("describe memory table failed during delete memory table, %w", err)
+	}
+	if describeResp == nil {
+		return nil
+	}
+	if err := checkMemoryTableSchema(describeResp.TableMeta); err != nil {
+		return fmt.Errorf("refuse to delete memory table %s, %w", s.MemoryTableName, err)
+	}
+	if err := s.deleteSearchIndexIfExists(s.MemoryTableName, s.MemorySearchIndexName); err != nil {
+		return fmt.Errorf("delete memory search index failed, %w", err)
+	}
+	deleteReq := new(tablestore.DeleteTableRequest)
+	deleteReq.TableName = s.MemoryTableName
+	if _, err := s.clt.DeleteTable(deleteReq); err != nil {
+		return fmt.Errorf("delete memory table failed, %w", err)
+	}
+	return nil
+}
+
+// PutMemory stores a memory, deduplicated by memory id. When the memory already exists it is merged
+// with the stored copy: sources are combined and the highest importance and latest expiry win.
+// The write is conditioned on the stored update time so concurrent merges are not lost.
+func (s *MemoryStore) PutMemory(memory *model.Memory) error {
+	if memory.UserID == "" {
+		return errors.New("user id is required")
+	}
+	if memory.Content == "" {
+		return errors.New("memory content is required")
+	}
+	if memory.MemoryID == "" {
+		memory.MemoryID = model.MemoryIDFor(memory.Content)
+	}
+	var err error
+	for range maxPutMemoryAttempts {
+		existing := model.Memory{
+			UserID:   memory.UserID,
+			MemoryID: memory.MemoryID,
+		}
+		exists := true
+		if getErr := s.GetMemory(&existing); getErr != nil {
+			if !errors.Is(getErr, errMemoryNotExists) {
+				return fmt.Errorf("put memory failed, %w", getErr)
+			}
+			exists = false
+		}
+		merged := memory.Clone()
+		if exists {
+			merged.Merge(&existing)
+		}
+		now := model.CurrentTimeMicroseconds()
+		if exists && now <= existing.UpdateTime {
+			now = existing.UpdateTime + 1
+		}
+		if merged.CreateTime == 0 {
+			merged.CreateTime = now
+		}
+		merged.UpdateTime = now
+		rowChange, buildErr := s.memoryPutRowChange(merged)
+		if buildErr != nil {
+			return fmt.Errorf("put memory failed, %w", buildErr)
+		}
+		if exists {
+			rowChange.SetCondition(tablestore.RowExistenceExpectation_EXPECT_EXIST)
+			rowChange.SetColumnCondition(tablestore.NewSingleColumnCondition(MemoryUpdateTimeField, tablestore.CT_EQUAL, existing.UpdateTime))
+		} else {
+			rowChange.SetCondition(tablestore.RowExistenceExpectation_EXPECT_NOT_EXIST)
+		}
+		putReq := new(tablestore.PutRowRequest)
+		putReq.PutRowChange = rowChange
+		if _, err = s.clt.PutRow(putReq); err == nil {
+			s.writes.touch(s.MemoryTableName)
+			*memory = *merged
+			return nil
+		}
+		var otsErr *tablestore.OtsError
+		if !errors.As(err, &otsErr) || otsErr.Code != conditionCheckFailCode {
+			break
+		}
+	}
+	return fmt.Errorf("put memory to memory store failed, %w", err)
+}
+
+func (s *MemoryStore) memoryPutRowChange(memory *model.Memory) (*tablestore.PutRowChange, error) {
+	rowChange := new(tablestore.PutRowChange)
+	rowChange.TableName = s.MemoryTableName
+	rowChange.PrimaryKey = memoryPrimaryKey(memory.UserID, memory.MemoryID)
+	rowChange.AddColumn(MemoryContentField, memory.Content)
+	rowChange.AddColumn(MemoryImportanceField, memory.Importance)
+	rowChange.AddColumn(MemoryCreateTimeField, memory.CreateTime)
+	rowChange.AddColumn(MemoryUpdateTimeField, memory.UpdateTime)
+	if memory.ExpireTime > 0 {
+		rowChange.AddColumn(MemoryExpireTimeField, memory.ExpireTime)
+	}
+	if len(memory.Sources) > 0 {
+		sources, err := json.Marshal(memory.Sources)
+		if err != nil {
+			return nil, fmt.Errorf("encode memory sources failed, %w", err)
+		}
+		rowChange.AddColumn(MemorySourcesField, string(sources))
+	}
+	for k, v := range memory.Metadata {
+		rowChange.AddColumn(k, v)
+	}
+	return rowChange, nil
+}
+
+// GetMemory get a memory by user id and memory id, expired memories are still returned
+func (s *MemoryStore) GetMemory(memory *model.Memory) error {
+	getReq := new(tablestore.GetRowRequest)
+	getReq.SingleRowQueryCriteria = new(tablestore.SingleRowQueryCriteria)
+	getReq.SingleRowQueryCriteria.TableName = s.MemoryTableName
+	getReq.SingleRowQueryCriteria.PrimaryKey = memoryPrimaryKey(memory.UserID, memory.MemoryID)
+	getReq.SingleRowQueryCriteria.MaxVersion = 1
+	resp, err := s.clt.GetRow(getReq)
+	if err != nil {
+		return fmt.Errorf("failed to get memory in memory store, %w", err)
+	}
+	if len(resp.PrimaryKey.PrimaryKeys) == 0 {
+		return errMemoryNotExists
+	}
+	parseMemoryFromRow(memory, resp.Columns, &resp.PrimaryKey)
+	return nil
+}
+
+// ListMemories list unexpired memories of a user, most important first
+func (s *MemoryStore) ListMemories(userID string) ([]model.Memory, error) {
+	if userID == "" {
+		return nil, errors.New("user id is required")
+	}
+	memories, err := s.listMemories(userID, unexpiredMemoriesFilter(model.CurrentTimeMicroseconds()))
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(memories, func(i, j int) bool {
+		if memories[i].Importance != memories[j].Importance {
+			return memories[i].Importance > memories[j].Importance
+		}
+		return memories[i].UpdateTime > memories[j].UpdateTime
+	})
+	return memories, nil
+}
+
+func (s *MemoryStore) listMemories(userID string, filter tablestore.ColumnFilter) ([]model.Memory, error) {
+	startPk := new(tablestore.PrimaryKey)
+	startPk.AddPrimaryKeyColumn(MemoryUserIDField, userID)
+	startPk.AddPrimaryKeyColumnWithMinValue(MemoryMemoryIDField)
+	endPk := new(tablestore.PrimaryKey)
+	endPk.AddPrimaryKeyColumn(MemoryUserIDField, userID)
+	endPk.AddPrimaryKeyColumnWithMaxValue(MemoryMemoryIDField)
+	criteria := new(tablestore.RangeRowQueryCriteria)
+	criteria.TableName = s.MemoryTableName
+	criteria.StartPrimaryKey = startPk
+	criteria.EndPrimaryKey = endPk
+	criteria.Direction = tablestore.FORWARD
+	criteria.MaxVersion = 1
+	if filter != nil {
+		criteria.Filter = filter
+	}
+	criteria.Limit = int32(configBatchSize(5000, -1, filter))
+	rangeReq := new(tablestore.GetRangeRequest)
+	rangeReq.RangeRowQueryCriteria = criteria
+	var memories []model.Memory
+	for {
+		resp, err := s.clt.GetRange(rangeReq)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get list of memories, %w", err)
+		}
+		for _, row := range resp.Rows {
+			var memory model.Memory
+			parseMemoryFromRow(&memory, row.Columns, row.PrimaryKey)
+			memories = append(memories, memory)
+		}
+		if resp.NextStartPrimaryKey == nil {
+			return memories, nil
+		}
+		rangeReq.RangeRowQueryCriteria.StartPrimaryKey = resp.NextStartPrimaryKey
+	}
+}
+
+// SearchMemories full text search unexpired memories of a user ordered by relevance and importance
+func (s *MemoryStore) SearchMemories(userID string, keyword string, pageSize int32, nextToken []byte, opts ...model.SearchOption) (*model.Response[model.Memory], error) {
+	if userID == "" {
+		return nil, errors.New("user id is required")
+	}
+	searchOpts, err := model.NewSearchOptions(model.QueryModeMatch, opts...)
+	if err != nil {
+		return nil, err
+	}
+	boolQuery := &search.BoolQuery{
+		MustQueries: []search.Query{
+			&search.TermQuery{
+				FieldName: MemoryUserIDField,
+				Term:      userID,
+			},
+		},
+		MustNotQueries: []search.Query{
+			&search.RangeQuery{
+				FieldName:    MemoryExpireTimeField,
+				From:         int64(1),
+				To:           model.CurrentTimeMicroseconds(),
+				IncludeLower: true,
+				IncludeUpper: true,
+			},
+		},
+	}
+	if keyword != "" {
+		query, err := keywordQuery(MemoryContentField, keyword, searchOpts)
+		if err != nil {
+			return nil, err
+		}
+		boolQuery.MustQueries = append(boolQuery.MustQueries, query)
+	}
+	searchQuery := search.NewSearchQuery()
+	searchQuery.SetQuery(boolQuery)
+	querySort, err := searchSort(searchOpts, []string{MemoryImportanceField, MemoryUpdateTimeField, MemoryExpireTimeField},
+		&search.ScoreSort{
+			Order: search.SortOrder_DESC.Enum(),
+		},
+		&search.FieldSort{
+			FieldName: MemoryImportanceField,
+			Order:     search.SortOrder_DESC.Enum(),
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	searchQuery.SetSort(querySort)
+	searchQuery.SetGetTotalCount(true)
+	searchQuery.SetLimit(pageSize)
+	if nextToken != nil {
+		searchQuery.SetToken(nextToken)
+	}
+	searchReq := new(tablestore.SearchRequest)
+	searchReq.SetTableName(s.MemoryTableName)
+	searchReq.SetIndexName(s.MemorySearchIndexName)
+	searchReq.SetSearchQuery(searchQuery)
+	searchReq.SetColumnsToGet(&tablestore.ColumnsToGet{
+		ReturnAll: true,
+	})
+	// memories are not kept in the write log, merging writes waits for the index instead
+	if searchOpts.Consistency != model.ConsistencyEventual {
+		if err := s.waitForIndex(s.MemoryTableName, s.MemorySearchIndexName, searchOpts.ConsistencyTimeout); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := s.clt.Search(searchReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search memories, %w", err)
+	}
+	ret := new(model.Response[model.Memory])
+	ret.Total = resp.TotalCount
+	ret.Hits = make([]model.Memory, 0, len(resp.Rows))
+	for _, row := range resp.Rows {
+		var memory model.Memory
+		parseMemoryFromRow(&memory, row.Columns, row.PrimaryKey)
+		ret.Hits = append(ret.Hits, memory)
+	}
+	if resp.NextToken != nil {
+		ret.NextToken = resp.NextToken
+	}
+	return ret, nil
+}
+
+// ForgetMemory delete a memory of a user
+func (s *MemoryStore) ForgetMemory(userID string, memoryID string) error {
+	deleteReq := new(tablestore.DeleteRowRequest)
+	deleteReq.DeleteRowChange = new(tablestore.DeleteRowChange)
+	deleteReq.DeleteRowChange.TableName = s.MemoryTableName
+	deleteReq.DeleteRowChange.PrimaryKey = memoryPrimaryKey(userID, memoryID)
+	deleteReq.DeleteRowChange.SetCondition(tablestore.RowExistenceExpectation_EXPECT_EXIST)
+	if _, err := s.clt.DeleteRow(deleteReq); err != nil {
+		return fmt.Errorf("delete memory in memory store failed, %w", err)
+	}
+	s.writes.touch(s.MemoryTableName)
+	return nil
+}
+
+// ForgetMemories delete all memories of a user including expired ones
+func (s *MemoryStore) ForgetMemories(userID string) (int, error) {
+	if userID == "" {
+		return 0, errors.New("user id is required")
+	}
+	memories, err := s.listMemories(userID, nil)
+	if err != nil {
+		return 0, fmt.Errorf("delete user memories failed, %w", err)
+	}
+	writer := s.newBatchWriter(0, 0)
+	for _, v := range memories {
+		rowChange := new(tablestore.DeleteRowChange)
+		rowChange.TableName = s.MemoryTableName
+		rowChange.PrimaryKey = memoryPrimaryKey(v.UserID, v.MemoryID)
+		rowChange.SetCondition(tablestore.RowExistenceExpectation_IGNORE)
+		if err := writer.Add(rowChange, nil); err != nil {
+			return writer.Written(), fmt.Errorf("delete user memories failed, %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return writer.Written(), fmt.Errorf("delete user memories failed, %w", err)
+	}
+	s.writes.touch(s.MemoryTableName)
+	return writer.Written(), nil
+}
+
+// unexpiredMemoriesFilter passes memories without expiry or expiring after now
+func unexpiredMemoriesFilter(now int64) tablestore.ColumnFilter {
+	return tablestore.NewSingleColumnCondition(MemoryExpireTimeField, tablestore.CT_GREATER_THAN, now)
+}
+
+func memoryPrimaryKey(userID string, memoryID string) *tablestore.PrimaryKey {
+	pk := new(tablestore.PrimaryKey)
+	pk.AddPrimaryKeyColumn(MemoryUserIDField, userID)
+	pk.AddPrimaryKeyColumn(MemoryMemoryIDField, memoryID)
+	return pk
+}
+
+func parseMemoryFromRow(memory *model.Memory, columns []*tablestore.AttributeColumn, primaryKey *tablestore.PrimaryKey) {
+	if primaryKey != nil {
+		for _, col := range primaryKey.PrimaryKeys {
+			switch col.ColumnName {
+			case MemoryUserIDField:
+				memory.UserID = cast.ToString(col.Value)
+			case MemoryMemoryIDField:
+				memory.MemoryID = cast.ToString(col.Value)
+			}
+		}
+	}
+	for _, col := range columns {
+		switch col.ColumnName {
+		case MemoryContentField:
+			memory.Content = cast.ToString(col.Value)
+		case MemoryImportanceField:
+			memory.Importance = cast.ToFloat64(col.Value)
+		case MemoryCreateTimeField:
+			memory.CreateTime = cast.ToInt64(col.Value)
+		case MemoryUpdateTimeField:
+			memory.UpdateTime = cast.ToInt64(col.Value)
+		case MemoryExpireTimeField:
+			memory.ExpireTime = cast.ToInt64(col.Value)
+		case MemorySourcesField:
+			var sources []model.MemorySource
+			if err := json.Unmarshal([]byte(cast.ToString(col.Value)), &sources); err == nil {
+				memory.Sources = sources
+			}
+		default:
+			if memory.Metadata == nil {
+				memory.Metadata = model.NewMetadata()
+			}
+			memory.Metadata[col.ColumnName] = col.Value
+		}
+	}
+}
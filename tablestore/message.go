@@ -1,6 +1,7 @@
 package tablestore
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
 	"slices"
@@ -48,24 +49,20 @@ func (s *MemoryStore) InitMessageTable() error {
 				return fmt.Errorf("create message table secondary index failed during init message table, %w", err)
 			}
 		}
-		searchIndexExists := false
-		listSearchIndexReq := new(tablestore.ListSearchIndexRequest)
-		listSearchIndexReq.TableName = s.MessageTableName
-		indexResp, err := s.clt.ListSearchIndex(listSearchIndexReq)
+		searchIndexExists, err := s.searchIndexExists(s.MessageTableName, s.MessageSearchIndexName)
 		if err != nil {
 			return fmt.Errorf("list message search index failed during init message table, %w", err)
 		}
-		for _, v := range indexResp.IndexInfo {
-			if v.IndexName == s.MessageSearchIndexName {
-				searchIndexExists = true
-				break
-			}
-		}
 
 		if !searchIndexExists {
 			if err := s.createMessageSearchIndex(); err != nil {
 				return fmt.Errorf("create message table search index failed during init message table, %w", err)
 			}
+		} else if err := s.reconcileSearchIndexTTL(s.MessageTableName, s.MessageSearchIndexName, s.MessageTableOptions); err != nil {
+			return fmt.Errorf("reconcile message search index failed during init message table, %w", err)
+		}
+		if err := s.reconcileTable(s.MessageTableName, s.MessageTableOptions, describeResp); err != nil {
+			return fmt.Errorf("reconcile message table failed during init message table, %w", err)
 		}
 		return nil
 	}
@@ -75,12 +72,8 @@ func (s *MemoryStore) InitMessageTable() error {
 	tableMeta.AddPrimaryKeyColumn(MessageCreateTimeField, tablestore.PrimaryKeyType_INTEGER)
 	tableMeta.AddPrimaryKeyColumn(MessageMessageIDField, tablestore.PrimaryKeyType_STRING)
 	tableMeta.AddDefinedColumn(MessageContentField, tablestore.DefinedColumn_STRING)
-	tableOption := new(tablestore.TableOption)
-	tableOption.MaxVersion = 1
-	tableOption.TimeToAlive = -1
-	reservedThroughput := new(tablestore.ReservedThroughput)
-	reservedThroughput.Readcap = 0
-	reservedThroughput.Writecap = 0
+	tableOption := newTableOption(s.MessageTableOptions)
+	reservedThroughput := newReservedThroughput(s.MessageTableOptions)
 	indexMeta := new(tablestore.IndexMeta)
 	indexMeta.IndexName = s.MessageSecondaryIndexName
 	indexMeta.AddPrimaryKeyColumn(MessageSessionIDField)
@@ -91,6 +84,7 @@ func (s *MemoryStore) InitMessageTable() error {
 	createTableRequest.TableMeta = tableMeta
 	createTableRequest.TableOption = tableOption
 	createTableRequest.ReservedThroughput = reservedThroughput
+	createTableRequest.SSESpecification = s.MessageTableOptions.SSE
 	createTableRequest.AddIndexMeta(indexMeta)
 	if _, err := s.clt.CreateTable(createTableRequest); err != nil {
 		return fmt.Errorf("create message table failed, %w", err)
@@ -99,10 +93,14 @@ func (s *MemoryStore) InitMessageTable() error {
 }
 
 func (s *MemoryStore) createMessageSearchIndex() error {
-	analyzer := tablestore.Analyzer_Fuzzy
+	contentSchema, err := textFieldSchema(MessageSearchContentField, s.MessageAnalyzer)
+	if err != nil {
+		return fmt.Errorf("create message search index failed, %w", err)
+	}
 	createReq := new(tablestore.CreateSearchIndexRequest)
 	createReq.TableName = s.MessageTableName
 	createReq.IndexName = s.MessageSearchIndexName
+	createReq.TimeToLive = searchIndexTTL(s.MessageTableOptions)
 	createReq.IndexSchema = &tablestore.IndexSchema{
 		FieldSchemas: []*tablestore.FieldSchema{
 			{
@@ -116,49 +114,137 @@ func (s *MemoryStore) createMessageSearchIndex() error {
 				Index:     proto.Bool(true),
 			},
 			{
-				FieldName: proto.String(MessageSearchContentField),
-				FieldType: tablestore.FieldType_TEXT,
+				FieldName: proto.String(MessageUserIDField),
+				FieldType: tablestore.FieldType_KEYWORD,
 				Index:     proto.Bool(true),
-				Analyzer:  &analyzer,
-				AnalyzerParameter: tablestore.FuzzyAnalyzerParameter{
-					MinChars: 1,
-					MaxChars: 7,
-				},
 			},
+			{
+				FieldName: proto.String(MessageDeletedAtField),
+				FieldType: tablestore.FieldType_LONG,
+				Index:     proto.Bool(true),
+			},
+			{
+				FieldName: proto.String(model.MetadataCompactedByKey),
+				FieldType: tablestore.FieldType_KEYWORD,
+				Index:     proto.Bool(true),
+			},
+			contentSchema,
 		},
 	}
-	_, err := s.clt.CreateSearchIndex(createReq)
-	if err != nil {
+	if s.Embedder != nil {
+		createReq.IndexSchema.FieldSchemas = append(createReq.IndexSchema.FieldSchemas, vectorFieldSchema(MessageEmbeddingField, int32(s.Embedder.Dimension()), s.EmbeddingMetric))
+	}
+	for _, field := range s.MessageIndexFields {
+		schema, err := indexFieldSchema(field)
+		if err != nil {
+			return fmt.Errorf("create message search index failed, %w", err)
+		}
+		createReq.IndexSchema.FieldSchemas = append(createReq.IndexSchema.FieldSchemas, schema)
+	}
+	if _, err := s.clt.CreateSearchIndex(createReq); err != nil {
 		return fmt.Errorf("create message search index failed, %w", err)
 	}
 	return nil
 }
 
-func (s *MemoryStore) PutMessage(message *model.Message) error {
-	pk := new(tablestore.PrimaryKey)
-	pk.AddPrimaryKeyColumn(MessageSessionIDField, message.SessionID)
-	pk.AddPrimaryKeyColumn(MessageCreateTimeField, message.CreateTime)
-	pk.AddPrimaryKeyColumn(MessageMessageIDField, message.MessageID)
-	putReq := new(tablestore.PutRowRequest)
-	putReq.PutRowChange = new(tablestore.PutRowChange)
-	putReq.PutRowChange.TableName = s.MessageTableName
-	putReq.PutRowChange.PrimaryKey = pk
-	if message.Content != "" {
-		putReq.PutRowChange.AddColumn(MessageContentField, message.Content)
+// InitMessageSearchIndex creates the message search index if it does not exist yet
+func (s *MemoryStore) InitMessageSearchIndex() error {
+	exists, err := s.searchIndexExists(s.MessageTableName, s.MessageSearchIndexName)
+	if err != nil {
+		return fmt.Errorf("list message search index failed during init message search index, %w", err)
 	}
-	if message.SearchContent != "" {
-		putReq.PutRowChange.AddColumn(MessageSearchContentField, message.SearchContent)
+	if exists {
+		return nil
 	}
-	for k, v := range message.Metadata {
-		putReq.PutRowChange.AddColumn(k, v)
+	return s.createMessageSearchIndex()
+}
+
+// DeleteMessageTableAndIndex deletes the message search index, secondary index and table in order.
+// It refuses to touch a table whose schema does not match the one created by InitMessageTable.
+func (s *MemoryStore) DeleteMessageTableAndIndex() error {
+	describeResp, err := s.describeTableIfExists(s.MessageTableName)
+	if err != nil {
+		return fmt.Errorf("describe message table failed during delete message table, %w", err)
+	}
+	if describeResp == nil {
+		return nil
 	}
+	if err := checkMessageTableSchema(describeResp.TableMeta); err != nil {
+		return fmt.Errorf("refuse to delete message table %s, %w", s.MessageTableName, err)
+	}
+	if err := s.deleteSearchIndexIfExists(s.MessageTableName, s.MessageSearchIndexName); err != nil {
+		return fmt.Errorf("delete message search index failed, %w", err)
+	}
+	if err := s.deleteSecondaryIndexIfExists(s.MessageTableName, s.MessageSecondaryIndexName, describeResp.IndexMetas); err != nil {
+		return fmt.Errorf("delete message secondary index failed, %w", err)
+	}
+	deleteReq := new(tablestore.DeleteTableRequest)
+	deleteReq.TableName = s.MessageTableName
+	if _, err := s.clt.DeleteTable(deleteReq); err != nil {
+		return fmt.Errorf("delete message table failed, %w", err)
+	}
+	return nil
+}
+
+func (s *MemoryStore) PutMessage(message *model.Message) error {
+	if err := s.ensureMessageEmbedding(message); err != nil {
+		return fmt.Errorf("put message to memory store failed, %w", err)
+	}
+	if message.UserID == "" {
+		userID, err := s.resolveSessionOwner(message.SessionID)
+		if err != nil {
+			return fmt.Errorf("put message to memory store failed, %w", err)
+		}
+		message.UserID = userID
+	}
+	putReq := new(tablestore.PutRowRequest)
+	putReq.PutRowChange = s.messagePutRowChange(message)
 	putReq.PutRowChange.SetCondition(tablestore.RowExistenceExpectation_IGNORE)
 	if _, err := s.clt.PutRow(putReq); err != nil {
 		return fmt.Errorf("put message to memory store failed, %w", err)
 	}
+	s.writes.record(s.MessageTableName, messageWriteKey(message.SessionID, message.MessageID), message.Clone())
 	return nil
 }
 
+func (s *MemoryStore) messagePutRowChange(message *model.Message) *tablestore.PutRowChange {
+	rowChange := new(tablestore.PutRowChange)
+	rowChange.TableName = s.MessageTableName
+	rowChange.PrimaryKey = messagePrimaryKey(message.SessionID, message.CreateTime, message.MessageID)
+	if userID := cmp.Or(message.UserID, s.sessionOwner(message.SessionID)); userID != "" {
+		rowChange.AddColumn(MessageUserIDField, userID)
+	}
+	if message.ParentMessageID != "" {
+		rowChange.AddColumn(MessageParentIDField, message.ParentMessageID)
+	}
+	if message.BranchID != "" {
+		rowChange.AddColumn(MessageBranchIDField, message.BranchID)
+	}
+	if message.Content != "" {
+		rowChange.AddColumn(MessageContentField, message.Content)
+	}
+	if message.SearchContent != "" {
+		rowChange.AddColumn(MessageSearchContentField, message.SearchContent)
+	}
+	if len(message.Embedding) > 0 {
+		rowChange.AddColumn(MessageEmbeddingField, encodeVector(message.Embedding))
+	}
+	if message.DeletedAt > 0 {
+		rowChange.AddColumn(MessageDeletedAtField, message.DeletedAt)
+	}
+	if message.StreamStatus != "" {
+		rowChange.AddColumn(MessageStreamStatusField, string(message.StreamStatus))
+	}
+	if message.StreamUpdateTime > 0 {
+		rowChange.AddColumn(MessageStreamUpdateField, message.StreamUpdateTime)
+	}
+	for k, v := range message.Metadata {
+		rowChange.AddColumn(k, v)
+	}
+	return rowChange
+}
+
+// UpdateMessage update a message, with message history enabled the replaced version is kept first
 func (s *MemoryStore) UpdateMessage(message *model.Message) error {
 	tmp := model.Message{
 		SessionID:  message.SessionID,
@@ -172,6 +258,14 @@ func (s *MemoryStore) UpdateMessage(message *model.Message) error {
 		// CreateTime is already populated by the GetMessage call above
 		message.CreateTime = tmp.CreateTime
 	}
+	// the place of the message in the conversation tree is kept when the caller leaves it unset
+	message.ParentMessageID = cmp.Or(message.ParentMessageID, tmp.ParentMessageID)
+	message.BranchID = cmp.Or(message.BranchID, tmp.BranchID)
+	if s.MessageHistory {
+		if err := s.saveMessageVersion(&tmp, model.CurrentTimeMicroseconds()); err != nil {
+			return fmt.Errorf("update message failed, %w", err)
+		}
+	}
 	pk := new(tablestore.PrimaryKey)
 	pk.AddPrimaryKeyColumn(MessageSessionIDField, message.SessionID)
 	pk.AddPrimaryKeyColumn(MessageCreateTimeField, message.CreateTime)
@@ -180,6 +274,18 @@ func (s *MemoryStore) UpdateMessage(message *model.Message) error {
 	updateReq.UpdateRowChange = new(tablestore.UpdateRowChange)
 	updateReq.UpdateRowChange.TableName = s.MessageTableName
 	updateReq.UpdateRowChange.PrimaryKey = pk
+	if message.UserID == "" {
+		message.UserID = cmp.Or(tmp.UserID, s.sessionOwner(message.SessionID))
+	}
+	if message.UserID != "" {
+		updateReq.UpdateRowChange.PutColumn(MessageUserIDField, message.UserID)
+	}
+	if message.ParentMessageID != "" {
+		updateReq.UpdateRowChange.PutColumn(MessageParentIDField, message.ParentMessageID)
+	}
+	if message.BranchID != "" {
+		updateReq.UpdateRowChange.PutColumn(MessageBranchIDField, message.BranchID)
+	}
 	if message.Content != "" {
 		updateReq.UpdateRowChange.PutColumn(MessageContentField, message.Content)
 	} else {
@@ -190,6 +296,18 @@ func (s *MemoryStore) UpdateMessage(message *model.Message) error {
 	} else {
 		updateReq.UpdateRowChange.DeleteColumn(MessageSearchContentField)
 	}
+	// the stored vector read back with the message no longer matches edited text
+	if message.EmbeddingText() != tmp.EmbeddingText() && slices.Equal(message.Embedding, tmp.Embedding) {
+		message.Embedding = nil
+	}
+	if err := s.ensureMessageEmbedding(message); err != nil {
+		return fmt.Errorf("update message in memory store failed, %w", err)
+	}
+	if len(message.Embedding) > 0 {
+		updateReq.UpdateRowChange.PutColumn(MessageEmbeddingField, encodeVector(message.Embedding))
+	} else {
+		updateReq.UpdateRowChange.DeleteColumn(MessageEmbeddingField)
+	}
 	for k, v := range message.Metadata {
 		updateReq.UpdateRowChange.PutColumn(k, v)
 	}
@@ -202,9 +320,11 @@ func (s *MemoryStore) UpdateMessage(message *model.Message) error {
 	if _, err := s.clt.UpdateRow(updateReq); err != nil {
 		return fmt.Errorf("update message in memory store failed, %w", err)
 	}
+	s.writes.record(s.MessageTableName, messageWriteKey(message.SessionID, message.MessageID), message.Clone())
 	return nil
 }
 
+// DeleteMessage delete a message, or move it to the trash when soft delete is enabled
 func (s *MemoryStore) DeleteMessage(sessionID string, messageID string, createTime int64) error {
 	if createTime == 0 {
 		tmp := model.Message{
@@ -216,6 +336,9 @@ func (s *MemoryStore) DeleteMessage(sessionID string, messageID string, createTi
 		}
 		createTime = tmp.CreateTime
 	}
+	if s.SoftDelete {
+		return s.trashMessage(sessionID, messageID, createTime, model.CurrentTimeMicroseconds())
+	}
 	pk := new(tablestore.PrimaryKey)
 	pk.AddPrimaryKeyColumn(MessageSessionIDField, sessionID)
 	pk.AddPrimaryKeyColumn(MessageCreateTimeField, createTime)
@@ -228,19 +351,24 @@ func (s *MemoryStore) DeleteMessage(sessionID string, messageID string, createTi
 	if _, err := s.clt.DeleteRow(deleteReq); err != nil {
 		return fmt.Errorf("delete message in memory store failed, %w", err)
 	}
+	s.writes.record(s.MessageTableName, messageWriteKey(sessionID, messageID), nil)
+	if s.MessageHistory {
+		if err := s.deleteMessageHistory(sessionID, messageID); err != nil {
+			return fmt.Errorf("delete message in memory store failed, %w", err)
+		}
+	}
 	return nil
 }
 
-// DeleteMessages delete all messages for a session
+// DeleteMessages delete all messages for a session, including messages in the trash and their history
 func (s *MemoryStore) DeleteMessages(sessionID string) (int, error) {
-	list := s.ListMessages(sessionID)
 	var (
 		count int
 		total int
 	)
 	// Process items in batches of 200
 	currentBatch := new(tablestore.BatchWriteRowRequest)
-	for v := range list {
+	if err := s.scanMessages(sessionID, nil, func(v *model.Message) error {
 		rowChange := new(tablestore.DeleteRowChange)
 		rowChange.TableName = s.MessageTableName
 		rowChange.SetCondition(tablestore.RowExistenceExpectation_IGNORE)
@@ -254,13 +382,16 @@ func (s *MemoryStore) DeleteMessages(sessionID string) (int, error) {
 
 		if count >= 200 {
 			if _, err := s.clt.BatchWriteRow(currentBatch); err != nil {
-				return total, fmt.Errorf("delete session messages failed, %w", err)
+				return err
 			}
 			total += count
 			count = 0
 			// Create a new batch request for next batch
 			currentBatch = new(tablestore.BatchWriteRowRequest)
 		}
+		return nil
+	}); err != nil {
+		return total, fmt.Errorf("delete session messages failed, %w", err)
 	}
 	if count > 0 {
 		if _, err := s.clt.BatchWriteRow(currentBatch); err != nil {
@@ -268,11 +399,16 @@ func (s *MemoryStore) DeleteMessages(sessionID string) (int, error) {
 		}
 		total += count
 	}
+	if s.MessageHistory {
+		if err := s.deleteMessageHistory(sessionID, ""); err != nil {
+			return total, fmt.Errorf("delete session messages failed, %w", err)
+		}
+	}
 	return total, nil
 }
 
 func (s *MemoryStore) DeleteAllMessages() (int, error) {
-	list := s.ListAllMessages()
+	list := s.listMessagesWithFilter("", nil, 0, 0, tablestore.FORWARD, -1, 5000)
 	var (
 		count int
 		total int
@@ -307,10 +443,26 @@ func (s *MemoryStore) DeleteAllMessages() (int, error) {
 		}
 		total += count
 	}
+	if s.MessageHistory {
+		if err := s.deleteMessageHistory("", ""); err != nil {
+			return total, fmt.Errorf("delete all messages failed, %w", err)
+		}
+	}
 	return total, nil
 }
 
+// GetMessage get a message, messages in the trash are reported as not existing
 func (s *MemoryStore) GetMessage(message *model.Message) error {
+	if err := s.getMessage(message); err != nil {
+		return err
+	}
+	if message.IsDeleted() {
+		return fmt.Errorf("message not exists")
+	}
+	return nil
+}
+
+func (s *MemoryStore) getMessage(message *model.Message) error {
 	if message.CreateTime == 0 {
 		tmp := model.Message{
 			SessionID: message.SessionID,
@@ -351,7 +503,7 @@ func (s *MemoryStore) ListMessages(sessionID string) <-chan model.Message {
 	return s.ListMessagesWithFilter(sessionID, nil, 0, 0, tablestore.FORWARD, -1, 5000)
 }
 
-// ListMessagesWithFilter  list messages with filters
+// ListMessagesWithFilter  list messages with filters, skipping messages in the trash
 func (s *MemoryStore) ListMessagesWithFilter(
 	sessionID string,
 	filter tablestore.ColumnFilter,
@@ -360,6 +512,31 @@ func (s *MemoryStore) ListMessagesWithFilter(
 	order tablestore.Direction,
 	maxCount int,
 	batchSize int,
+) <-chan model.Message {
+	return s.listStoredMessages(sessionID, andColumnFilters(filter, s.activeMessagesFilter()), inclusiveStartCreateTime, inclusiveEndCreateTime, order, maxCount, batchSize)
+}
+
+// listStoredMessages list messages with filters, skipping messages in the trash but not archived ones
+func (s *MemoryStore) listStoredMessages(
+	sessionID string,
+	filter tablestore.ColumnFilter,
+	inclusiveStartCreateTime int64,
+	inclusiveEndCreateTime int64,
+	order tablestore.Direction,
+	maxCount int,
+	batchSize int,
+) <-chan model.Message {
+	return s.listMessagesWithFilter(sessionID, andColumnFilters(filter, notDeletedFilter(MessageDeletedAtField)), inclusiveStartCreateTime, inclusiveEndCreateTime, order, maxCount, batchSize)
+}
+
+func (s *MemoryStore) listMessagesWithFilter(
+	sessionID string,
+	filter tablestore.ColumnFilter,
+	inclusiveStartCreateTime int64,
+	inclusiveEndCreateTime int64,
+	order tablestore.Direction,
+	maxCount int,
+	batchSize int,
 ) <-chan model.Message {
 	var (
 		constMin = tablestore.MIN
@@ -444,7 +621,7 @@ func (s *MemoryStore) ListMessagesWithFilter(
 	return retCh
 }
 
-// ListMessagesPaginated  paginated messages
+// ListMessagesPaginated  paginated messages, skipping messages in the trash
 func (s *MemoryStore) ListMessagesPaginated(
 	sessionID string,
 	filter tablestore.ColumnFilter,
@@ -453,6 +630,41 @@ func (s *MemoryStore) ListMessagesPaginated(
 	order tablestore.Direction,
 	pageSize int,
 	nextStartPrimaryKey *tablestore.PrimaryKey,
+) (*model.Response[model.Message], error) {
+	filter = andColumnFilters(filter, s.activeMessagesFilter(), notDeletedFilter(MessageDeletedAtField))
+	return s.listMessagesPage(sessionID, filter, inclusiveStartCreateTime, inclusiveEndCreateTime, order, pageSize, nextStartPrimaryKey)
+}
+
+// scanMessages call fn with the messages of a session, or of all sessions when sessionID is empty,
+// including messages in the trash. Unlike the listing channels it stops at the first read error.
+func (s *MemoryStore) scanMessages(sessionID string, filter tablestore.ColumnFilter, fn func(message *model.Message) error) error {
+	var nextStartPrimaryKey *tablestore.PrimaryKey
+	for {
+		page, err := s.listMessagesPage(sessionID, filter, 0, 0, tablestore.FORWARD, 5000, nextStartPrimaryKey)
+		if err != nil {
+			return err
+		}
+		for i := range page.Hits {
+			if err := fn(&page.Hits[i]); err != nil {
+				return err
+			}
+		}
+		if page.NextStartPrimaryKey == nil {
+			return nil
+		}
+		nextStartPrimaryKey = page.NextStartPrimaryKey
+	}
+}
+
+// listMessagesPage page of messages with filter, including messages in the trash
+func (s *MemoryStore) listMessagesPage(
+	sessionID string,
+	filter tablestore.ColumnFilter,
+	inclusiveStartCreateTime int64,
+	inclusiveEndCreateTime int64,
+	order tablestore.Direction,
+	pageSize int,
+	nextStartPrimaryKey *tablestore.PrimaryKey,
 ) (*model.Response[model.Message], error) {
 	var (
 		constMin = tablestore.MIN
@@ -552,15 +764,61 @@ func (s *MemoryStore) getMessageCreateTimeFromSecondaryIndex(message *model.Mess
 	return nil
 }
 
-func (s *MemoryStore) SearchMessages(sessionID string, keyword string, inclusiveStartCreateTime int64, inclusiveEndCreateTime int64, pageSize int32, nextToken []byte) (*model.Response[model.Message], error) {
+// messageIndexedFields message search index fields search results can be sorted and aggregated by
+// messageSortValue value of an indexed message field, nil when missing
+func messageSortValue(v *model.Message, field string) any {
+	switch field {
+	case MessageCreateTimeField:
+		return v.CreateTime
+	case MessageSessionIDField:
+		return v.SessionID
+	case MessageUserIDField:
+		if v.UserID == "" {
+			return nil
+		}
+		return v.UserID
+	}
+	if value, ok := v.Metadata.Get(field); ok {
+		return value
+	}
+	return nil
+}
+
+func (s *MemoryStore) messageIndexedFields() []string {
+	ret := []string{MessageCreateTimeField, MessageSessionIDField, MessageUserIDField}
+	for _, v := range s.MessageIndexFields {
+		ret = append(ret, v.Name)
+	}
+	return ret
+}
+
+func (s *MemoryStore) SearchMessages(sessionID string, keyword string, inclusiveStartCreateTime int64, inclusiveEndCreateTime int64, pageSize int32, nextToken []byte, opts ...model.SearchOption) (*model.Response[model.Message], error) {
+	return s.searchMessages(MessageSessionIDField, sessionID, keyword, inclusiveStartCreateTime, inclusiveEndCreateTime, pageSize, nextToken, opts...)
+}
+
+// SearchUserMessages searches messages across all sessions of a user, it only finds messages carrying
+// the user id, see BackfillMessageUserIDs for messages written before
+func (s *MemoryStore) SearchUserMessages(userID string, keyword string, inclusiveStartCreateTime int64, inclusiveEndCreateTime int64, pageSize int32, nextToken []byte, opts ...model.SearchOption) (*model.Response[model.Message], error) {
+	if userID == "" {
+		return nil, errors.New("user id is required")
+	}
+	return s.searchMessages(MessageUserIDField, userID, keyword, inclusiveStartCreateTime, inclusiveEndCreateTime, pageSize, nextToken, opts...)
+}
+
+// searchMessages searches messages whose scopeField equals scopeValue, an empty scopeValue searches all messages
+func (s *MemoryStore) searchMessages(scopeField string, scopeValue string, keyword string, inclusiveStartCreateTime int64, inclusiveEndCreateTime int64, pageSize int32, nextToken []byte, opts ...model.SearchOption) (*model.Response[model.Message], error) {
+	searchOpts, err := model.NewSearchOptions(model.QueryModePhrase, opts...)
+	if err != nil {
+		return nil, err
+	}
 	searchReq := new(tablestore.SearchRequest)
 	searchReq.SetTableName(s.MessageTableName)
 	searchReq.SetIndexName(s.MessageSearchIndexName)
 	queries := make([]search.Query, 0, 3)
-	if sessionID != "" {
+	if scopeValue != "" {
 		queries = append(queries, &search.TermQuery{
-			FieldName: MessageSessionIDField,
-			Term:      sessionID,
+			FieldName: scopeField,
+			Term:      scopeValue,
 		})
 	}
 	if inclusiveStartCreateTime > 0 || inclusiveEndCreateTime > 0 {
@@ -582,28 +840,34 @@ func (s *MemoryStore) SearchMessages(sessionID string, keyword string, inclusive
 		queries = append(queries, rangeQuery)
 	}
 	if keyword != "" {
-		queries = append(queries, &search.MatchPhraseQuery{
-			FieldName: MessageSearchContentField,
-			Text:      keyword,
-		})
+		query, err := keywordQuery(MessageSearchContentField, keyword, searchOpts)
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, query)
 	}
 	searchQuery := search.NewSearchQuery()
 	if l := len(queries); l > 1 {
-		searchQuery.SetQuery(&search.BoolQuery{
+		searchQuery.SetQuery(s.excludeArchived(s.excludeDeleted(&search.BoolQuery{
 			MustQueries: queries,
-		})
+		}, MessageDeletedAtField)))
 	} else if l == 1 {
-		searchQuery.SetQuery(queries[0])
+		searchQuery.SetQuery(s.excludeArchived(s.excludeDeleted(queries[0], MessageDeletedAtField)))
 	} else {
 		return nil, errors.New("missing search conditions")
 	}
-	searchQuery.SetSort(&search.Sort{
-		Sorters: []search.Sorter{
-			&search.ScoreSort{
-				Order: search.SortOrder_DESC.Enum(), // 从得分高到低排序。
-			},
-		},
+	querySort, err := searchSort(searchOpts, s.messageIndexedFields(), &search.ScoreSort{
+		Order: search.SortOrder_DESC.Enum(), // 从得分高到低排序。
 	})
+	if err != nil {
+		return nil, err
+	}
+	searchQuery.SetSort(querySort)
+	if keyword != "" {
+		if highlight := searchHighlight(MessageSearchContentField, s.Highlight); highlight != nil {
+			searchQuery.SetHighlight(highlight)
+		}
+	}
 	searchQuery.SetGetTotalCount(true)
 	searchQuery.SetLimit(pageSize)
 	if nextToken != nil {
@@ -613,6 +877,11 @@ func (s *MemoryStore) SearchMessages(sessionID string, keyword string, inclusive
 	searchReq.SetColumnsToGet(&tablestore.ColumnsToGet{
 		ReturnAll: true,
 	})
+	if searchOpts.Consistency == model.ConsistencyWaitIndex {
+		if err := s.waitForIndex(s.MessageTableName, s.MessageSearchIndexName, searchOpts.ConsistencyTimeout); err != nil {
+			return nil, err
+		}
+	}
 	resp, err := s.clt.Search(searchReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search messages, %w", err)
@@ -625,8 +894,26 @@ func (s *MemoryStore) SearchMessages(sessionID string, keyword string, inclusive
 		parseMessageFromRow(&message, row.Columns, row.PrimaryKey)
 		ret.Hits = append(ret.Hits, message)
 	}
+	ret.Details = hitDetails(resp, MessageSearchContentField)
 	if resp.NextToken != nil {
 		ret.NextToken = resp.NextToken
 	}
+	if searchOpts.Consistency == model.ConsistencyMergeWrites && nextToken == nil {
+		scopeOf := func(v *model.Message) string {
+			if scopeField == MessageUserIDField {
+				return v.UserID
+			}
+			return v.SessionID
+		}
+		mergeWrites(ret, s.writes.recent(s.MessageTableName), int(pageSize), func(v *model.Message) string {
+			return messageWriteKey(v.SessionID, v.MessageID)
+		}, func(v *model.Message) bool {
+			return (scopeValue == "" || scopeOf(v) == scopeValue) &&
+				inTimeRange(v.CreateTime, inclusiveStartCreateTime, inclusiveEndCreateTime) &&
+				model.MatchKeyword(v.SearchContent, keyword, searchOpts) &&
+				v.DeletedAt == 0 &&
+				(s.IncludeArchivedMessages || v.CompactedBy() == "")
+		}, mergeCompare(searchOpts.Sort, messageSortValue))
+	}
 	return ret, nil
 }
@@ -0,0 +1,156 @@
+package tablestore
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+// sessionOwnerCacheSize max sessions whose user the store remembers
+const sessionOwnerCacheSize = 10000
+
+// sessionOwnerLookupSize latest messages of a session read to find its user
+const sessionOwnerLookupSize = 20
+
+// sessionOwnerEntry user of a session, empty when several users wrote a session with the same id
+type sessionOwnerEntry struct {
+	sessionID string
+	userID    string
+}
+
+// sessionOwners least recently used cache of the users of sessions written through the store
+type sessionOwners struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+func newSessionOwners(size int) *sessionOwners {
+	return &sessionOwners{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// remember records the user of a session, a session id seen with another user becomes ambiguous
+func (c *sessionOwners) remember(userID string, sessionID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[sessionID]; ok {
+		entry := el.Value.(*sessionOwnerEntry)
+		if entry.userID != userID {
+			entry.userID = ""
+		}
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[sessionID] = c.order.PushFront(&sessionOwnerEntry{sessionID: sessionID, userID: userID})
+	for c.order.Len() > c.size {
+		el := c.order.Back()
+		c.order.Remove(el)
+		delete(c.entries, el.Value.(*sessionOwnerEntry).sessionID)
+	}
+}
+
+// forget drops a deleted session unless other users wrote a session with the same id
+func (c *sessionOwners) forget(userID string, sessionID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[sessionID]; ok && el.Value.(*sessionOwnerEntry).userID == userID {
+		c.order.Remove(el)
+		delete(c.entries, sessionID)
+	}
+}
+
+// owner user of a session, empty when unknown or ambiguous
+func (c *sessionOwners) owner(sessionID string) string {
+	userID, _ := c.lookup(sessionID)
+	return userID
+}
+
+// lookup user of a session and whether the session is known, the user is empty when ambiguous
+func (c *sessionOwners) lookup(sessionID string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[sessionID]; ok {
+		c.order.MoveToFront(el)
+		return el.Value.(*sessionOwnerEntry).userID, true
+	}
+	return "", false
+}
+
+func (s *MemoryStore) rememberSessionOwner(userID string, sessionID string) {
+	if userID != "" && sessionID != "" {
+		s.owners.remember(userID, sessionID)
+	}
+}
+
+// sessionOwner user id of a session recently written or resolved through this store, empty when
+// unknown or when users share the session id.
+func (s *MemoryStore) sessionOwner(sessionID string) string {
+	return s.owners.owner(sessionID)
+}
+
+// BackfillMessageUserIDs stamps messages written without a user id with the user of their session, for
+// the sessions of userID or all sessions when empty. rowsPerSecond limits the write rate, 0 means
+// unlimited. It returns the number of updated messages and can be rerun safely after a failure.
+// resolveSessionOwner user id of a session for a message written without one. A session this store
+// has not seen is looked up in its latest messages, empty when none carries a user or users share
+// the session id.
+func (s *MemoryStore) resolveSessionOwner(sessionID string) (string, error) {
+	if userID, ok := s.owners.lookup(sessionID); ok || sessionID == "" {
+		return userID, nil
+	}
+	resp, err := s.listMessagesPage(sessionID, nil, 0, 0, tablestore.BACKWARD, sessionOwnerLookupSize, nil)
+	if err != nil {
+		return "", fmt.Errorf("resolve session owner failed, %w", err)
+	}
+	for _, v := range resp.Hits {
+		s.rememberSessionOwner(v.UserID, sessionID)
+	}
+	return s.sessionOwner(sessionID), nil
+}
+
+func (s *MemoryStore) BackfillMessageUserIDs(userID string, rowsPerSecond int) (int, error) {
+	var sessions []model.Session
+	for v := range s.listSessions(userID, nil, -1, 5000) {
+		sessions = append(sessions, v)
+	}
+	writer := s.newBatchWriter(0, rowsPerSecond)
+	writer.skipConditionFailed = true
+	for _, session := range sessions {
+		s.rememberSessionOwner(session.UserID, session.SessionID)
+		var addErr error
+		for message := range s.listMessagesWithFilter(session.SessionID, missingMessageUserIDFilter(), 0, 0, tablestore.FORWARD, -1, 5000) {
+			if addErr != nil {
+				// drain the channel so the producer is not blocked
+				continue
+			}
+			rowChange := new(tablestore.UpdateRowChange)
+			rowChange.TableName = s.MessageTableName
+			rowChange.PrimaryKey = messagePrimaryKey(message.SessionID, message.CreateTime, message.MessageID)
+			rowChange.PutColumn(MessageUserIDField, session.UserID)
+			// skip messages deleted since they were listed
+			rowChange.SetCondition(tablestore.RowExistenceExpectation_EXPECT_EXIST)
+			addErr = writer.Add(rowChange, nil)
+		}
+		if addErr != nil {
+			return writer.Written(), fmt.Errorf("backfill message user ids failed, %w", addErr)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return writer.Written(), fmt.Errorf("backfill message user ids failed, %w", err)
+	}
+	return writer.Written(), nil
+}
+
+// missingMessageUserIDFilter passes messages without a user id column
+func missingMessageUserIDFilter() tablestore.ColumnFilter {
+	return tablestore.NewSingleColumnCondition(MessageUserIDField, tablestore.CT_EQUAL, "")
+}
@@ -0,0 +1,158 @@
+package tablestore
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/golang/protobuf/proto"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+const defaultReadyPollInterval = time.Second
+
+// WaitForReady blocks until session, message and memory tables accept reads, their secondary indexes
+// and search indexes have reached the incremental sync phase, or timeout is exceeded.
+func (s *MemoryStore) WaitForReady(timeout time.Duration) error {
+	startTime := time.Now()
+	deadline := startTime.Add(timeout)
+	interval := s.ReadyPollInterval
+	if interval <= 0 {
+		interval = defaultReadyPollInterval
+	}
+	checks := []func(time.Duration) (bool, error){
+		func(elapsed time.Duration) (bool, error) {
+			return s.tableReady(s.SessionTableName, s.SessionSecondaryIndexName, sessionProbePrimaryKey(), elapsed)
+		},
+		func(elapsed time.Duration) (bool, error) {
+			return s.searchIndexReady(s.SessionTableName, s.SessionSearchIndexName, elapsed)
+		},
+		func(elapsed time.Duration) (bool, error) {
+			return s.tableReady(s.MessageTableName, s.MessageSecondaryIndexName, messageProbePrimaryKey(), elapsed)
+		},
+		func(elapsed time.Duration) (bool, error) {
+			return s.searchIndexReady(s.MessageTableName, s.MessageSearchIndexName, elapsed)
+		},
+		func(elapsed time.Duration) (bool, error) {
+			return s.tableReady(s.MemoryTableName, "", memoryProbePrimaryKey(), elapsed)
+		},
+		func(elapsed time.Duration) (bool, error) {
+			return s.searchIndexReady(s.MemoryTableName, s.MemorySearchIndexName, elapsed)
+		},
+	}
+	if s.MessageHistory {
+		checks = append(checks, func(elapsed time.Duration) (bool, error) {
+			return s.tableReady(s.MessageHistoryTableName, "", messageHistoryProbePrimaryKey(), elapsed)
+		})
+	}
+	for _, check := range checks {
+		for {
+			ready, err := check(time.Since(startTime))
+			if err != nil {
+				return err
+			}
+			if ready {
+				break
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("wait for tables and indexes ready timeout after %s", timeout)
+			}
+			time.Sleep(interval)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) reportReady(progress model.ReadyProgress) {
+	if s.ReadyCallback != nil {
+		s.ReadyCallback(progress)
+	}
+}
+
+// tableReady checks that the table can serve reads and its secondary index, if any, finished the full sync
+func (s *MemoryStore) tableReady(tableName string, indexName string, probe *tablestore.PrimaryKey, elapsed time.Duration) (bool, error) {
+	describeResp, err := s.describeTableIfExists(tableName)
+	if err != nil {
+		return false, fmt.Errorf("describe table %s failed during wait for ready, %w", tableName, err)
+	}
+	if describeResp == nil {
+		return false, fmt.Errorf("table %s not exists", tableName)
+	}
+	getReq := new(tablestore.GetRowRequest)
+	getReq.SingleRowQueryCriteria = new(tablestore.SingleRowQueryCriteria)
+	getReq.SingleRowQueryCriteria.TableName = tableName
+	getReq.SingleRowQueryCriteria.PrimaryKey = probe
+	getReq.SingleRowQueryCriteria.MaxVersion = 1
+	if _, err := s.clt.GetRow(getReq); err != nil {
+		// only a table still loading is retried, auth, permission or network errors are returned
+		if !isOtsErrorCode(err, tablestore.TABLE_NOT_READY, tablestore.PARTITION_UNAVAILABLE) {
+			return false, fmt.Errorf("probe table %s failed during wait for ready, %w", tableName, err)
+		}
+		s.reportReady(model.ReadyProgress{TableName: tableName, Phase: "LOADING", Elapsed: elapsed})
+		return false, nil
+	}
+	s.reportReady(model.ReadyProgress{TableName: tableName, Phase: "LOADED", Ready: true, Elapsed: elapsed})
+	if indexName == "" {
+		return true, nil
+	}
+	for _, v := range describeResp.IndexMetas {
+		if v.IndexName != indexName {
+			continue
+		}
+		// local indexes may not report a sync phase, treat them as ready
+		ready := v.IndexSyncPhase == nil || *v.IndexSyncPhase == tablestore.SyncPhase_INCR
+		s.reportReady(model.ReadyProgress{TableName: tableName, IndexName: indexName, Phase: v.IndexSyncPhase.String(), Ready: ready, Elapsed: elapsed})
+		return ready, nil
+	}
+	return false, fmt.Errorf("secondary index %s of table %s not exists", indexName, tableName)
+}
+
+// searchIndexReady checks that the search index is in the incremental sync phase
+func (s *MemoryStore) searchIndexReady(tableName string, indexName string, elapsed time.Duration) (bool, error) {
+	describeReq := new(tablestore.DescribeSearchIndexRequest)
+	describeReq.TableName = tableName
+	describeReq.IndexName = indexName
+	describeReq.IncludeSyncStat = proto.Bool(true)
+	describeResp, err := s.clt.DescribeSearchIndex(describeReq)
+	if err != nil {
+		return false, fmt.Errorf("describe search index %s failed during wait for ready, %w", indexName, err)
+	}
+	var phase *tablestore.SyncPhase
+	if describeResp.SyncStat != nil {
+		phase = &describeResp.SyncStat.SyncPhase
+	}
+	ready := phase != nil && *phase == tablestore.SyncPhase_INCR
+	s.reportReady(model.ReadyProgress{TableName: tableName, IndexName: indexName, Phase: phase.String(), Ready: ready, Elapsed: elapsed})
+	return ready, nil
+}
+
+func sessionProbePrimaryKey() *tablestore.PrimaryKey {
+	pk := new(tablestore.PrimaryKey)
+	pk.AddPrimaryKeyColumn(SessionUserIDField, "")
+	pk.AddPrimaryKeyColumn(SessionSessionIDField, "")
+	return pk
+}
+
+func messageProbePrimaryKey() *tablestore.PrimaryKey {
+	pk := new(tablestore.PrimaryKey)
+	pk.AddPrimaryKeyColumn(MessageSessionIDField, "")
+	pk.AddPrimaryKeyColumn(MessageCreateTimeField, int64(0))
+	pk.AddPrimaryKeyColumn(MessageMessageIDField, "")
+	return pk
+}
+
+func messageHistoryProbePrimaryKey() *tablestore.PrimaryKey {
+	pk := new(tablestore.PrimaryKey)
+	pk.AddPrimaryKeyColumn(MessageSessionIDField, "")
+	pk.AddPrimaryKeyColumn(MessageMessageIDField, "")
+	pk.AddPrimaryKeyColumn(MessageHistoryValidToField, int64(0))
+	return pk
+}
+
+func memoryProbePrimaryKey() *tablestore.PrimaryKey {
+	pk := new(tablestore.PrimaryKey)
+	pk.AddPrimaryKeyColumn(MemoryUserIDField, "")
+	pk.AddPrimaryKeyColumn(MemoryMemoryIDField, "")
+	return pk
+}
@@ -0,0 +1,103 @@
+package tablestore
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore/search"
+	"github.com/spf13/cast"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+// DeleteMessagesBefore delete messages of a session created before createTime (exclusive)
+func (s *MemoryStore) DeleteMessagesBefore(sessionID string, createTime int64) (int, error) {
+	if sessionID == "" {
+		return 0, errors.New("session id is required")
+	}
+	report, err := s.SweepMessages(model.RetentionOptions{
+		SessionID: sessionID,
+		Before:    createTime,
+	})
+	if report == nil {
+		return 0, err
+	}
+	return report.Deleted, err
+}
+
+// SweepMessages delete messages older than the retention cutoff, together with their versions when
+// message history is enabled, deleted after each batch of messages. When no session is given the message search index is scanned, so
+// messages of sessions that no longer exist are also removed.
+func (s *MemoryStore) SweepMessages(opts model.RetentionOptions) (*model.RetentionReport, error) {
+	before := opts.Cutoff()
+	if before <= 0 {
+		return nil, errors.New("missing retention cutoff, either Before or MaxAge is required")
+	}
+	report := &model.RetentionReport{
+		Before:    before,
+		DryRun:    opts.DryRun,
+		StartTime: model.CurrentTimeMicroseconds(),
+	}
+	writer := s.newBatchWriter(opts.BatchSize, opts.RowsPerSecond)
+	deleteHistory := s.deleteHistoryAfterFlush(writer)
+	handle := func(sessionID string, createTime int64, messageID string) error {
+		if opts.DryRun {
+			report.Add(sessionID, 1)
+			return nil
+		}
+		rowChange := new(tablestore.DeleteRowChange)
+		rowChange.TableName = s.MessageTableName
+		rowChange.SetCondition(tablestore.RowExistenceExpectation_IGNORE)
+		rowChange.PrimaryKey = messagePrimaryKey(sessionID, createTime, messageID)
+		return writer.Add(rowChange, func(bool) {
+			report.Add(sessionID, 1)
+			deleteHistory(sessionID, messageID)
+		})
+	}
+	var err error
+	if opts.SessionID != "" {
+		// ListMessagesWithFilter treats the end create time as inclusive
+		if before > 1 {
+			for v := range s.listMessagesWithFilter(opts.SessionID, nil, 0, before-1, tablestore.FORWARD, -1, 5000) {
+				if err != nil {
+					// drain the channel so the producer is not blocked
+					continue
+				}
+				err = handle(v.SessionID, v.CreateTime, v.MessageID)
+			}
+		}
+	} else {
+		query := &search.RangeQuery{
+			FieldName: MessageCreateTimeField,
+			From:      tablestore.MIN,
+			To:        before,
+		}
+		err = s.parallelScan(s.MessageTableName, s.MessageSearchIndexName, query, nil, func(row *tablestore.Row) error {
+			var (
+				sessionID  string
+				createTime int64
+				messageID  string
+			)
+			for _, col := range row.PrimaryKey.PrimaryKeys {
+				switch col.ColumnName {
+				case MessageSessionIDField:
+					sessionID = cast.ToString(col.Value)
+				case MessageCreateTimeField:
+					createTime = cast.ToInt64(col.Value)
+				case MessageMessageIDField:
+					messageID = cast.ToString(col.Value)
+				}
+			}
+			return handle(sessionID, createTime, messageID)
+		})
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	report.EndTime = model.CurrentTimeMicroseconds()
+	if err != nil {
+		return report, fmt.Errorf("sweep messages failed, %w", err)
+	}
+	return report, nil
+}
@@ -0,0 +1,78 @@
+package tablestore
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore/search"
+)
+
+// parallelScan walks every row of a search index matching query, split by split
+func (s *tableClient) parallelScan(tableName string, indexName string, query search.Query, columnsToGet *tablestore.ColumnsToGet, fn func(row *tablestore.Row) error) error {
+	splitsReq := new(tablestore.ComputeSplitsRequest)
+	splitsReq.SetTableName(tableName)
+	splitsReq.SetSearchIndexSplitsOptions(tablestore.SearchIndexSplitsOptions{IndexName: indexName})
+	splitsResp, err := s.clt.ComputeSplits(splitsReq)
+	if err != nil {
+		return fmt.Errorf("compute splits of search index %s failed, %w", indexName, err)
+	}
+	if columnsToGet == nil {
+		columnsToGet = new(tablestore.ColumnsToGet)
+	}
+	for parallelID := range splitsResp.SplitsSize {
+		scanQuery := search.NewScanQuery().
+			SetQuery(query).
+			SetLimit(2000).
+			SetCurrentParallelID(parallelID).
+			SetMaxParallel(splitsResp.SplitsSize)
+		scanReq := new(tablestore.ParallelScanRequest)
+		scanReq.SetTableName(tableName).
+			SetIndexName(indexName).
+			SetColumnsToGet(columnsToGet).
+			SetScanQuery(scanQuery).
+			SetSessionId(splitsResp.SessionId)
+		for {
+			resp, err := s.clt.ParallelScan(scanReq)
+			if err != nil {
+				return fmt.Errorf("parallel scan search index %s failed, %w", indexName, err)
+			}
+			for _, row := range resp.Rows {
+				if err := fn(row); err != nil {
+					return err
+				}
+			}
+			if resp.NextToken == nil {
+				break
+			}
+			scanReq.SetScanQuery(scanQuery.SetToken(resp.NextToken))
+		}
+	}
+	return nil
+}
+
+// rateLimiter blocks callers so that no more than rowsPerSecond rows are processed per second
+type rateLimiter struct {
+	rowsPerSecond int
+	startTime     time.Time
+	rows          int
+}
+
+func newRateLimiter(rowsPerSecond int) *rateLimiter {
+	return &rateLimiter{
+		rowsPerSecond: rowsPerSecond,
+		startTime:     time.Now(),
+	}
+}
+
+// Wait accounts n rows and sleeps until they fit in the configured rate
+func (l *rateLimiter) Wait(n int) {
+	if l == nil || l.rowsPerSecond <= 0 {
+		return
+	}
+	l.rows += n
+	expected := time.Duration(float64(l.rows) / float64(l.rowsPerSecond) * float64(time.Second))
+	if elapsed := time.Since(l.startTime); elapsed < expected {
+		time.Sleep(expected - elapsed)
+	}
+}
@@ -0,0 +1,125 @@
+package tablestore
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore/search"
+	"github.com/golang/protobuf/proto"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+// textFieldSchema full-text field tokenized by the configured analyzer
+func textFieldSchema(fieldName string, opts model.AnalyzerOptions) (*tablestore.FieldSchema, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	analyzer := opts.Analyzer
+	return &tablestore.FieldSchema{
+		FieldName:         proto.String(fieldName),
+		FieldType:         tablestore.FieldType_TEXT,
+		Index:             proto.Bool(true),
+		Analyzer:          &analyzer,
+		AnalyzerParameter: opts.Parameter(),
+	}, nil
+}
+
+// keywordQuery matches keyword against a text field in the selected query mode
+func keywordQuery(fieldName string, keyword string, opts *model.SearchOptions) (search.Query, error) {
+	switch opts.Mode {
+	case model.QueryModePhrase, model.QueryModeMatch, model.QueryModePrefix, model.QueryModeWildcard:
+		return clauseQuery(fieldName, opts.Mode, keyword, opts), nil
+	case model.QueryModeString:
+		clauses, err := model.ParseQueryString(keyword)
+		if err != nil {
+			return nil, err
+		}
+		query := new(search.BoolQuery)
+		var should []search.Query
+		for _, clause := range clauses {
+			q := clauseQuery(fieldName, clause.Mode, clause.Text, opts)
+			switch {
+			case clause.Occur == model.ClauseMust:
+				query.MustQueries = append(query.MustQueries, q)
+			case clause.Occur == model.ClauseMustNot:
+				query.MustNotQueries = append(query.MustNotQueries, q)
+			case opts.Operator == model.MatchOperatorAnd:
+				query.MustQueries = append(query.MustQueries, q)
+			default:
+				should = append(should, q)
+			}
+		}
+		if len(should) > 0 {
+			query.ShouldQueries = should
+			if len(query.MustQueries) == 0 {
+				query.MinimumShouldMatch = proto.Int32(max(opts.MinimumShouldMatch, 1))
+			}
+		}
+		if len(query.MustQueries) == 0 && len(query.ShouldQueries) == 0 {
+			// exclusions only, match everything else
+			query.MustQueries = []search.Query{&search.MatchAllQuery{}}
+		}
+		return query, nil
+	}
+	return nil, fmt.Errorf("unsupported query mode: %q", opts.Mode)
+}
+
+func clauseQuery(fieldName string, mode model.QueryMode, text string, opts *model.SearchOptions) search.Query {
+	switch mode {
+	case model.QueryModeMatch:
+		query := &search.MatchQuery{
+			FieldName: fieldName,
+			Text:      text,
+		}
+		if opts.Operator == model.MatchOperatorAnd {
+			query.Operator = search.QueryOperator_AND.Enum()
+		} else if opts.MinimumShouldMatch > 0 {
+			query.MinimumShouldMatch = proto.Int32(opts.MinimumShouldMatch)
+		}
+		return query
+	case model.QueryModePrefix:
+		return &search.PrefixQuery{
+			FieldName: fieldName,
+			Prefix:    text,
+		}
+	case model.QueryModeWildcard:
+		return &search.WildcardQuery{
+			FieldName: fieldName,
+			Value:     text,
+		}
+	}
+	return &search.MatchPhraseQuery{
+		FieldName: fieldName,
+		Text:      text,
+	}
+}
+
+// searchSort sorters of opts followed by the primary key tiebreaker, fields other than score must be in
+// sortable. defaults are used when opts has no sorters.
+func searchSort(opts *model.SearchOptions, sortable []string, defaults ...search.Sorter) (*search.Sort, error) {
+	sorters := make([]search.Sorter, 0, len(opts.Sort)+1)
+	for _, v := range opts.Sort {
+		order := search.SortOrder_ASC
+		if v.Desc {
+			order = search.SortOrder_DESC
+		}
+		if v.Field == model.SortByScore {
+			sorters = append(sorters, &search.ScoreSort{Order: order.Enum()})
+			continue
+		}
+		if !slices.Contains(sortable, v.Field) {
+			return nil, fmt.Errorf("field %s is not sortable, sortable fields: %v", v.Field, sortable)
+		}
+		sorters = append(sorters, &search.FieldSort{
+			FieldName: v.Field,
+			Order:     order.Enum(),
+		})
+	}
+	if len(sorters) == 0 {
+		sorters = append(sorters, defaults...)
+	}
+	sorters = append(sorters, &search.PrimaryKeySort{Order: search.SortOrder_ASC.Enum()})
+	return &search.Sort{Sorters: sorters}, nil
+}
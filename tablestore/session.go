@@ -1,9 +1,11 @@
 package tablestore
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
 	"slices"
+	"sync"
 
 	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
 	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore/search"
@@ -24,48 +26,43 @@ func (s *MemoryStore) InitSessionTable() error {
 		if err != nil {
 			return fmt.Errorf("describe session table failed during init session table, %w", err)
 		}
+		if err := s.addMissingDefinedColumns(s.SessionTableName, describeResp.TableMeta, sessionDefinedColumns()); err != nil {
+			return fmt.Errorf("add session table defined columns failed during init session table, %w", err)
+		}
 		// Check if secondary index exists
 		indexExists := false
 		for _, v := range describeResp.IndexMetas {
 			if v.IndexName == s.SessionSecondaryIndexName {
 				indexExists = true
+				s.setSessionIndexMissing(s.missingSessionIndexColumns(v))
 				break
 			}
 		}
 
 		// Only create index if it doesn't exist
 		if !indexExists {
-			indexMeta := new(tablestore.IndexMeta)
-			indexMeta.IndexName = s.SessionSecondaryIndexName
-			indexMeta.AddPrimaryKeyColumn(SessionUserIDField)
-			indexMeta.AddPrimaryKeyColumn(SessionUpdateTimeField)
-			indexMeta.AddPrimaryKeyColumn(SessionSessionIDField)
-			indexMeta.SetAsLocalIndex()
 			createIndexReq := new(tablestore.CreateIndexRequest)
 			createIndexReq.MainTableName = s.SessionTableName
-			createIndexReq.IndexMeta = indexMeta
+			createIndexReq.IndexMeta = s.sessionSecondaryIndexMeta()
+			createIndexReq.IncludeBaseData = true
 			if _, err := s.clt.CreateIndex(createIndexReq); err != nil {
 				return fmt.Errorf("create session table secondary index failed during init session table, %w", err)
 			}
 		}
-		searchIndexExists := false
-		listSearchIndexReq := new(tablestore.ListSearchIndexRequest)
-		listSearchIndexReq.TableName = s.SessionTableName
-		indexResp, err := s.clt.ListSearchIndex(listSearchIndexReq)
+		searchIndexExists, err := s.searchIndexExists(s.SessionTableName, s.SessionSearchIndexName)
 		if err != nil {
 			return fmt.Errorf("list session search index failed during init session table, %w", err)
 		}
-		for _, v := range indexResp.IndexInfo {
-			if v.IndexName == s.SessionSearchIndexName {
-				searchIndexExists = true
-				break
-			}
-		}
 
 		if !searchIndexExists {
 			if err := s.createSessionSearchIndex(); err != nil {
 				return fmt.Errorf("create session table search index failed during init session table, %w", err)
 			}
+		} else if err := s.reconcileSearchIndexTTL(s.SessionTableName, s.SessionSearchIndexName, s.SessionTableOptions); err != nil {
+			return fmt.Errorf("reconcile session search index failed during init session table, %w", err)
+		}
+		if err := s.reconcileTable(s.SessionTableName, s.SessionTableOptions, describeResp); err != nil {
+			return fmt.Errorf("reconcile session table failed during init session table, %w", err)
 		}
 		return nil
 	}
@@ -73,24 +70,17 @@ func (s *MemoryStore) InitSessionTable() error {
 	tableMeta.TableName = s.SessionTableName
 	tableMeta.AddPrimaryKeyColumn(SessionUserIDField, tablestore.PrimaryKeyType_STRING)
 	tableMeta.AddPrimaryKeyColumn(SessionSessionIDField, tablestore.PrimaryKeyType_STRING)
-	tableMeta.AddDefinedColumn(SessionUpdateTimeField, tablestore.DefinedColumn_INTEGER)
-	tableOption := new(tablestore.TableOption)
-	tableOption.MaxVersion = 1
-	tableOption.TimeToAlive = -1
-	reservedThroughput := new(tablestore.ReservedThroughput)
-	reservedThroughput.Readcap = 0
-	reservedThroughput.Writecap = 0
-	indexMeta := new(tablestore.IndexMeta)
-	indexMeta.IndexName = s.SessionSecondaryIndexName
-	indexMeta.AddPrimaryKeyColumn(SessionUserIDField)
-	indexMeta.AddPrimaryKeyColumn(SessionUpdateTimeField)
-	indexMeta.AddPrimaryKeyColumn(SessionSessionIDField)
-	indexMeta.SetAsLocalIndex()
+	for _, col := range sessionDefinedColumns() {
+		tableMeta.AddDefinedColumn(col.Name, col.ColumnType)
+	}
+	tableOption := newTableOption(s.SessionTableOptions)
+	reservedThroughput := newReservedThroughput(s.SessionTableOptions)
 	createTableRequest := new(tablestore.CreateTableRequest)
 	createTableRequest.TableMeta = tableMeta
 	createTableRequest.TableOption = tableOption
 	createTableRequest.ReservedThroughput = reservedThroughput
-	createTableRequest.AddIndexMeta(indexMeta)
+	createTableRequest.SSESpecification = s.SessionTableOptions.SSE
+	createTableRequest.AddIndexMeta(s.sessionSecondaryIndexMeta())
 	if _, err := s.clt.CreateTable(createTableRequest); err != nil {
 		return fmt.Errorf("create session table failed, %w", err)
 	}
@@ -100,11 +90,151 @@ func (s *MemoryStore) InitSessionTable() error {
 	return nil
 }
 
+// sessionDefinedColumns defined columns of the session table
+func sessionDefinedColumns() []*tablestore.DefinedColumnSchema {
+	return []*tablestore.DefinedColumnSchema{
+		{Name: SessionUpdateTimeField, ColumnType: tablestore.DefinedColumn_INTEGER},
+		{Name: SessionTitleField, ColumnType: tablestore.DefinedColumn_STRING},
+		{Name: SessionCreateTimeField, ColumnType: tablestore.DefinedColumn_INTEGER},
+		{Name: SessionStatusField, ColumnType: tablestore.DefinedColumn_STRING},
+		{Name: SessionPinnedField, ColumnType: tablestore.DefinedColumn_BOOLEAN},
+		{Name: SessionDeletedAtField, ColumnType: tablestore.DefinedColumn_INTEGER},
+	}
+}
+
+// sessionSecondaryIndexMeta local index ordering sessions of a user by update time, the lifecycle
+// columns are included so recent session listings return and filter on them
+func (s *MemoryStore) sessionSecondaryIndexMeta() *tablestore.IndexMeta {
+	indexMeta := new(tablestore.IndexMeta)
+	indexMeta.IndexName = s.SessionSecondaryIndexName
+	indexMeta.AddPrimaryKeyColumn(SessionUserIDField)
+	indexMeta.AddPrimaryKeyColumn(SessionUpdateTimeField)
+	indexMeta.AddPrimaryKeyColumn(SessionSessionIDField)
+	indexMeta.AddDefinedColumn(SessionTitleField)
+	indexMeta.AddDefinedColumn(SessionCreateTimeField)
+	indexMeta.AddDefinedColumn(SessionStatusField)
+	indexMeta.AddDefinedColumn(SessionPinnedField)
+	indexMeta.AddDefinedColumn(SessionDeletedAtField)
+	indexMeta.SetAsLocalIndex()
+	return indexMeta
+}
+
+// StaleIndexError warns that an index lacks columns added since it was created, the store works around
+// it until the index is migrated
+type StaleIndexError struct {
+	TableName string
+	IndexName string
+	// Missing columns the index lacks
+	Missing []string
+	// Migration the call migrating the index
+	Migration string
+}
+
+func (e *StaleIndexError) Error() string {
+	return fmt.Sprintf("index %s of table %s lacks columns %v, call %s to migrate it", e.IndexName, e.TableName, e.Missing, e.Migration)
+}
+
+// sessionIndexState whether the session secondary index lacks the lifecycle columns
+type sessionIndexState struct {
+	mu      sync.Mutex
+	checked bool
+	missing []string
+}
+
+// setSessionIndexMissing records the columns the session secondary index lacks and warns when some are
+func (s *MemoryStore) setSessionIndexMissing(missing []string) {
+	s.sessionIndex.mu.Lock()
+	s.sessionIndex.checked = true
+	s.sessionIndex.missing = missing
+	s.sessionIndex.mu.Unlock()
+	if len(missing) > 0 {
+		s.warn(&StaleIndexError{
+			TableName: s.SessionTableName,
+			IndexName: s.SessionSecondaryIndexName,
+			Missing:   missing,
+			Migration: "RebuildSessionSecondaryIndex",
+		})
+	}
+}
+
+// staleSessionIndex reports whether the session secondary index lacks the lifecycle columns, recent
+// session listings then read the session table instead. The index is described once, a failed
+// describe is retried on the next call.
+func (s *MemoryStore) staleSessionIndex() bool {
+	s.sessionIndex.mu.Lock()
+	checked, missing := s.sessionIndex.checked, s.sessionIndex.missing
+	s.sessionIndex.mu.Unlock()
+	if checked {
+		return len(missing) > 0
+	}
+	describeReq := new(tablestore.DescribeTableRequest)
+	describeReq.TableName = s.SessionTableName
+	describeResp, err := s.clt.DescribeTable(describeReq)
+	if err != nil {
+		return false
+	}
+	for _, v := range describeResp.IndexMetas {
+		if v.IndexName == s.SessionSecondaryIndexName {
+			missing = s.missingSessionIndexColumns(v)
+		}
+	}
+	s.setSessionIndexMissing(missing)
+	return len(missing) > 0
+}
+
+// missingSessionIndexColumns defined columns of sessionSecondaryIndexMeta missing from an existing index,
+// which then returns sessions without them and passes every row through filters on them
+func (s *MemoryStore) missingSessionIndexColumns(indexMeta *tablestore.IndexMeta) []string {
+	var ret []string
+	for _, col := range s.sessionSecondaryIndexMeta().DefinedColumns {
+		if !slices.Contains(indexMeta.DefinedColumns, col) {
+			ret = append(ret, col)
+		}
+	}
+	return ret
+}
+
+// RebuildSessionSecondaryIndex drop and recreate the session secondary index from the table rows, it
+// migrates an index created before the lifecycle columns existed. Recent session listings fail or miss
+// sessions until the index is rebuilt, they read the session table again once this store sees the
+// index is migrated.
+func (s *MemoryStore) RebuildSessionSecondaryIndex() error {
+	describeReq := new(tablestore.DescribeTableRequest)
+	describeReq.TableName = s.SessionTableName
+	describeResp, err := s.clt.DescribeTable(describeReq)
+	if err != nil {
+		return fmt.Errorf("describe session table failed during rebuild session secondary index, %w", err)
+	}
+	if err := s.addMissingDefinedColumns(s.SessionTableName, describeResp.TableMeta, sessionDefinedColumns()); err != nil {
+		return fmt.Errorf("add session table defined columns failed during rebuild session secondary index, %w", err)
+	}
+	if err := s.deleteSecondaryIndexIfExists(s.SessionTableName, s.SessionSecondaryIndexName, describeResp.IndexMetas); err != nil {
+		return fmt.Errorf("delete session secondary index failed, %w", err)
+	}
+	createIndexReq := new(tablestore.CreateIndexRequest)
+	createIndexReq.MainTableName = s.SessionTableName
+	createIndexReq.IndexMeta = s.sessionSecondaryIndexMeta()
+	createIndexReq.IncludeBaseData = true
+	if _, err := s.clt.CreateIndex(createIndexReq); err != nil {
+		return fmt.Errorf("create session secondary index failed during rebuild session secondary index, %w", err)
+	}
+	s.setSessionIndexMissing(nil)
+	return nil
+}
+
 func (s *MemoryStore) createSessionSearchIndex() error {
-	analyzer := tablestore.Analyzer_Fuzzy
+	contentSchema, err := textFieldSchema(SessionSearchContentField, s.SessionAnalyzer)
+	if err != nil {
+		return fmt.Errorf("create session search index failed, %w", err)
+	}
+	titleSchema, err := textFieldSchema(SessionTitleField, s.SessionAnalyzer)
+	if err != nil {
+		return fmt.Errorf("create session search index failed, %w", err)
+	}
 	createReq := new(tablestore.CreateSearchIndexRequest)
 	createReq.TableName = s.SessionTableName
 	createReq.IndexName = s.SessionSearchIndexName
+	createReq.TimeToLive = searchIndexTTL(s.SessionTableOptions)
 	createReq.IndexSchema = &tablestore.IndexSchema{
 		FieldSchemas: []*tablestore.FieldSchema{
 			{
@@ -118,47 +248,151 @@ func (s *MemoryStore) createSessionSearchIndex() error {
 				Index:     proto.Bool(true),
 			},
 			{
-				FieldName: proto.String(SessionSearchContentField),
-				FieldType: tablestore.FieldType_TEXT,
+				FieldName: proto.String(SessionCreateTimeField),
+				FieldType: tablestore.FieldType_LONG,
+				Index:     proto.Bool(true),
+			},
+			{
+				FieldName: proto.String(SessionStatusField),
+				FieldType: tablestore.FieldType_KEYWORD,
+				Index:     proto.Bool(true),
+			},
+			{
+				FieldName: proto.String(SessionPinnedField),
+				FieldType: tablestore.FieldType_BOOLEAN,
+				Index:     proto.Bool(true),
+			},
+			{
+				FieldName: proto.String(SessionDeletedAtField),
+				FieldType: tablestore.FieldType_LONG,
 				Index:     proto.Bool(true),
-				Analyzer:  &analyzer,
-				AnalyzerParameter: tablestore.FuzzyAnalyzerParameter{
-					MinChars: 1,
-					MaxChars: 7,
-				},
 			},
+			titleSchema,
+			contentSchema,
 		},
 	}
-	_, err := s.clt.CreateSearchIndex(createReq)
-	if err != nil {
+	if s.Embedder != nil {
+		createReq.IndexSchema.FieldSchemas = append(createReq.IndexSchema.FieldSchemas, vectorFieldSchema(SessionEmbeddingField, int32(s.Embedder.Dimension()), s.EmbeddingMetric))
+	}
+	for _, field := range s.SessionIndexFields {
+		schema, err := indexFieldSchema(field)
+		if err != nil {
+			return fmt.Errorf("create session search index failed, %w", err)
+		}
+		createReq.IndexSchema.FieldSchemas = append(createReq.IndexSchema.FieldSchemas, schema)
+	}
+	if _, err := s.clt.CreateSearchIndex(createReq); err != nil {
 		return fmt.Errorf("create session search index failed, %w", err)
 	}
 	return nil
 }
 
+// InitSessionSearchIndex creates the session search index if it does not exist yet
+func (s *MemoryStore) InitSessionSearchIndex() error {
+	exists, err := s.searchIndexExists(s.SessionTableName, s.SessionSearchIndexName)
+	if err != nil {
+		return fmt.Errorf("list session search index failed during init session search index, %w", err)
+	}
+	if exists {
+		return nil
+	}
+	return s.createSessionSearchIndex()
+}
+
+// DeleteSessionTableAndIndex deletes the session search index, secondary index and table in order.
+// It refuses to touch a table whose schema does not match the one created by InitSessionTable.
+func (s *MemoryStore) DeleteSessionTableAndIndex() error {
+	describeResp, err := s.describeTableIfExists(s.SessionTableName)
+	if err != nil {
+		return fmt.Errorf("describe session table failed during delete session table, %w", err)
+	}
+	if describeResp == nil {
+		return nil
+	}
+	if err := checkSessionTableSchema(describeResp.TableMeta); err != nil {
+		return fmt.Errorf("refuse to delete session table %s, %w", s.SessionTableName, err)
+	}
+	if err := s.deleteSearchIndexIfExists(s.SessionTableName, s.SessionSearchIndexName); err != nil {
+		return fmt.Errorf("delete session search index failed, %w", err)
+	}
+	if err := s.deleteSecondaryIndexIfExists(s.SessionTableName, s.SessionSecondaryIndexName, describeResp.IndexMetas); err != nil {
+		return fmt.Errorf("delete session secondary index failed, %w", err)
+	}
+	deleteReq := new(tablestore.DeleteTableRequest)
+	deleteReq.TableName = s.SessionTableName
+	if _, err := s.clt.DeleteTable(deleteReq); err != nil {
+		return fmt.Errorf("delete session table failed, %w", err)
+	}
+	return nil
+}
+
 func (s *MemoryStore) PutSession(session *model.Session) error {
-	pk := new(tablestore.PrimaryKey)
-	pk.AddPrimaryKeyColumn(SessionUserIDField, session.UserID)
-	pk.AddPrimaryKeyColumn(SessionSessionIDField, session.SessionID)
-	putReq := new(tablestore.PutRowRequest)
-	putReq.PutRowChange = new(tablestore.PutRowChange)
-	putReq.PutRowChange.TableName = s.SessionTableName
-	putReq.PutRowChange.PrimaryKey = pk
-	putReq.PutRowChange.AddColumn(SessionUpdateTimeField, session.UpdateTime)
-	if session.SearchContent != "" {
-		putReq.PutRowChange.AddColumn(SessionSearchContentField, session.SearchContent)
+	if session.Status != "" {
+		if err := session.Status.Validate(); err != nil {
+			return fmt.Errorf("put session to memory store failed, %w", err)
+		}
 	}
-	for k, v := range session.Metadata {
-		putReq.PutRowChange.AddColumn(k, v)
+	if err := s.ensureSessionEmbedding(session); err != nil {
+		return fmt.Errorf("put session to memory store failed, %w", err)
 	}
+	session.CreateTime = cmp.Or(session.CreateTime, session.UpdateTime)
+	putReq := new(tablestore.PutRowRequest)
+	putReq.PutRowChange = s.sessionPutRowChange(session)
 	putReq.PutRowChange.SetCondition(tablestore.RowExistenceExpectation_IGNORE)
 	if _, err := s.clt.PutRow(putReq); err != nil {
 		return fmt.Errorf("put session to memory store failed, %w", err)
 	}
+	s.writes.record(s.SessionTableName, sessionWriteKey(session.UserID, session.SessionID), session.Clone())
+	s.rememberSessionOwner(session.UserID, session.SessionID)
 	return nil
 }
 
+func (s *MemoryStore) sessionPutRowChange(session *model.Session) *tablestore.PutRowChange {
+	pk := new(tablestore.PrimaryKey)
+	pk.AddPrimaryKeyColumn(SessionUserIDField, session.UserID)
+	pk.AddPrimaryKeyColumn(SessionSessionIDField, session.SessionID)
+	rowChange := new(tablestore.PutRowChange)
+	rowChange.TableName = s.SessionTableName
+	rowChange.PrimaryKey = pk
+	rowChange.AddColumn(SessionUpdateTimeField, session.UpdateTime)
+	if session.Title != "" {
+		rowChange.AddColumn(SessionTitleField, session.Title)
+	}
+	if session.CreateTime > 0 {
+		rowChange.AddColumn(SessionCreateTimeField, session.CreateTime)
+	}
+	if session.Status != "" {
+		rowChange.AddColumn(SessionStatusField, string(session.Status))
+	}
+	if session.Pinned {
+		rowChange.AddColumn(SessionPinnedField, true)
+	}
+	if session.DeletedAt > 0 {
+		rowChange.AddColumn(SessionDeletedAtField, session.DeletedAt)
+	}
+	if session.ActiveBranchID != "" {
+		rowChange.AddColumn(SessionActiveBranchField, session.ActiveBranchID)
+	}
+	if session.SearchContent != "" {
+		rowChange.AddColumn(SessionSearchContentField, session.SearchContent)
+	}
+	if len(session.Embedding) > 0 {
+		rowChange.AddColumn(SessionEmbeddingField, encodeVector(session.Embedding))
+	}
+	for k, v := range session.Metadata {
+		rowChange.AddColumn(k, v)
+	}
+	return rowChange
+}
+
+// UpdateSession update the title, search content and metadata of a session, and its creation time and
+// status when set. The pin and the active branch are kept, PinSession and SwitchBranch change them.
 func (s *MemoryStore) UpdateSession(session *model.Session) error {
+	if session.Status != "" {
+		if err := session.Status.Validate(); err != nil {
+			return fmt.Errorf("update session failed, %w", err)
+		}
+	}
 	tmp := model.Session{
 		UserID:    session.UserID,
 		SessionID: session.SessionID,
@@ -174,11 +408,34 @@ func (s *MemoryStore) UpdateSession(session *model.Session) error {
 	updateReq.UpdateRowChange.TableName = s.SessionTableName
 	updateReq.UpdateRowChange.PrimaryKey = pk
 	updateReq.UpdateRowChange.PutColumn(SessionUpdateTimeField, session.UpdateTime)
+	if session.Title != "" {
+		updateReq.UpdateRowChange.PutColumn(SessionTitleField, session.Title)
+	} else {
+		updateReq.UpdateRowChange.DeleteColumn(SessionTitleField)
+	}
+	if session.CreateTime > 0 {
+		updateReq.UpdateRowChange.PutColumn(SessionCreateTimeField, session.CreateTime)
+	}
+	if session.Status != "" {
+		updateReq.UpdateRowChange.PutColumn(SessionStatusField, string(session.Status))
+	}
 	if session.SearchContent != "" {
 		updateReq.UpdateRowChange.PutColumn(SessionSearchContentField, session.SearchContent)
 	} else {
 		updateReq.UpdateRowChange.DeleteColumn(SessionSearchContentField)
 	}
+	// the stored vector read back with the session no longer matches edited search content
+	if session.SearchContent != tmp.SearchContent && slices.Equal(session.Embedding, tmp.Embedding) {
+		session.Embedding = nil
+	}
+	if err := s.ensureSessionEmbedding(session); err != nil {
+		return fmt.Errorf("update session in memory store failed, %w", err)
+	}
+	if len(session.Embedding) > 0 {
+		updateReq.UpdateRowChange.PutColumn(SessionEmbeddingField, encodeVector(session.Embedding))
+	} else {
+		updateReq.UpdateRowChange.DeleteColumn(SessionEmbeddingField)
+	}
 	for k, v := range session.Metadata {
 		updateReq.UpdateRowChange.PutColumn(k, v)
 	}
@@ -191,10 +448,55 @@ func (s *MemoryStore) UpdateSession(session *model.Session) error {
 	if _, err := s.clt.UpdateRow(updateReq); err != nil {
 		return fmt.Errorf("update session in memory store failed, %w", err)
 	}
+	// lifecycle columns left unset keep their stored value
+	session.CreateTime = cmp.Or(session.CreateTime, tmp.CreateTime)
+	session.Status = cmp.Or(session.Status, tmp.Status)
+	session.Pinned = tmp.Pinned
+	session.ActiveBranchID = tmp.ActiveBranchID
+	s.writes.record(s.SessionTableName, sessionWriteKey(session.UserID, session.SessionID), session.Clone())
+	s.rememberSessionOwner(session.UserID, session.SessionID)
 	return nil
 }
 
+// PinSession pin or unpin a session, pinned sessions are listed first by ListRecentSessions with WithPinnedFirst
+func (s *MemoryStore) PinSession(userID string, sessionID string, pinned bool) error {
+	session := model.Session{
+		UserID:    userID,
+		SessionID: sessionID,
+	}
+	if err := s.GetSession(&session); err != nil {
+		return fmt.Errorf("pin session failed, %w", err)
+	}
+	pk := new(tablestore.PrimaryKey)
+	pk.AddPrimaryKeyColumn(SessionUserIDField, userID)
+	pk.AddPrimaryKeyColumn(SessionSessionIDField, sessionID)
+	updateReq := new(tablestore.UpdateRowRequest)
+	updateReq.UpdateRowChange = new(tablestore.UpdateRowChange)
+	updateReq.UpdateRowChange.TableName = s.SessionTableName
+	updateReq.UpdateRowChange.PrimaryKey = pk
+	if pinned {
+		updateReq.UpdateRowChange.PutColumn(SessionPinnedField, true)
+	} else {
+		updateReq.UpdateRowChange.DeleteColumn(SessionPinnedField)
+	}
+	updateReq.UpdateRowChange.SetCondition(tablestore.RowExistenceExpectation_EXPECT_EXIST)
+	if _, err := s.clt.UpdateRow(updateReq); err != nil {
+		return fmt.Errorf("pin session failed, %w", err)
+	}
+	session.Pinned = pinned
+	s.writes.record(s.SessionTableName, sessionWriteKey(userID, sessionID), session.Clone())
+	return nil
+}
+
+// DeleteSession delete a session, or move it to the trash when soft delete is enabled
 func (s *MemoryStore) DeleteSession(userID string, sessionID string) error {
+	if s.SoftDelete {
+		return s.trashSession(userID, sessionID, model.CurrentTimeMicroseconds())
+	}
+	return s.deleteSession(userID, sessionID)
+}
+
+func (s *MemoryStore) deleteSession(userID string, sessionID string) error {
 	pk := new(tablestore.PrimaryKey)
 	pk.AddPrimaryKeyColumn(SessionUserIDField, userID)
 	pk.AddPrimaryKeyColumn(SessionSessionIDField, sessionID)
@@ -206,11 +508,14 @@ func (s *MemoryStore) DeleteSession(userID string, sessionID string) error {
 	if _, err := s.clt.DeleteRow(deleteReq); err != nil {
 		return fmt.Errorf("delete session in memory store failed, %w", err)
 	}
+	s.writes.record(s.SessionTableName, sessionWriteKey(userID, sessionID), nil)
+	s.owners.forget(userID, sessionID)
 	return nil
 }
 
+// DeleteSessions delete all sessions for a user, including sessions in the trash
 func (s *MemoryStore) DeleteSessions(userID string) (int, error) {
-	list := s.ListSessions(userID, nil, -1, 5000)
+	list := s.listSessions(userID, nil, -1, 5000)
 	var (
 		count int
 		total int
@@ -248,7 +553,7 @@ func (s *MemoryStore) DeleteSessions(userID string) (int, error) {
 }
 
 func (s *MemoryStore) DeleteAllSessions() (int, error) {
-	list := s.ListAllSessions()
+	list := s.listSessions("", nil, -1, 5000)
 	var (
 		count int
 		total int
@@ -285,7 +590,18 @@ func (s *MemoryStore) DeleteAllSessions() (int, error) {
 	return total, nil
 }
 
+// GetSession get a session, sessions in the trash are reported as not existing
 func (s *MemoryStore) GetSession(session *model.Session) error {
+	if err := s.getSession(session); err != nil {
+		return err
+	}
+	if session.IsDeleted() {
+		return fmt.Errorf("session not exists")
+	}
+	return nil
+}
+
+func (s *MemoryStore) getSession(session *model.Session) error {
 	pk := new(tablestore.PrimaryKey)
 	pk.AddPrimaryKeyColumn(SessionUserIDField, session.UserID)
 	pk.AddPrimaryKeyColumn(SessionSessionIDField, session.SessionID)
@@ -302,6 +618,7 @@ func (s *MemoryStore) GetSession(session *model.Session) error {
 		return fmt.Errorf("session not exists")
 	}
 	parseSessionFromRow(session, resp.Columns, &resp.PrimaryKey)
+	s.rememberSessionOwner(session.UserID, session.SessionID)
 	return nil
 }
 
@@ -309,7 +626,38 @@ func (s *MemoryStore) ListAllSessions() <-chan model.Session {
 	return s.ListSessions("", nil, -1, 5000)
 }
 
+// ListSessions list sessions of a user, or all sessions when userID is empty, skipping sessions in the trash
 func (s *MemoryStore) ListSessions(userID string, filter tablestore.ColumnFilter, maxCount int, batchSize int) <-chan model.Session {
+	return s.listSessions(userID, andColumnFilters(filter, notDeletedFilter(SessionDeletedAtField)), maxCount, batchSize)
+}
+
+// scanSessions call fn with the sessions of a user, or of all users when userID is empty, including
+// sessions in the trash. Unlike the listing channels it stops at the first read error.
+func (s *MemoryStore) scanSessions(userID string, filter tablestore.ColumnFilter, fn func(session *model.Session) error) error {
+	rangeReq := new(tablestore.GetRangeRequest)
+	rangeReq.RangeRowQueryCriteria = s.sessionRangeCriteria(userID, filter)
+	rangeReq.RangeRowQueryCriteria.Limit = 5000
+	for {
+		resp, err := s.clt.GetRange(rangeReq)
+		if err != nil {
+			return fmt.Errorf("failed to get list of sessions, %w", err)
+		}
+		for _, row := range resp.Rows {
+			var session model.Session
+			parseSessionFromRow(&session, row.Columns, row.PrimaryKey)
+			if err := fn(&session); err != nil {
+				return err
+			}
+		}
+		if resp.NextStartPrimaryKey == nil {
+			return nil
+		}
+		rangeReq.RangeRowQueryCriteria.StartPrimaryKey = resp.NextStartPrimaryKey
+	}
+}
+
+// sessionRangeCriteria range over the sessions of a user, or of all users when userID is empty
+func (s *MemoryStore) sessionRangeCriteria(userID string, filter tablestore.ColumnFilter) *tablestore.RangeRowQueryCriteria {
 	startPk := new(tablestore.PrimaryKey)
 	if userID != "" {
 		startPk.AddPrimaryKeyColumn(SessionUserIDField, userID)
@@ -333,6 +681,11 @@ func (s *MemoryStore) ListSessions(userID string, filter tablestore.ColumnFilter
 	if filter != nil {
 		criteria.Filter = filter
 	}
+	return criteria
+}
+
+func (s *MemoryStore) listSessions(userID string, filter tablestore.ColumnFilter, maxCount int, batchSize int) <-chan model.Session {
+	criteria := s.sessionRangeCriteria(userID, filter)
 	if maxCount <= 0 {
 		maxCount = -1
 	}
@@ -373,7 +726,43 @@ func (s *MemoryStore) ListSessions(userID string, filter tablestore.ColumnFilter
 	return retCh
 }
 
-func (s *MemoryStore) ListRecentSessions(userID string, filter tablestore.ColumnFilter, inclusiveStartUpdateTime int64, inclusiveEndUpdateTime int64, maxCount int, batchSize int) ([]model.Session, error) {
+// ListRecentSessions list sessions by update time descending. opts filter by status and list pinned
+// sessions first, rows written before the lifecycle columns existed count as active and unpinned.
+func (s *MemoryStore) ListRecentSessions(userID string, filter tablestore.ColumnFilter, inclusiveStartUpdateTime int64, inclusiveEndUpdateTime int64, maxCount int, batchSize int, opts ...model.SessionListOption) ([]model.Session, error) {
+	listOpts, err := model.NewSessionListOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	filter = andColumnFilters(filter, SessionStatusFilter(listOpts.Statuses...), notDeletedFilter(SessionDeletedAtField))
+	if !listOpts.PinnedFirst {
+		return s.listRecentSessions(userID, filter, inclusiveStartUpdateTime, inclusiveEndUpdateTime, maxCount, batchSize)
+	}
+	pinned, err := s.listRecentSessions(userID, andColumnFilters(filter, pinnedSessionsFilter(true)), inclusiveStartUpdateTime, inclusiveEndUpdateTime, maxCount, batchSize)
+	if err != nil {
+		return nil, err
+	}
+	if maxCount > 0 && len(pinned) >= maxCount {
+		return pinned[:maxCount], nil
+	}
+	remaining := maxCount
+	if maxCount > 0 {
+		remaining -= len(pinned)
+	}
+	unpinned, err := s.listRecentSessions(userID, andColumnFilters(filter, pinnedSessionsFilter(false)), inclusiveStartUpdateTime, inclusiveEndUpdateTime, remaining, batchSize)
+	if err != nil {
+		return nil, err
+	}
+	return append(pinned, unpinned...), nil
+}
+
+func (s *MemoryStore) listRecentSessions(userID string, filter tablestore.ColumnFilter, inclusiveStartUpdateTime int64, inclusiveEndUpdateTime int64, maxCount int, batchSize int) ([]model.Session, error) {
+	if s.staleSessionIndex() {
+		hits, err := s.recentSessionsFromTable(userID, filter, inclusiveStartUpdateTime, inclusiveEndUpdateTime)
+		if maxCount > 0 && len(hits) > maxCount {
+			hits = hits[:maxCount]
+		}
+		return hits, err
+	}
 	startPk := new(tablestore.PrimaryKey)
 	if userID != "" {
 		startPk.AddPrimaryKeyColumn(SessionUserIDField, userID)
@@ -442,7 +831,36 @@ func (s *MemoryStore) ListRecentSessions(userID string, filter tablestore.Column
 	return hits, nil
 }
 
-func (s *MemoryStore) ListRecentSessionsPaginated(userID string, filter tablestore.ColumnFilter, inclusiveStartUpdateTime int64, inclusiveEndUpdateTime int64, pageSize int, nextStartPrimaryKey *tablestore.PrimaryKey) (*model.Response[model.Session], error) {
+// ListRecentSessionsPaginated paginated ListRecentSessions. With WithPinnedFirst the pages only hold
+// unpinned sessions and all pinned sessions are prepended to the first page, so it may exceed pageSize.
+func (s *MemoryStore) ListRecentSessionsPaginated(userID string, filter tablestore.ColumnFilter, inclusiveStartUpdateTime int64, inclusiveEndUpdateTime int64, pageSize int, nextStartPrimaryKey *tablestore.PrimaryKey, opts ...model.SessionListOption) (*model.Response[model.Session], error) {
+	listOpts, err := model.NewSessionListOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	filter = andColumnFilters(filter, SessionStatusFilter(listOpts.Statuses...), notDeletedFilter(SessionDeletedAtField))
+	if !listOpts.PinnedFirst {
+		return s.listRecentSessionsPage(userID, filter, inclusiveStartUpdateTime, inclusiveEndUpdateTime, pageSize, nextStartPrimaryKey)
+	}
+	ret, err := s.listRecentSessionsPage(userID, andColumnFilters(filter, pinnedSessionsFilter(false)), inclusiveStartUpdateTime, inclusiveEndUpdateTime, pageSize, nextStartPrimaryKey)
+	if err != nil {
+		return nil, err
+	}
+	if nextStartPrimaryKey != nil {
+		return ret, nil
+	}
+	pinned, err := s.listRecentSessions(userID, andColumnFilters(filter, pinnedSessionsFilter(true)), inclusiveStartUpdateTime, inclusiveEndUpdateTime, -1, -1)
+	if err != nil {
+		return nil, err
+	}
+	ret.Hits = append(pinned, ret.Hits...)
+	return ret, nil
+}
+
+func (s *MemoryStore) listRecentSessionsPage(userID string, filter tablestore.ColumnFilter, inclusiveStartUpdateTime int64, inclusiveEndUpdateTime int64, pageSize int, nextStartPrimaryKey *tablestore.PrimaryKey) (*model.Response[model.Session], error) {
+	if s.staleSessionIndex() {
+		return s.recentSessionsPageFromTable(userID, filter, inclusiveStartUpdateTime, inclusiveEndUpdateTime, pageSize, nextStartPrimaryKey)
+	}
 	var startPk *tablestore.PrimaryKey
 	if nextStartPrimaryKey != nil {
 		startPk = nextStartPrimaryKey
@@ -501,7 +919,141 @@ func (s *MemoryStore) ListRecentSessionsPaginated(userID string, filter tablesto
 	return ret, nil
 }
 
-func (s *MemoryStore) SearchSessions(userID string, keyword string, inclusiveStartUpdateTime int64, inclusiveEndUpdateTime int64, pageSize int32, nextToken []byte) (*model.Response[model.Session], error) {
+// sessionSortValue value of an indexed session field, nil when missing
+func sessionSortValue(v *model.Session, field string) any {
+	switch field {
+	case SessionUpdateTimeField:
+		return v.UpdateTime
+	case SessionUserIDField:
+		return v.UserID
+	case SessionCreateTimeField:
+		if v.CreateTime == 0 {
+			return nil
+		}
+		return v.CreateTime
+	case SessionStatusField:
+		if v.Status == "" {
+			return nil
+		}
+		return string(v.Status)
+	case SessionPinnedField:
+		if !v.Pinned {
+			return nil
+		}
+		return true
+	}
+	if value, ok := v.Metadata.Get(field); ok {
+		return value
+	}
+	return nil
+}
+
+// recentSessionsFromTable sessions in the order of the session secondary index, read from the session
+// table while the index lacks the columns filter needs
+func (s *MemoryStore) recentSessionsFromTable(userID string, filter tablestore.ColumnFilter, inclusiveStartUpdateTime int64, inclusiveEndUpdateTime int64) ([]model.Session, error) {
+	var hits []model.Session
+	if err := s.scanSessions(userID, filter, func(session *model.Session) error {
+		if inTimeRange(session.UpdateTime, inclusiveEndUpdateTime, inclusiveStartUpdateTime) {
+			hits = append(hits, *session)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	slices.SortFunc(hits, func(a, b model.Session) int {
+		return compareSessionIndexKeys(&b, &a)
+	})
+	return hits, nil
+}
+
+// recentSessionsPageFromTable page of recentSessionsFromTable, nextStartPrimaryKey is a key of the
+// session secondary index as returned by the index
+func (s *MemoryStore) recentSessionsPageFromTable(userID string, filter tablestore.ColumnFilter, inclusiveStartUpdateTime int64, inclusiveEndUpdateTime int64, pageSize int, nextStartPrimaryKey *tablestore.PrimaryKey) (*model.Response[model.Session], error) {
+	hits, err := s.recentSessionsFromTable(userID, filter, inclusiveStartUpdateTime, inclusiveEndUpdateTime)
+	if err != nil {
+		return nil, err
+	}
+	if nextStartPrimaryKey != nil {
+		var start model.Session
+		parseSessionFromRow(&start, nil, nextStartPrimaryKey)
+		// the next page starts at the key, inclusive as with the index
+		idx := slices.IndexFunc(hits, func(v model.Session) bool { return compareSessionIndexKeys(&v, &start) <= 0 })
+		if idx < 0 {
+			idx = len(hits)
+		}
+		hits = hits[idx:]
+	}
+	ret := new(model.Response[model.Session])
+	if pageSize > 0 && len(hits) > pageSize {
+		next := hits[pageSize]
+		ret.NextStartPrimaryKey = new(tablestore.PrimaryKey)
+		ret.NextStartPrimaryKey.AddPrimaryKeyColumn(SessionUserIDField, next.UserID)
+		ret.NextStartPrimaryKey.AddPrimaryKeyColumn(SessionUpdateTimeField, next.UpdateTime)
+		ret.NextStartPrimaryKey.AddPrimaryKeyColumn(SessionSessionIDField, next.SessionID)
+		hits = hits[:pageSize]
+	}
+	ret.Hits = hits
+	return ret, nil
+}
+
+// compareSessionIndexKeys compares sessions by the primary key of the session secondary index
+func compareSessionIndexKeys(a *model.Session, b *model.Session) int {
+	return cmp.Or(
+		cmp.Compare(a.UserID, b.UserID),
+		cmp.Compare(a.UpdateTime, b.UpdateTime),
+		cmp.Compare(a.SessionID, b.SessionID),
+	)
+}
+
+// SessionStatusFilter column filter keeping sessions in one of statuses, sessions without status count
+// as active. Pass it to ListSessions, ListRecentSessions takes WithSessionStatus instead. It returns nil
+// when statuses is empty.
+func SessionStatusFilter(statuses ...model.SessionStatus) tablestore.ColumnFilter {
+	if len(statuses) == 0 {
+		return nil
+	}
+	// a missing status column passes the conditions only when active sessions are wanted
+	filterIfMissing := !slices.Contains(statuses, model.SessionStatusActive)
+	conditions := make([]*tablestore.SingleColumnCondition, 0, len(statuses))
+	for _, status := range slices.Compact(slices.Sorted(slices.Values(statuses))) {
+		condition := tablestore.NewSingleColumnCondition(SessionStatusField, tablestore.CT_EQUAL, string(status))
+		condition.FilterIfMissing = filterIfMissing
+		conditions = append(conditions, condition)
+	}
+	if len(conditions) == 1 {
+		return conditions[0]
+	}
+	filter := tablestore.NewCompositeColumnCondition(tablestore.LO_OR)
+	for _, condition := range conditions {
+		filter.AddFilter(condition)
+	}
+	return filter
+}
+
+// pinnedSessionsFilter column filter keeping pinned or unpinned sessions
+func pinnedSessionsFilter(pinned bool) tablestore.ColumnFilter {
+	if pinned {
+		condition := tablestore.NewSingleColumnCondition(SessionPinnedField, tablestore.CT_EQUAL, true)
+		condition.FilterIfMissing = true
+		return condition
+	}
+	return tablestore.NewSingleColumnCondition(SessionPinnedField, tablestore.CT_NOT_EQUAL, true)
+}
+
+// sessionIndexedFields session search index fields search results can be sorted and aggregated by
+func (s *MemoryStore) sessionIndexedFields() []string {
+	ret := []string{SessionUpdateTimeField, SessionUserIDField, SessionCreateTimeField, SessionStatusField, SessionPinnedField}
+	for _, v := range s.SessionIndexFields {
+		ret = append(ret, v.Name)
+	}
+	return ret
+}
+
+func (s *MemoryStore) SearchSessions(userID string, keyword string, inclusiveStartUpdateTime int64, inclusiveEndUpdateTime int64, pageSize int32, nextToken []byte, opts ...model.SearchOption) (*model.Response[model.Session], error) {
+	searchOpts, err := model.NewSearchOptions(model.QueryModePhrase, opts...)
+	if err != nil {
+		return nil, err
+	}
 	searchReq := new(tablestore.SearchRequest)
 	searchReq.SetTableName(s.SessionTableName)
 	searchReq.SetIndexName(s.SessionSearchIndexName)
@@ -531,28 +1083,34 @@ func (s *MemoryStore) SearchSessions(userID string, keyword string, inclusiveSta
 		queries = append(queries, rangeQuery)
 	}
 	if keyword != "" {
-		queries = append(queries, &search.MatchPhraseQuery{
-			FieldName: SessionSearchContentField,
-			Text:      keyword,
-		})
+		query, err := keywordQuery(SessionSearchContentField, keyword, searchOpts)
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, query)
 	}
 	searchQuery := search.NewSearchQuery()
 	if l := len(queries); l > 1 {
-		searchQuery.SetQuery(&search.BoolQuery{
+		searchQuery.SetQuery(s.excludeDeleted(&search.BoolQuery{
 			MustQueries: queries,
-		})
+		}, SessionDeletedAtField))
 	} else if l == 1 {
-		searchQuery.SetQuery(queries[0])
+		searchQuery.SetQuery(s.excludeDeleted(queries[0], SessionDeletedAtField))
 	} else {
 		return nil, errors.New("missing search conditions")
 	}
-	searchQuery.SetSort(&search.Sort{
-		Sorters: []search.Sorter{
-			&search.ScoreSort{
-				Order: search.SortOrder_DESC.Enum(), // 从得分高到低排序。
-			},
-		},
+	querySort, err := searchSort(searchOpts, s.sessionIndexedFields(), &search.ScoreSort{
+		Order: search.SortOrder_DESC.Enum(), // 从得分高到低排序。
 	})
+	if err != nil {
+		return nil, err
+	}
+	searchQuery.SetSort(querySort)
+	if keyword != "" {
+		if highlight := searchHighlight(SessionSearchContentField, s.Highlight); highlight != nil {
+			searchQuery.SetHighlight(highlight)
+		}
+	}
 	searchQuery.SetGetTotalCount(true)
 	searchQuery.SetLimit(pageSize)
 	if nextToken != nil {
@@ -562,6 +1120,11 @@ func (s *MemoryStore) SearchSessions(userID string, keyword string, inclusiveSta
 	searchReq.SetColumnsToGet(&tablestore.ColumnsToGet{
 		ReturnAll: true,
 	})
+	if searchOpts.Consistency == model.ConsistencyWaitIndex {
+		if err := s.waitForIndex(s.SessionTableName, s.SessionSearchIndexName, searchOpts.ConsistencyTimeout); err != nil {
+			return nil, err
+		}
+	}
 	resp, err := s.clt.Search(searchReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search sessions, %w", err)
@@ -574,8 +1137,19 @@ func (s *MemoryStore) SearchSessions(userID string, keyword string, inclusiveSta
 		parseSessionFromRow(&session, row.Columns, row.PrimaryKey)
 		ret.Hits = append(ret.Hits, session)
 	}
+	ret.Details = hitDetails(resp, SessionSearchContentField)
 	if resp.NextToken != nil {
 		ret.NextToken = resp.NextToken
 	}
+	if searchOpts.Consistency == model.ConsistencyMergeWrites && nextToken == nil {
+		mergeWrites(ret, s.writes.recent(s.SessionTableName), int(pageSize), func(v *model.Session) string {
+			return sessionWriteKey(v.UserID, v.SessionID)
+		}, func(v *model.Session) bool {
+			return (userID == "" || v.UserID == userID) &&
+				inTimeRange(v.UpdateTime, inclusiveStartUpdateTime, inclusiveEndUpdateTime) &&
+				model.MatchKeyword(v.SearchContent, keyword, searchOpts) &&
+				v.DeletedAt == 0
+		}, mergeCompare(searchOpts.Sort, sessionSortValue))
+	}
 	return ret, nil
 }
@@ -0,0 +1,237 @@
+package tablestore
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+// ErrStreamAborted returned by AppendChunk and FinishMessage once the message stopped streaming, aborted
+// by AbortStaleStreams of another store for instance, the stream is then ended
+var ErrStreamAborted = errors.New("message stream aborted")
+
+// messageStream partial content of a message being written by BeginMessage
+type messageStream struct {
+	mu      sync.Mutex
+	message *model.Message
+	content strings.Builder
+	// pending bytes appended since the last flush
+	pending   int
+	flushedAt time.Time
+}
+
+// streamingFilter column filter keeping messages whose stream is still in progress
+func streamingFilter() tablestore.ColumnFilter {
+	condition := tablestore.NewSingleColumnCondition(MessageStreamStatusField, tablestore.CT_EQUAL, string(model.StreamStatusStreaming))
+	condition.FilterIfMissing = true
+	return condition
+}
+
+// BeginMessage write a message whose content is appended by AppendChunk and made final by FinishMessage,
+// the row is visible to readers with stream status streaming and the content written so far.
+// It fails when the message already exists.
+func (s *MemoryStore) BeginMessage(message *model.Message) error {
+	key := messageWriteKey(message.SessionID, message.MessageID)
+	if _, ok := s.streams.Load(key); ok {
+		return errors.New("begin message failed, message is already streaming")
+	}
+	if message.UserID == "" {
+		userID, err := s.resolveSessionOwner(message.SessionID)
+		if err != nil {
+			return fmt.Errorf("begin message failed, %w", err)
+		}
+		message.UserID = userID
+	}
+	message.StreamStatus = model.StreamStatusStreaming
+	message.StreamUpdateTime = model.CurrentTimeMicroseconds()
+	putReq := new(tablestore.PutRowRequest)
+	putReq.PutRowChange = s.messagePutRowChange(message)
+	putReq.PutRowChange.SetCondition(tablestore.RowExistenceExpectation_EXPECT_NOT_EXIST)
+	if _, err := s.clt.PutRow(putReq); err != nil {
+		return fmt.Errorf("begin message failed, %w", err)
+	}
+	s.writes.record(s.MessageTableName, key, message.Clone())
+	stream := &messageStream{
+		message:   message.Clone(),
+		flushedAt: time.Now(),
+	}
+	stream.content.WriteString(message.Content)
+	if _, loaded := s.streams.LoadOrStore(key, stream); loaded {
+		return errors.New("begin message failed, message is already streaming")
+	}
+	return nil
+}
+
+func (s *MemoryStore) messageStream(sessionID string, messageID string) (*messageStream, error) {
+	v, ok := s.streams.Load(messageWriteKey(sessionID, messageID))
+	if !ok {
+		return nil, errors.New("message is not streaming")
+	}
+	return v.(*messageStream), nil
+}
+
+// AppendChunk append a chunk to the content of a message started by BeginMessage. The content is
+// written once StreamFlushInterval elapsed or StreamFlushBytes are pending since the last write, and
+// fails with ErrStreamAborted once the stream was aborted, by AbortStaleStreams for instance.
+func (s *MemoryStore) AppendChunk(sessionID string, messageID string, chunk string) error {
+	stream, err := s.messageStream(sessionID, messageID)
+	if err != nil {
+		return fmt.Errorf("append chunk failed, %w", err)
+	}
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	stream.content.WriteString(chunk)
+	stream.pending += len(chunk)
+	if stream.pending < s.StreamFlushBytes && time.Since(stream.flushedAt) < s.StreamFlushInterval {
+		return nil
+	}
+	if err := s.flushMessageStream(stream, model.StreamStatusStreaming); err != nil {
+		s.endAbortedStream(stream, err)
+		return fmt.Errorf("append chunk failed, %w", err)
+	}
+	return nil
+}
+
+// FinishMessage write the full content of a message started by BeginMessage with stream status complete,
+// computing its embedding when an Embedder is configured. The final content becomes the search_content
+// unless the message passed to BeginMessage set SearchContent. A failed finish can be retried unless it fails
+// with ErrStreamAborted.
+func (s *MemoryStore) FinishMessage(sessionID string, messageID string) error {
+	stream, err := s.messageStream(sessionID, messageID)
+	if err != nil {
+		return fmt.Errorf("finish message failed, %w", err)
+	}
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	if err := s.flushMessageStream(stream, model.StreamStatusComplete); err != nil {
+		s.endAbortedStream(stream, err)
+		return fmt.Errorf("finish message failed, %w", err)
+	}
+	s.streams.Delete(messageWriteKey(sessionID, messageID))
+	return nil
+}
+
+// AbortMessage write the partial content of a message started by BeginMessage with stream status aborted.
+// The stream ends even when the write fails, AbortStaleStreams marks it later.
+func (s *MemoryStore) AbortMessage(sessionID string, messageID string) error {
+	stream, err := s.messageStream(sessionID, messageID)
+	if err != nil {
+		return fmt.Errorf("abort message failed, %w", err)
+	}
+	s.streams.Delete(messageWriteKey(sessionID, messageID))
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	if err := s.flushMessageStream(stream, model.StreamStatusAborted); err != nil {
+		return fmt.Errorf("abort message failed, %w", err)
+	}
+	return nil
+}
+
+// flushMessageStream write the content of a stream with status as long as the row is still streaming,
+// it fails with ErrStreamAborted otherwise. The caller holds the stream lock.
+func (s *MemoryStore) flushMessageStream(stream *messageStream, status model.StreamStatus) error {
+	message := stream.message.Clone()
+	message.Content = stream.content.String()
+	message.StreamStatus = status
+	message.StreamUpdateTime = model.CurrentTimeMicroseconds()
+	updateReq := new(tablestore.UpdateRowRequest)
+	updateReq.UpdateRowChange = new(tablestore.UpdateRowChange)
+	updateReq.UpdateRowChange.TableName = s.MessageTableName
+	updateReq.UpdateRowChange.PrimaryKey = messagePrimaryKey(message.SessionID, message.CreateTime, message.MessageID)
+	if message.Content != "" {
+		updateReq.UpdateRowChange.PutColumn(MessageContentField, message.Content)
+	}
+	if status == model.StreamStatusComplete {
+		// partial content is not searched, the final content is
+		if message.SearchContent == "" {
+			message.SearchContent = message.Content
+		}
+		if message.SearchContent != "" {
+			updateReq.UpdateRowChange.PutColumn(MessageSearchContentField, message.SearchContent)
+		}
+		if err := s.ensureMessageEmbedding(message); err != nil {
+			return err
+		}
+		if len(message.Embedding) > 0 {
+			updateReq.UpdateRowChange.PutColumn(MessageEmbeddingField, encodeVector(message.Embedding))
+		}
+	}
+	updateReq.UpdateRowChange.PutColumn(MessageStreamStatusField, string(status))
+	updateReq.UpdateRowChange.PutColumn(MessageStreamUpdateField, message.StreamUpdateTime)
+	updateReq.UpdateRowChange.SetCondition(tablestore.RowExistenceExpectation_EXPECT_EXIST)
+	updateReq.UpdateRowChange.SetColumnCondition(streamingFilter())
+	if _, err := s.clt.UpdateRow(updateReq); err != nil {
+		if isOtsErrorCode(err, conditionCheckFailCode) {
+			return fmt.Errorf("%w, %w", ErrStreamAborted, err)
+		}
+		return err
+	}
+	stream.message = message
+	stream.pending = 0
+	stream.flushedAt = time.Now()
+	s.writes.record(s.MessageTableName, messageWriteKey(message.SessionID, message.MessageID), message.Clone())
+	return nil
+}
+
+// endAbortedStream drops a stream whose flush failed because the row no longer streams
+func (s *MemoryStore) endAbortedStream(stream *messageStream, err error) {
+	if errors.Is(err, ErrStreamAborted) {
+		s.streams.Delete(messageWriteKey(stream.message.SessionID, stream.message.MessageID))
+	}
+}
+
+// AbortStaleStreams mark as aborted the messages of a session, or of all sessions when sessionID is
+// empty, still streaming without a write for staleAfter, 0 means DefaultStreamStaleAfter. It recovers
+// streams abandoned by a crashed writer and skips streams open in this store. It returns the number
+// of messages marked.
+func (s *MemoryStore) AbortStaleStreams(sessionID string, staleAfter time.Duration) (int, error) {
+	if staleAfter <= 0 {
+		staleAfter = model.DefaultStreamStaleAfter
+	}
+	cutoff := model.CurrentTimeMicroseconds() - staleAfter.Microseconds()
+	staleFilter := func() tablestore.ColumnFilter {
+		condition := tablestore.NewSingleColumnCondition(MessageStreamUpdateField, tablestore.CT_LESS_THAN, cutoff)
+		condition.FilterIfMissing = true
+		return andColumnFilters(streamingFilter(), condition)
+	}
+	writer := s.newBatchWriter(0, 0)
+	// skip streams written since they were listed
+	writer.skipConditionFailed = true
+	var addErr error
+	for message := range s.listStoredMessages(sessionID, staleFilter(), 0, 0, tablestore.FORWARD, -1, 5000) {
+		if addErr != nil {
+			// drain the channel so the producer is not blocked
+			continue
+		}
+		key := messageWriteKey(message.SessionID, message.MessageID)
+		if _, ok := s.streams.Load(key); ok {
+			continue
+		}
+		rowChange := new(tablestore.UpdateRowChange)
+		rowChange.TableName = s.MessageTableName
+		rowChange.PrimaryKey = messagePrimaryKey(message.SessionID, message.CreateTime, message.MessageID)
+		rowChange.PutColumn(MessageStreamStatusField, string(model.StreamStatusAborted))
+		rowChange.SetCondition(tablestore.RowExistenceExpectation_EXPECT_EXIST)
+		rowChange.SetColumnCondition(staleFilter())
+		aborted := message.Clone()
+		aborted.StreamStatus = model.StreamStatusAborted
+		addErr = writer.Add(rowChange, func(written bool) {
+			if written {
+				s.writes.record(s.MessageTableName, key, aborted)
+			}
+		})
+	}
+	if addErr != nil {
+		return writer.Written(), fmt.Errorf("abort stale streams failed, %w", addErr)
+	}
+	if err := writer.Flush(); err != nil {
+		return writer.Written(), fmt.Errorf("abort stale streams failed, %w", err)
+	}
+	return writer.Written(), nil
+}
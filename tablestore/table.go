@@ -0,0 +1,134 @@
+package tablestore
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/golang/protobuf/proto"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+// newTableOption table options of a new table, unset fields take the defaults
+func newTableOption(opts model.TableOptions) *tablestore.TableOption {
+	opts.Normalize()
+	tableOption := new(tablestore.TableOption)
+	tableOption.MaxVersion = opts.MaxVersion
+	tableOption.TimeToAlive = opts.TimeToAlive
+	tableOption.DeviationCellVersionInSec = opts.DeviationCellVersionInSec
+	if opts.DisableUpdate {
+		tableOption.AllowUpdate = proto.Bool(false)
+	}
+	return tableOption
+}
+
+func newReservedThroughput(opts model.TableOptions) *tablestore.ReservedThroughput {
+	reservedThroughput := new(tablestore.ReservedThroughput)
+	reservedThroughput.Readcap = opts.ReservedReadCU
+	reservedThroughput.Writecap = opts.ReservedWriteCU
+	return reservedThroughput
+}
+
+func searchIndexTTL(opts model.TableOptions) *int32 {
+	if opts.SearchIndexTTL == 0 {
+		return nil
+	}
+	return proto.Int32(opts.SearchIndexTTL)
+}
+
+// addMissingDefinedColumns adds the defined columns of columns missing from an existing table
+func (s *tableClient) addMissingDefinedColumns(tableName string, meta *tablestore.TableMeta, columns []*tablestore.DefinedColumnSchema) error {
+	req := new(tablestore.AddDefinedColumnRequest)
+	req.TableName = tableName
+	for _, col := range columns {
+		if meta != nil && slices.ContainsFunc(meta.DefinedColumns, func(v *tablestore.DefinedColumnSchema) bool {
+			return v.Name == col.Name
+		}) {
+			continue
+		}
+		req.DefinedColumns = append(req.DefinedColumns, col)
+	}
+	if len(req.DefinedColumns) == 0 {
+		return nil
+	}
+	if _, err := s.clt.AddDefinedColumn(req); err != nil {
+		return fmt.Errorf("add defined columns to table %s failed, %w", tableName, err)
+	}
+	return nil
+}
+
+// reconcileTable updates table options and reserved throughput of an existing table when the fields set
+// in opts drift, fields left to their zero value keep the current setting
+func (s *tableClient) reconcileTable(tableName string, opts model.TableOptions, describeResp *tablestore.DescribeTableResponse) error {
+	if opts.SSE != nil && opts.SSE.Enable && (describeResp.SSEDetails == nil || !describeResp.SSEDetails.Enable) {
+		return fmt.Errorf("server side encryption of table %s can only be enabled on creation", tableName)
+	}
+	updateReq := new(tablestore.UpdateTableRequest)
+	updateReq.TableName = tableName
+	current := describeResp.TableOption
+	if current == nil {
+		current = new(tablestore.TableOption)
+	}
+	tableOption := &tablestore.TableOption{
+		TimeToAlive:               current.TimeToAlive,
+		MaxVersion:                current.MaxVersion,
+		DeviationCellVersionInSec: current.DeviationCellVersionInSec,
+	}
+	var drift bool
+	if opts.TimeToAlive != 0 && opts.TimeToAlive != current.TimeToAlive {
+		tableOption.TimeToAlive = opts.TimeToAlive
+		drift = true
+	}
+	if opts.MaxVersion > 0 && opts.MaxVersion != current.MaxVersion {
+		tableOption.MaxVersion = opts.MaxVersion
+		drift = true
+	}
+	if opts.DeviationCellVersionInSec > 0 && opts.DeviationCellVersionInSec != current.DeviationCellVersionInSec {
+		tableOption.DeviationCellVersionInSec = opts.DeviationCellVersionInSec
+		drift = true
+	}
+	if opts.DisableUpdate && (current.AllowUpdate == nil || *current.AllowUpdate) {
+		tableOption.AllowUpdate = proto.Bool(false)
+		drift = true
+	}
+	if drift {
+		updateReq.TableOption = tableOption
+	}
+	if opts.ReservedReadCU > 0 || opts.ReservedWriteCU > 0 {
+		if current := describeResp.ReservedThroughput; current == nil ||
+			current.Readcap != opts.ReservedReadCU ||
+			current.Writecap != opts.ReservedWriteCU {
+			updateReq.ReservedThroughput = newReservedThroughput(opts)
+		}
+	}
+	if updateReq.TableOption == nil && updateReq.ReservedThroughput == nil {
+		return nil
+	}
+	if _, err := s.clt.UpdateTable(updateReq); err != nil {
+		return fmt.Errorf("update table %s failed, %w", tableName, err)
+	}
+	return nil
+}
+
+// reconcileSearchIndexTTL updates search index ttl when it is set in opts and drifts
+func (s *tableClient) reconcileSearchIndexTTL(tableName string, indexName string, opts model.TableOptions) error {
+	describeReq := new(tablestore.DescribeSearchIndexRequest)
+	describeReq.TableName = tableName
+	describeReq.IndexName = indexName
+	describeResp, err := s.clt.DescribeSearchIndex(describeReq)
+	if err != nil {
+		return fmt.Errorf("describe search index %s failed, %w", indexName, err)
+	}
+	if opts.SearchIndexTTL == 0 || describeResp.TimeToLive == opts.SearchIndexTTL {
+		return nil
+	}
+	updateReq := new(tablestore.UpdateSearchIndexRequest)
+	updateReq.TableName = tableName
+	updateReq.IndexName = indexName
+	updateReq.TimeToLive = searchIndexTTL(opts)
+	if _, err := s.clt.UpdateSearchIndex(updateReq); err != nil {
+		return fmt.Errorf("update search index %s ttl failed, %w", indexName, err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bububa/tablestore-memory/model"
+	tb "github.com/bububa/tablestore-memory/tablestore"
+)
+
+func TestAggregateMessages(t *testing.T) {
+	store := MemoryStore(
+		model.WithSessionTableName("session_aggregation"),
+		model.WithMessageTableName("message_aggregation"),
+		model.WithMemoryTableName("memory_aggregation"),
+		model.WithSessionIndexFields(model.IndexField{Name: "topic", Type: model.STRING}),
+		model.WithMessageIndexFields(model.IndexField{Name: model.MetadataRoleKey, Type: model.STRING}),
+	)
+	if err := store.InitTable(); err != nil {
+		t.Fatal(err)
+	}
+	userID := "user_aggregation_1"
+	for idx, topic := range []string{"billing", "billing", "account"} {
+		session := model.NewSession(userID, fmt.Sprintf("session_aggregation_%d", idx))
+		session.Metadata.Put("topic", topic)
+		if err := store.PutSession(session); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sessionID := "session_aggregation_0"
+	day := model.HistogramDay
+	roles := []model.Role{model.RoleUser, model.RoleAssistant, model.RoleUser, model.RoleUser}
+	for idx, role := range roles {
+		message := model.NewMessage(sessionID, fmt.Sprintf("message_%d", idx))
+		message.SetCreateTime(day*int64(idx/2) + int64(idx) + 1).SetRole(role)
+		if err := store.PutMessage(message); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(time.Second * 11)
+	resp, err := store.AggregateMessages(model.AggregationRequest{
+		SessionID: sessionID,
+		Aggregations: []model.Aggregation{
+			model.CountAggregation("messages", tb.MessageCreateTimeField),
+			model.MaxAggregation("last", tb.MessageCreateTimeField),
+			model.HistogramAggregation("per_day", tb.MessageCreateTimeField, day, 0, 2*day,
+				model.TermsAggregation("roles", model.MetadataRoleKey, 0),
+			),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Total != 4 || resp.Results["messages"].Count != 4 {
+		t.Errorf("expected 4 messages, got total:%d count:%d", resp.Total, resp.Results["messages"].Count)
+	}
+	if last := resp.Results["last"]; !last.HasValue || int64(last.Value) != day+4 {
+		t.Errorf("unexpected max create time: %+v", last)
+	}
+	buckets := resp.Results["per_day"].Buckets
+	if len(buckets) != 2 || buckets[0].NumericKey != 0 || buckets[1].NumericKey != day {
+		t.Fatalf("unexpected daily buckets: %+v", buckets)
+	}
+	for idx, bucket := range buckets {
+		if bucket.Count != 2 {
+			t.Errorf("expected 2 messages on day %d, got:%d", idx, bucket.Count)
+		}
+		if n := len(bucket.SubAggregations["roles"].Buckets); n == 0 {
+			t.Errorf("expected role buckets on day %d", idx)
+		}
+	}
+	if _, err := store.AggregateMessages(model.AggregationRequest{
+		Aggregations: []model.Aggregation{model.CountAggregation("c", "unindexed")},
+	}); err == nil {
+		t.Error("expected unindexed field error")
+	}
+	sessions, err := store.AggregateSessions(model.AggregationRequest{
+		UserID: userID,
+		Aggregations: []model.Aggregation{
+			model.TermsAggregation("topics", "topic", 10),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	topics := sessions.Results["topics"].Buckets
+	if len(topics) != 2 || topics[0].Key != "billing" || topics[0].Count != 2 {
+		t.Errorf("unexpected topic buckets: %+v", topics)
+	}
+	if err := store.DeleteTableAndIndex(); err != nil {
+		t.Error(err)
+	}
+}
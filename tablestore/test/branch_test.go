@@ -0,0 +1,105 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+func messageIDs(messages []model.Message) []string {
+	ret := make([]string, 0, len(messages))
+	for _, message := range messages {
+		ret = append(ret, message.MessageID)
+	}
+	return ret
+}
+
+func TestConversationBranches(t *testing.T) {
+	store := MemoryStore(
+		model.WithSessionTableName("session_branch"),
+		model.WithMessageTableName("message_branch"),
+		model.WithMemoryTableName("memory_branch"),
+	)
+	if err := store.InitTable(); err != nil {
+		t.Fatal(err)
+	}
+	userID := "user_branch_1"
+	if _, err := store.DeleteSessions(userID); err != nil {
+		t.Fatal(err)
+	}
+	session := model.NewSession(userID, "session_branch_1")
+	if err := store.PutSession(session); err != nil {
+		t.Fatal(err)
+	}
+	for _, sessionID := range []string{session.SessionID, "session_branch_fork"} {
+		if _, err := store.DeleteMessages(sessionID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	question := model.NewMessage(session.SessionID, "q1").SetCreateTime(1).SetContent("question")
+	answer := model.NewReply(question, "a1").SetCreateTime(2).SetContent("answer")
+	regenerated := model.NewAlternative(answer, "a2", "branch_1").SetCreateTime(3).SetContent("regenerated answer")
+	followUp := model.NewReply(regenerated, "q2").SetCreateTime(4).SetContent("follow up")
+	for _, message := range []*model.Message{question, answer, regenerated, followUp} {
+		if err := store.PutMessage(message); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path, err := store.ListActivePath(userID, session.SessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := messageIDs(path); len(ids) != 2 || ids[0] != "q1" || ids[1] != "a1" {
+		t.Errorf("unexpected main branch path: %v", ids)
+	}
+	siblings, err := store.ListMessageSiblings(session.SessionID, "a1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := messageIDs(siblings); len(ids) != 2 || ids[0] != "a1" || ids[1] != "a2" {
+		t.Errorf("unexpected siblings: %v", ids)
+	}
+
+	if err := store.SwitchBranch(userID, session.SessionID, "branch_missing"); err == nil {
+		t.Error("expected switching to an empty branch to fail")
+	}
+	if err := store.SwitchBranch(userID, session.SessionID, "branch_1"); err != nil {
+		t.Fatal(err)
+	}
+	path, err = store.ListActivePath(userID, session.SessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := messageIDs(path); len(ids) != 3 || ids[0] != "q1" || ids[1] != "a2" || ids[2] != "q2" {
+		t.Errorf("unexpected branch_1 path: %v", ids)
+	}
+	if err := store.UpdateSession(model.NewSession(userID, session.SessionID).SetTitle("renamed")); err != nil {
+		t.Fatal(err)
+	}
+	got := model.Session{UserID: userID, SessionID: session.SessionID}
+	if err := store.GetSession(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ActiveBranchID != "branch_1" {
+		t.Errorf("expected update to keep active branch, got:%q", got.ActiveBranchID)
+	}
+
+	fork, err := store.ForkSession(userID, session.SessionID, "a2", "session_branch_fork")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fork.ActiveBranchID != "" || !fork.IsActive() {
+		t.Errorf("unexpected fork: %+v", fork)
+	}
+	path, err = store.ListActivePath(userID, fork.SessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := messageIDs(path); len(ids) != 2 || ids[0] != "q1" || ids[1] != "a2" || path[1].BranchID != "" {
+		t.Errorf("unexpected fork path: %+v", path)
+	}
+	if _, err := store.ForkSession(userID, session.SessionID, "a2", "session_branch_fork"); err == nil {
+		t.Error("expected forking into an existing session to fail")
+	}
+}
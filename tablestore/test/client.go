@@ -4,17 +4,32 @@ import (
 	"os"
 
 	"github.com/bububa/tablestore-memory/client"
+	"github.com/bububa/tablestore-memory/model"
 	"github.com/bububa/tablestore-memory/protocol"
 )
 
-func MemoryStore() protocol.MemoryStore {
+func MemoryStore(opts ...model.Option) protocol.MemoryStore {
 	cfg := client.Config{
 		Endpoint:        os.Getenv("OTS_ENDPOINT"),
 		Instance:        os.Getenv("OTS_INSTANCE"),
 		AccessKeyID:     os.Getenv("OTS_AK"),
 		AccessKeySecret: os.Getenv("OTS_SK"),
 	}
-	store, err := client.NewMemoryStore(&cfg)
+	store, err := client.NewMemoryStore(&cfg, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return store
+}
+
+func KnowledgeStore(opts ...model.KnowledgeOption) protocol.KnowledgeStore {
+	cfg := client.Config{
+		Endpoint:        os.Getenv("OTS_ENDPOINT"),
+		Instance:        os.Getenv("OTS_INSTANCE"),
+		AccessKeyID:     os.Getenv("OTS_AK"),
+		AccessKeySecret: os.Getenv("OTS_SK"),
+	}
+	store, err := client.NewKnowledgeStore(&cfg, opts...)
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,81 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+func TestCloneSession(t *testing.T) {
+	store := MemoryStore(
+		model.WithSessionTableName("session_clone"),
+		model.WithMessageTableName("message_clone"),
+		model.WithMemoryTableName("memory_clone"),
+	)
+	if err := store.InitTable(); err != nil {
+		t.Fatal(err)
+	}
+	srcUserID, dstUserID := "user_clone_src", "user_clone_dst"
+	for _, userID := range []string{srcUserID, dstUserID} {
+		if _, err := store.DeleteSessions(userID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, sessionID := range []string{"session_clone_src", "session_clone_dst", "session_clone_truncated"} {
+		if _, err := store.DeleteMessages(sessionID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	source := model.NewSession(srcUserID, "session_clone_src").SetTitle("production issue")
+	if err := store.PutSession(source); err != nil {
+		t.Fatal(err)
+	}
+	first := model.NewMessage(source.SessionID, "m1").SetCreateTime(1).SetContent("first")
+	second := model.NewReply(first, "m2").SetCreateTime(2).SetContent("second")
+	third := model.NewReply(second, "m3").SetCreateTime(3).SetContent("third")
+	for _, message := range []*model.Message{first, second, third} {
+		if err := store.PutMessage(message); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := store.CloneSession(srcUserID, source.SessionID, dstUserID, "session_clone_dst", model.CloneOptions{Provenance: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Messages != 3 || report.Session.Title != source.Title || report.Session.UserID != dstUserID {
+		t.Errorf("unexpected clone report: %+v", report)
+	}
+	copied := model.Message{SessionID: "session_clone_dst", MessageID: "m3"}
+	if err := store.GetMessage(&copied); err != nil {
+		t.Fatal(err)
+	}
+	if v := copied.Metadata.GetString(model.MetadataClonedFromSessionKey); v == nil || *v != source.SessionID {
+		t.Errorf("expected provenance on copied message, got:%+v", copied.Metadata)
+	}
+	if copied.UserID != dstUserID || copied.ParentMessageID != "m2" {
+		t.Errorf("unexpected copied message: %+v", copied)
+	}
+	if _, err := store.CloneSession(srcUserID, source.SessionID, dstUserID, "session_clone_dst", model.CloneOptions{}); err == nil {
+		t.Error("expected cloning into an existing session to fail")
+	}
+
+	report, err = store.CloneSession(srcUserID, source.SessionID, dstUserID, "session_clone_truncated", model.CloneOptions{
+		UntilMessageID:    "m2",
+		RewriteMessageIDs: true,
+		BatchSize:         1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Messages != 2 || len(report.MessageIDs) != 2 {
+		t.Fatalf("unexpected truncated clone report: %+v", report)
+	}
+	path, err := store.ListMessagePath("session_clone_truncated", report.MessageIDs["m2"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(path) != 2 || path[0].MessageID != report.MessageIDs["m1"] || path[1].Content != "second" {
+		t.Errorf("unexpected truncated clone path: %+v", path)
+	}
+}
@@ -0,0 +1,66 @@
+package test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+func TestCompactMessages(t *testing.T) {
+	store := MemoryStore()
+	if err := store.InitTable(); err != nil {
+		t.Fatal(err)
+	}
+	sessionID := "session_compaction_1"
+	if _, err := store.DeleteMessages(sessionID); err != nil {
+		t.Error(err)
+	}
+	for i := range 10 {
+		message := model.NewMessage(sessionID, fmt.Sprintf("message_%02d", i))
+		message.SetCreateTime(int64(10 + i)).SetContent(fmt.Sprintf("turn %d", i))
+		message.SetRole(model.RoleUser)
+		if err := store.PutMessage(message); err != nil {
+			t.Fatal(err)
+		}
+	}
+	summarizer := model.SummarizerFunc(func(messages []model.Message) (string, error) {
+		return fmt.Sprintf("%d earlier turns", len(messages)), nil
+	})
+	report, err := store.CompactMessages(sessionID, summarizer, model.CompactionOptions{EndTime: 15})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Compacted != 6 || report.Summary.CreateTime != 15 {
+		t.Fatalf("expect 6 compacted messages with summary at 15, got:%d at %d", report.Compacted, report.Summary.CreateTime)
+	}
+	var active []model.Message
+	for msg := range store.ListMessages(sessionID) {
+		active = append(active, msg)
+	}
+	if len(active) != 5 || active[0].MessageID != report.Summary.MessageID {
+		t.Errorf("expect summary and 4 active messages, got:%d", len(active))
+	}
+	originals, err := store.ListSummarizedMessages(report.Summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(originals) != 6 {
+		t.Errorf("expect 6 archived originals, got:%d", len(originals))
+	}
+	for _, msg := range originals {
+		if msg.CompactedBy() != report.Summary.MessageID {
+			t.Errorf("expect message:%s compacted by summary", msg.MessageID)
+		}
+	}
+	window, err := store.BuildContext(sessionID, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(window.Messages) != 5 || !window.Messages[0].IsSummary() {
+		t.Errorf("expect summary and 4 messages in context, got:%d", len(window.Messages))
+	}
+	if _, err := store.DeleteMessages(sessionID); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,64 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+func TestReadYourWrites(t *testing.T) {
+	store := MemoryStore()
+	if err := store.InitTable(); err != nil {
+		t.Fatal(err)
+	}
+	sessionID := "session_read_your_writes"
+	if _, err := store.DeleteMessages(sessionID); err != nil {
+		t.Error(err)
+	}
+	message := randomMessage(sessionID)
+	message.SetSearchContent("just sent unindexed message")
+	if err := store.PutMessage(message); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := store.SearchMessages(sessionID, "just sent", 0, 0, 10, nil, model.WithConsistency(model.ConsistencyMergeWrites, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Hits) != 1 || resp.Hits[0].MessageID != message.MessageID || resp.Total != 1 {
+		t.Errorf("expected merged write, got total:%d hits:%+v", resp.Total, resp.Hits)
+	}
+	resp, err = store.SearchMessages(sessionID, "just sent", 0, 0, 10, nil, model.WithConsistency(model.ConsistencyWaitIndex, time.Second*30))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Total != 1 {
+		t.Errorf("expected indexed write after wait, got:%d", resp.Total)
+	}
+	if err := store.DeleteMessage(message.SessionID, message.MessageID, message.CreateTime); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = store.SearchMessages(sessionID, "just sent", 0, 0, 10, nil, model.WithConsistency(model.ConsistencyMergeWrites, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Hits) != 0 || resp.Total != 0 {
+		t.Errorf("expected deleted message dropped, got total:%d hits:%d", resp.Total, len(resp.Hits))
+	}
+
+	// unindexed rows are placed by the requested sort and the page keeps its size
+	older := randomMessage(sessionID).SetCreateTime(1).SetSearchContent("sorted unindexed message")
+	newer := randomMessage(sessionID).SetCreateTime(2).SetSearchContent("sorted unindexed message")
+	for _, v := range []*model.Message{newer, older} {
+		if err := store.PutMessage(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resp, err = store.SearchMessages(sessionID, "sorted unindexed", 0, 0, 1, nil, model.WithConsistency(model.ConsistencyMergeWrites, 0), model.WithSort(model.SortAsc("create_time")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Hits) != 1 || resp.Hits[0].MessageID != older.MessageID {
+		t.Errorf("expected the oldest merged write only, got:%+v", resp.Hits)
+	}
+}
@@ -0,0 +1,61 @@
+package test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+func TestBuildContext(t *testing.T) {
+	store := MemoryStore()
+	if err := store.InitTable(); err != nil {
+		t.Fatal(err)
+	}
+	sessionID := "session_context_1"
+	if _, err := store.DeleteMessages(sessionID); err != nil {
+		t.Error(err)
+	}
+	counter := model.TokenizerFunc(func(*model.Message) int { return 10 })
+	system := model.NewMessage(sessionID, "message_system")
+	system.SetCreateTime(1).SetContent("you are a helpful assistant")
+	system.SetRole(model.RoleSystem)
+	if err := store.PutMessage(system); err != nil {
+		t.Fatal(err)
+	}
+	pinned := model.NewMessage(sessionID, "message_pinned")
+	pinned.SetCreateTime(2).SetContent("remember this")
+	pinned.SetRole(model.RoleUser).SetPinned(true)
+	if err := store.PutMessage(pinned); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 30 {
+		message := model.NewMessage(sessionID, fmt.Sprintf("message_%02d", i))
+		message.SetCreateTime(int64(10 + i)).SetContent("hello")
+		message.SetRole(model.RoleUser)
+		if err := store.PutMessage(message); err != nil {
+			t.Fatal(err)
+		}
+	}
+	window, err := store.BuildContext(sessionID, 100, model.WithTokenizer(counter), model.WithContextPageSize(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(window.Messages) != 10 || window.Tokens != 100 || !window.Truncated {
+		t.Fatalf("expect 10 messages with 100 tokens truncated, got:%d messages, %d tokens, truncated:%v", len(window.Messages), window.Tokens, window.Truncated)
+	}
+	if window.Messages[0].MessageID != system.MessageID || window.Messages[1].MessageID != pinned.MessageID {
+		t.Errorf("expect system and pinned messages first, got:%s, %s", window.Messages[0].MessageID, window.Messages[1].MessageID)
+	}
+	if window.Messages[2].MessageID != "message_22" || window.Messages[9].MessageID != "message_29" {
+		t.Errorf("expect latest messages, got:%s..%s", window.Messages[2].MessageID, window.Messages[9].MessageID)
+	}
+	for i := 1; i < len(window.Messages); i++ {
+		if window.Messages[i].CreateTime < window.Messages[i-1].CreateTime {
+			t.Errorf("expect chronological order at:%d", i)
+		}
+	}
+	if _, err := store.DeleteMessages(sessionID); err != nil {
+		t.Error(err)
+	}
+}
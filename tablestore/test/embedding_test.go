@@ -0,0 +1,79 @@
+package test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+func TestSemanticSearchMessages(t *testing.T) {
+	store := MemoryStore(
+		model.WithSessionTableName("session_embedding"),
+		model.WithMessageTableName("message_embedding"),
+		model.WithMemoryTableName("memory_embedding"),
+		model.WithEmbedder(model.HashEmbedder{Dim: 32}, tablestore.VectorMetricType_COSINE),
+		model.WithMessageIndexFields(model.IndexField{Name: model.MetadataRoleKey, Type: model.STRING}),
+	)
+	if err := store.InitTable(); err != nil {
+		t.Fatal(err)
+	}
+	userID := "user_embedding_1"
+	sessionID := "session_embedding_1"
+	if err := store.PutSession(model.NewSession(userID, sessionID).SetSearchContent("account help")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.DeleteMessages(sessionID); err != nil {
+		t.Error(err)
+	}
+	contents := []string{"how do I reset my password", "the invoice total looks wrong", "password reset link expired"}
+	for i, content := range contents {
+		message := model.NewMessage(sessionID, content)
+		message.SetCreateTime(int64(i + 1)).SetContent(content)
+		message.SetRole(model.RoleUser)
+		if err := store.PutMessage(message); err != nil {
+			t.Fatal(err)
+		}
+		if len(message.Embedding) != 32 {
+			t.Fatalf("expect embedding computed on put, got:%d", len(message.Embedding))
+		}
+	}
+	time.Sleep(time.Second * 11)
+	resp, err := store.SemanticSearchMessages(sessionID, "", "reset password", 2, model.MetadataEquals(model.MetadataRoleKey, string(model.RoleUser)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Hits) != 2 {
+		t.Fatalf("expect 2 hits, got:%d", len(resp.Hits))
+	}
+	for _, hit := range resp.Hits {
+		if hit.Content == contents[1] {
+			t.Errorf("expect password messages nearest, got:%s", hit.Content)
+		}
+	}
+	resp, err = store.SemanticSearchMessages("", userID, "invoice", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Hits) != 1 || resp.Hits[0].Content != contents[1] {
+		t.Errorf("expect invoice message for user, got:%+v", resp.Hits)
+	}
+	edited := model.Message{SessionID: sessionID, MessageID: contents[1]}
+	if err := store.GetMessage(&edited); err != nil {
+		t.Fatal(err)
+	}
+	stale := edited.Embedding
+	edited.SetContent("my parcel never arrived")
+	if err := store.UpdateMessage(&edited); err != nil {
+		t.Fatal(err)
+	}
+	if expected, _ := (model.HashEmbedder{Dim: 32}).Embed([]string{edited.Content}); slices.Equal(edited.Embedding, stale) || !slices.Equal(edited.Embedding, expected[0]) {
+		t.Error("expect update to embed the edited content")
+	}
+	if err := store.DeleteTableAndIndex(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,46 @@
+package test
+
+import (
+	"testing"
+)
+
+func TestDeleteUserData(t *testing.T) {
+	store := MemoryStore()
+	if err := store.InitTable(); err != nil {
+		t.Fatal(err)
+	}
+	var totalMessages int
+	for range 3 {
+		session := randomSession("user_erasure")
+		if err := store.PutSession(session); err != nil {
+			t.Error(err)
+		}
+		for range 5 {
+			if err := store.PutMessage(randomMessage(session.SessionID)); err != nil {
+				t.Error(err)
+			}
+			totalMessages++
+		}
+	}
+	report, err := store.DeleteUserData("user_erasure", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.DeletedSessions != 3 {
+		t.Errorf("expect deleted sessions:3, got:%d", report.DeletedSessions)
+	}
+	if report.DeletedMessages != totalMessages {
+		t.Errorf("expect deleted messages:%d, got:%d", totalMessages, report.DeletedMessages)
+	}
+	if !report.Completed || !report.Verified {
+		t.Errorf("expect erasure completed and verified, got:%+v", report)
+	}
+	// resuming a completed erasure is a no-op
+	report, err = store.DeleteUserData("user_erasure", report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.DeletedSessions != 3 {
+		t.Errorf("expect deleted sessions:3, got:%d", report.DeletedSessions)
+	}
+}
@@ -0,0 +1,81 @@
+package test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+func TestExportImport(t *testing.T) {
+	store := MemoryStore()
+	if err := store.InitTable(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.DeleteUserData("user_export", nil); err != nil {
+		t.Fatal(err)
+	}
+	var (
+		totalMessages int
+		last          *model.Message
+	)
+	for range 2 {
+		session := randomSession("user_export")
+		if err := store.PutSession(session); err != nil {
+			t.Error(err)
+		}
+		for range 3 {
+			last = randomMessage(session.SessionID)
+			if err := store.PutMessage(last); err != nil {
+				t.Error(err)
+			}
+			totalMessages++
+		}
+	}
+	var buf bytes.Buffer
+	exportReport, err := store.ExportUser("user_export", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exportReport.Sessions != 2 || exportReport.Messages != totalMessages {
+		t.Errorf("expect exported sessions:2, messages:%d, got:%+v", totalMessages, exportReport)
+	}
+	data := buf.Bytes()
+	importReport, err := store.Import(bytes.NewReader(data), model.ConflictSkip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if importReport.SkippedSessions != 2 || importReport.SkippedMessages != totalMessages {
+		t.Errorf("expect all rows skipped, got:%+v", importReport)
+	}
+	if err := store.DeleteMessage(last.SessionID, last.MessageID, last.CreateTime); err != nil {
+		t.Fatal(err)
+	}
+	importReport, err = store.Import(bytes.NewReader(data), model.ConflictFail)
+	if err == nil {
+		t.Error("expect import fail on conflict, but not")
+	}
+	if importReport.Messages != 1 {
+		t.Errorf("expect the missing message written in the failed batch counted, got:%+v", importReport)
+	}
+	if _, err := store.DeleteUserData("user_export", nil); err != nil {
+		t.Fatal(err)
+	}
+	importReport, err = store.Import(bytes.NewReader(data), model.ConflictOverwrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if importReport.Sessions != 2 || importReport.Messages != totalMessages {
+		t.Errorf("expect imported sessions:2, messages:%d, got:%+v", totalMessages, importReport)
+	}
+	var reexported bytes.Buffer
+	if _, err := store.ExportUser("user_export", &reexported); err != nil {
+		t.Fatal(err)
+	}
+	if reexported.Len() != len(data) {
+		t.Errorf("expect re-exported size:%d, got:%d", len(data), reexported.Len())
+	}
+	if _, err := store.DeleteUserData("user_export", nil); err != nil {
+		t.Error(err)
+	}
+}
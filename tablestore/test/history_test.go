@@ -0,0 +1,72 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+func TestMessageHistory(t *testing.T) {
+	store := MemoryStore(
+		model.WithSessionTableName("session_history"),
+		model.WithMessageTableName("message_history_messages"),
+		model.WithMemoryTableName("memory_history"),
+		model.WithMessageHistoryTableName("message_history_test"),
+		model.WithMessageHistory(),
+	)
+	if err := store.InitTable(); err != nil {
+		t.Fatal(err)
+	}
+	sessionID := "session_history_1"
+	if _, err := store.DeleteMessages(sessionID); err != nil {
+		t.Fatal(err)
+	}
+	message := randomMessage(sessionID)
+	message.SetContent("first")
+	if err := store.PutMessage(message); err != nil {
+		t.Fatal(err)
+	}
+	for _, content := range []string{"second", "third"} {
+		message.SetContent(content)
+		if err := store.UpdateMessage(message); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	versions, err := store.ListMessageVersions(sessionID, message.MessageID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 3 {
+		t.Fatalf("expected 3 versions, got:%d", len(versions))
+	}
+	for i, content := range []string{"first", "second", "third"} {
+		if versions[i].Content != content {
+			t.Errorf("expected version %d content:%s, got:%s", i, content, versions[i].Content)
+		}
+	}
+	if !versions[2].IsCurrent() || versions[0].ValidTo != versions[1].ValidFrom {
+		t.Errorf("unexpected version ranges: %+v", versions)
+	}
+
+	for i, content := range []string{"first", "second", "third"} {
+		at := versions[i].ValidFrom
+		got := model.Message{SessionID: sessionID, MessageID: message.MessageID}
+		if err := store.GetMessageAt(&got, at); err != nil {
+			t.Fatal(err)
+		}
+		if got.Content != content {
+			t.Errorf("expected content at %d:%s, got:%s", at, content, got.Content)
+		}
+	}
+	if err := store.GetMessageAt(&model.Message{SessionID: sessionID, MessageID: message.MessageID}, message.CreateTime-1); err == nil {
+		t.Error("expected no message before its create time")
+	}
+
+	if err := store.DeleteMessage(sessionID, message.MessageID, message.CreateTime); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.GetMessageAt(&model.Message{SessionID: sessionID, MessageID: message.MessageID}, versions[0].ValidFrom); err == nil {
+		t.Error("expected history deleted with the message")
+	}
+}
@@ -0,0 +1,65 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+func TestHybridSearchMessages(t *testing.T) {
+	store := MemoryStore(
+		model.WithSessionTableName("session_hybrid"),
+		model.WithMessageTableName("message_hybrid"),
+		model.WithMemoryTableName("memory_hybrid"),
+		model.WithEmbedder(model.HashEmbedder{Dim: 32}, tablestore.VectorMetricType_COSINE),
+	)
+	if err := store.InitTable(); err != nil {
+		t.Fatal(err)
+	}
+	sessionID := "session_hybrid_1"
+	if err := store.PutSession(model.NewSession("user_hybrid_1", sessionID)); err != nil {
+		t.Fatal(err)
+	}
+	contents := []string{"how do I reset my password", "the invoice total looks wrong", "password reset link expired"}
+	for i, content := range contents {
+		message := model.NewMessage(sessionID, content)
+		message.SetCreateTime(int64(i + 1)).SetContent(content)
+		if err := store.PutMessage(message); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(time.Second * 11)
+	for _, fusion := range []model.FusionMethod{model.FusionRRF, model.FusionWeighted} {
+		resp, err := store.HybridSearchMessages(model.HybridSearchRequest{
+			SessionID: sessionID,
+			Query:     "password",
+			TopK:      3,
+			Fusion:    fusion,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.Hits) == 0 {
+			t.Fatal("expect hybrid hits")
+		}
+		seen := make(map[string]struct{}, len(resp.Hits))
+		for _, hit := range resp.Hits {
+			if _, ok := seen[hit.Message.MessageID]; ok {
+				t.Errorf("duplicate hit:%s", hit.Message.MessageID)
+			}
+			seen[hit.Message.MessageID] = struct{}{}
+			if len(hit.Retrievers) == 0 || hit.Score <= 0 {
+				t.Errorf("expect scored hit with retrievers, got:%+v", hit)
+			}
+		}
+		if top := resp.Hits[0]; top.KeywordRank == 0 || top.VectorRank == 0 {
+			t.Errorf("expect top hit matched by both retrievers, got:%+v", top)
+		}
+	}
+	if err := store.DeleteTableAndIndex(); err != nil {
+		t.Error(err)
+	}
+}
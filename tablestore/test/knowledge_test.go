@@ -0,0 +1,92 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+func TestKnowledgeStore(t *testing.T) {
+	store := KnowledgeStore(
+		model.WithEmbedding(3, tablestore.VectorMetricType_COSINE),
+		model.WithMetadataFields(model.IndexField{Name: "category", Type: model.STRING}),
+	)
+	if err := store.InitTable(); err != nil {
+		t.Fatal(err)
+	}
+	tenantID := "tenant_knowledge_1"
+	if _, err := store.DeleteDocument(tenantID, "doc_1"); err != nil {
+		t.Error(err)
+	}
+	if _, err := store.DeleteDocument(tenantID, "doc_2"); err != nil {
+		t.Error(err)
+	}
+	chunks := []*model.Document{
+		model.NewDocument(tenantID, "doc_1", 0).SetContent("how to reset your password").SetEmbedding([]float32{1, 0, 0}),
+		model.NewDocument(tenantID, "doc_1", 1).SetContent("password rules and expiry").SetEmbedding([]float32{0.9, 0.1, 0}),
+		model.NewDocument(tenantID, "doc_2", 0).SetContent("billing and invoices").SetEmbedding([]float32{0, 0, 1}),
+	}
+	chunks[0].Metadata.Put("category", "account")
+	chunks[1].Metadata.Put("category", "account")
+	chunks[2].Metadata.Put("category", "billing")
+	if err := store.PutDocuments(chunks); err != nil {
+		t.Fatal(err)
+	}
+	list, err := store.ListDocumentChunks(tenantID, "doc_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].ChunkIndex != 0 || len(list[0].Embedding) != 3 {
+		t.Errorf("expect 2 ordered chunks with embeddings, got:%+v", list)
+	}
+	// re-ingesting with fewer chunks leaves no stale chunk behind
+	replaced := model.NewDocument(tenantID, "doc_2", 0).SetContent("billing and invoices, revised").SetEmbedding([]float32{0, 0, 1})
+	replaced.Metadata.Put("category", "billing")
+	if err := store.PutDocuments([]*model.Document{model.NewDocument(tenantID, "doc_2", 1).SetContent("obsolete billing chunk")}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := store.ReplaceDocument(tenantID, "doc_2", []*model.Document{replaced}); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Errorf("expect 1 stale chunk deleted, got:%d", n)
+	}
+	if list, err := store.ListDocumentChunks(tenantID, "doc_2"); err != nil {
+		t.Fatal(err)
+	} else if len(list) != 1 || list[0].Content != replaced.Content {
+		t.Errorf("expect the replaced chunk only, got:%+v", list)
+	}
+	time.Sleep(time.Second * 11)
+	resp, err := store.SearchDocuments(&model.DocumentSearchRequest{
+		TenantID: tenantID,
+		Query:    "password",
+		Filters:  []model.MetadataFilter{model.MetadataEquals("category", "account")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Hits) != 2 {
+		t.Errorf("expect 2 text hits, got:%d", len(resp.Hits))
+	}
+	resp, err = store.SearchDocuments(&model.DocumentSearchRequest{
+		TenantID:  tenantID,
+		Embedding: []float32{0, 0.1, 0.9},
+		PageSize:  1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Hits) != 1 || resp.Hits[0].DocumentID != "doc_2" {
+		t.Errorf("expect nearest chunk of doc_2, got:%+v", resp.Hits)
+	}
+	if n, err := store.DeleteDocument(tenantID, "doc_1"); err != nil {
+		t.Error(err)
+	} else if n != 2 {
+		t.Errorf("expect delete 2 chunks, got:%d", n)
+	}
+	if _, err := store.DeleteDocument(tenantID, "doc_2"); err != nil {
+		t.Error(err)
+	}
+}
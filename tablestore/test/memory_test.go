@@ -0,0 +1,60 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+func TestMemory(t *testing.T) {
+	store := MemoryStore()
+	if err := store.InitTable(); err != nil {
+		t.Fatal(err)
+	}
+	userID := "user_memory_1"
+	if _, err := store.ForgetMemories(userID); err != nil {
+		t.Error(err)
+	}
+	memory := model.NewMemory(userID, "User prefers metric units")
+	memory.SetImportance(0.5).AddSource("session_1", "message_1")
+	if err := store.PutMemory(memory); err != nil {
+		t.Fatal(err)
+	}
+	duplicate := model.NewMemory(userID, "user prefers  METRIC units")
+	duplicate.SetImportance(0.9).AddSource("session_2", "message_7")
+	if err := store.PutMemory(duplicate); err != nil {
+		t.Fatal(err)
+	}
+	expired := model.NewMemory(userID, "user is travelling this week")
+	expired.SetExpireTime(model.CurrentTimeMicroseconds() - 1)
+	if err := store.PutMemory(expired); err != nil {
+		t.Fatal(err)
+	}
+	memories, err := store.ListMemories(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(memories) != 1 {
+		t.Fatalf("expect 1 unexpired memory, got:%d", len(memories))
+	}
+	if memories[0].Importance != 0.9 || len(memories[0].Sources) != 2 {
+		t.Errorf("expect merged memory, got:%+v", memories[0])
+	}
+	time.Sleep(time.Second * 11)
+	resp, err := store.SearchMemories(userID, "metric", 10, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Hits) != 1 || resp.Hits[0].MemoryID != memory.MemoryID {
+		t.Errorf("expect search to find the memory, got:%d hits", len(resp.Hits))
+	}
+	if err := store.ForgetMemory(userID, memory.MemoryID); err != nil {
+		t.Error(err)
+	}
+	if n, err := store.ForgetMemories(userID); err != nil {
+		t.Error(err)
+	} else if n != 1 {
+		t.Errorf("expect forget 1 expired memory, got:%d", n)
+	}
+}
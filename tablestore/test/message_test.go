@@ -3,6 +3,7 @@ package test
 import (
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -207,6 +208,15 @@ func TestMessageSearch(t *testing.T) {
 		t.Error(err)
 	} else if resp.Total != total {
 		t.Errorf("expected search results:%d, got:%d", total, resp.Total)
+	} else {
+		for _, hit := range resp.SearchHits() {
+			if hit.Score <= 0 {
+				t.Errorf("expected positive score, got:%v", hit.Score)
+			}
+			if len(hit.Highlights) == 0 || !strings.Contains(hit.Highlights[0], model.DefaultHighlightPreTag+"searchable"+model.DefaultHighlightPostTag) {
+				t.Errorf("expected highlighted keyword, got:%v", hit.Highlights)
+			}
+		}
 	}
 	if resp, err := store.SearchMessages("session_search1", "searchable", 0, 0, int32(total), nil); err != nil {
 		t.Error(err)
@@ -0,0 +1,92 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bububa/tablestore-memory/model"
+	tb "github.com/bububa/tablestore-memory/tablestore"
+)
+
+func TestSearchUserMessages(t *testing.T) {
+	store := MemoryStore(
+		model.WithSessionTableName("session_user_messages"),
+		model.WithMessageTableName("message_user_messages"),
+		model.WithMemoryTableName("memory_user_messages"),
+	)
+	if err := store.InitTable(); err != nil {
+		t.Fatal(err)
+	}
+	userID := "user_messages_1"
+	for _, sessionID := range []string{"session_user_messages_1", "session_user_messages_2", "session_user_messages_3"} {
+		if err := store.PutSession(model.NewSession(userID, sessionID)); err != nil {
+			t.Fatal(err)
+		}
+		if sessionID == "session_user_messages_3" {
+			continue
+		}
+		message := randomMessage(sessionID)
+		message.SetSearchContent("shared topic across sessions")
+		if err := store.PutMessage(message); err != nil {
+			t.Fatal(err)
+		}
+		if message.UserID != userID {
+			t.Errorf("expected user id stamped from session, got:%q", message.UserID)
+		}
+	}
+	legacy := MemoryStore(
+		model.WithSessionTableName("session_user_messages"),
+		model.WithMessageTableName("message_user_messages"),
+		model.WithMemoryTableName("memory_user_messages"),
+	)
+	// a store that never saw the session reads the user from its latest messages
+	resolved := randomMessage("session_user_messages_2")
+	resolved.SetSearchContent("shared topic resolved from messages")
+	if err := legacy.PutMessage(resolved); err != nil {
+		t.Fatal(err)
+	}
+	if resolved.UserID != userID {
+		t.Errorf("expected user id resolved from session messages, got:%q", resolved.UserID)
+	}
+	// a message of a session without stamped messages has no user id until backfilled
+	orphan := randomMessage("session_user_messages_3")
+	orphan.SetSearchContent("shared topic written before backfill")
+	if err := legacy.PutMessage(orphan); err != nil {
+		t.Fatal(err)
+	}
+	if orphan.UserID != "" {
+		t.Fatalf("expected no user id for unknown session, got:%q", orphan.UserID)
+	}
+	updated, err := legacy.BackfillMessageUserIDs(userID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated != 1 {
+		t.Errorf("expected 1 message backfilled, got:%d", updated)
+	}
+	if updated, err := legacy.BackfillMessageUserIDs(userID, 0); err != nil || updated != 0 {
+		t.Errorf("expected backfill rerun to be a no-op, got:%d %v", updated, err)
+	}
+	time.Sleep(time.Second * 11)
+	resp, err := store.SearchUserMessages(userID, "shared topic", 0, 0, 10, nil, model.WithQueryMode(model.QueryModeMatch))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Total != 4 {
+		t.Errorf("expected 4 messages across sessions, got:%d", resp.Total)
+	}
+	for _, hit := range resp.Hits {
+		if hit.UserID != userID {
+			t.Errorf("expected hit of user %s, got:%s", userID, hit.UserID)
+		}
+		if _, ok := hit.Metadata[tb.MessageUserIDField]; ok {
+			t.Error("expected user id not parsed into metadata")
+		}
+	}
+	if _, err := store.SearchUserMessages("", "shared", 0, 0, 10, nil); err == nil {
+		t.Error("expected user id required")
+	}
+	if err := store.DeleteTableAndIndex(); err != nil {
+		t.Error(err)
+	}
+}
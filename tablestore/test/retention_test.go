@@ -0,0 +1,58 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+func TestMessageRetention(t *testing.T) {
+	store := MemoryStore()
+	if err := store.InitTable(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.DeleteAllMessages(); err != nil {
+		t.Error(err)
+	}
+	var (
+		total    = 40
+		oldCount int
+	)
+	for range total {
+		message := randomMessage(randomFrom([]string{"session_retention_1", "session_retention_2"}))
+		if message.CreateTime < 50 && message.SessionID == "session_retention_1" {
+			oldCount++
+		}
+		if err := store.PutMessage(message); err != nil {
+			t.Error(err)
+		}
+	}
+	if n, err := store.DeleteMessagesBefore("session_retention_1", 50); err != nil {
+		t.Error(err)
+	} else if n != oldCount {
+		t.Errorf("expect delete messages:%d, got:%d", oldCount, n)
+	}
+	time.Sleep(time.Second * 11)
+	report, err := store.SweepMessages(model.RetentionOptions{Before: 100, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Deleted != total-oldCount {
+		t.Errorf("expect dry run matched messages:%d, got:%d", total-oldCount, report.Deleted)
+	}
+	report, err = store.SweepMessages(model.RetentionOptions{Before: 100, RowsPerSecond: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Deleted != total-oldCount {
+		t.Errorf("expect deleted messages:%d, got:%d", total-oldCount, report.Deleted)
+	}
+	var count int
+	for range store.ListAllMessages() {
+		count++
+	}
+	if count != 0 {
+		t.Errorf("expect exists messages:0, got:%d", count)
+	}
+}
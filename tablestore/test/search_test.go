@@ -0,0 +1,92 @@
+package test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+func TestMessageSearchModes(t *testing.T) {
+	store := MemoryStore(
+		model.WithSessionTableName("session_search_modes"),
+		model.WithMessageTableName("message_search_modes"),
+		model.WithMemoryTableName("memory_search_modes"),
+		model.WithMessageAnalyzer(model.AnalyzerOptions{Analyzer: tablestore.Analyzer_MaxWord}),
+	)
+	if err := store.InitTable(); err != nil {
+		t.Fatal(err)
+	}
+	sessionID := "session_search_modes_1"
+	contents := []string{"reset my password please", "password reset link expired", "the invoice total looks wrong"}
+	for i, content := range contents {
+		message := model.NewMessage(sessionID, content)
+		message.SetCreateTime(int64(i + 1)).SetSearchContent(content)
+		if err := store.PutMessage(message); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(time.Second * 11)
+	cases := []struct {
+		keyword string
+		opts    []model.SearchOption
+		expect  int64
+	}{
+		{keyword: "reset password", expect: 0},
+		{keyword: "reset password", opts: []model.SearchOption{model.WithQueryMode(model.QueryModeMatch)}, expect: 2},
+		{keyword: "reset invoice", opts: []model.SearchOption{model.WithQueryMode(model.QueryModeMatch)}, expect: 3},
+		{keyword: "reset invoice", opts: []model.SearchOption{model.WithQueryMode(model.QueryModeMatch), model.WithMatchOperator(model.MatchOperatorAnd)}, expect: 0},
+		{keyword: "pass", opts: []model.SearchOption{model.WithQueryMode(model.QueryModePrefix)}, expect: 2},
+		{keyword: "inv*ce", opts: []model.SearchOption{model.WithQueryMode(model.QueryModeWildcard)}, expect: 1},
+		{keyword: "+password -expired", opts: []model.SearchOption{model.WithQueryMode(model.QueryModeString)}, expect: 1},
+		{keyword: `"reset link" invoice`, opts: []model.SearchOption{model.WithQueryMode(model.QueryModeString)}, expect: 2},
+	}
+	for _, c := range cases {
+		resp, err := store.SearchMessages(sessionID, c.keyword, 0, 0, 10, nil, c.opts...)
+		if err != nil {
+			t.Errorf("search %q failed, %v", c.keyword, err)
+			continue
+		}
+		if resp.Total != c.expect {
+			t.Errorf("search %q expected results:%d, got:%d", c.keyword, c.expect, resp.Total)
+		}
+	}
+	for _, desc := range []bool{false, true} {
+		var (
+			createTimes []int64
+			nextToken   []byte
+		)
+		for {
+			resp, err := store.SearchMessages(sessionID, "password reset invoice", 0, 0, 1, nextToken,
+				model.WithQueryMode(model.QueryModeMatch),
+				model.WithSort(model.SortField{Field: "create_time", Desc: desc}),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, hit := range resp.Hits {
+				createTimes = append(createTimes, hit.CreateTime)
+			}
+			if resp.NextToken == nil {
+				break
+			}
+			nextToken = resp.NextToken
+		}
+		expect := []int64{1, 2, 3}
+		if desc {
+			expect = []int64{3, 2, 1}
+		}
+		if !reflect.DeepEqual(createTimes, expect) {
+			t.Errorf("expected create times:%v, got:%v", expect, createTimes)
+		}
+	}
+	if _, err := store.SearchMessages(sessionID, "password", 0, 0, 10, nil, model.WithSort(model.SortAsc("unindexed"))); err == nil {
+		t.Error("expected unsortable field error")
+	}
+	if err := store.DeleteTableAndIndex(); err != nil {
+		t.Error(err)
+	}
+}
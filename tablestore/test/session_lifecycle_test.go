@@ -0,0 +1,137 @@
+package test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/bububa/tablestore-memory/model"
+	tb "github.com/bububa/tablestore-memory/tablestore"
+)
+
+func TestSessionLifecycle(t *testing.T) {
+	store := MemoryStore(
+		model.WithSessionTableName("session_lifecycle"),
+		model.WithMessageTableName("message_lifecycle"),
+		model.WithMemoryTableName("memory_lifecycle"),
+	)
+	if err := store.InitTable(); err != nil {
+		t.Fatal(err)
+	}
+	userID := "user_lifecycle_1"
+	if _, err := store.DeleteSessions(userID); err != nil {
+		t.Fatal(err)
+	}
+	sessions := []*model.Session{
+		model.NewSessionWithTime(userID, "session_lifecycle_1", 10).SetTitle("oldest pinned"),
+		model.NewSessionWithTime(userID, "session_lifecycle_2", 20).SetTitle("archived").SetStatus(model.SessionStatusArchived),
+		model.NewSessionWithTime(userID, "session_lifecycle_3", 30).SetTitle("newest"),
+		// written before lifecycle columns existed
+		{UserID: userID, SessionID: "session_lifecycle_4", UpdateTime: 40, Metadata: model.NewMetadata()},
+	}
+	for _, session := range sessions {
+		if err := store.PutSession(session); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.PinSession(userID, "session_lifecycle_1", true); err != nil {
+		t.Fatal(err)
+	}
+	got := model.Session{UserID: userID, SessionID: "session_lifecycle_1"}
+	if err := store.GetSession(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "oldest pinned" || got.CreateTime != 10 || !got.IsActive() || !got.Pinned {
+		t.Errorf("unexpected lifecycle fields: %+v", got)
+	}
+	if got.Metadata.HasKey(tb.SessionTitleField) {
+		t.Error("expect title not in metadata")
+	}
+
+	list, err := store.ListRecentSessions(userID, nil, 0, 0, -1, -1, model.WithSessionStatus(model.SessionStatusActive), model.WithPinnedFirst())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"session_lifecycle_1", "session_lifecycle_4", "session_lifecycle_3"}
+	if ids := sessionIDs(list); !slices.Equal(ids, expected) {
+		t.Errorf("expected active sessions pinned first %v, got:%v", expected, ids)
+	}
+	list, err = store.ListRecentSessions(userID, nil, 0, 0, -1, -1, model.WithSessionStatus(model.SessionStatusArchived))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := sessionIDs(list); !slices.Equal(ids, []string{"session_lifecycle_2"}) {
+		t.Errorf("expected archived session only, got:%v", ids)
+	}
+
+	page, err := store.ListRecentSessionsPaginated(userID, nil, 0, 0, 1, nil, model.WithPinnedFirst())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := sessionIDs(page.Hits); !slices.Equal(ids, []string{"session_lifecycle_1", "session_lifecycle_4"}) {
+		t.Errorf("expected pinned session prepended to first page, got:%v", ids)
+	}
+	var rest []string
+	for next := page.NextStartPrimaryKey; next != nil; {
+		page, err = store.ListRecentSessionsPaginated(userID, nil, 0, 0, 1, next, model.WithPinnedFirst())
+		if err != nil {
+			t.Fatal(err)
+		}
+		rest = append(rest, sessionIDs(page.Hits)...)
+		next = page.NextStartPrimaryKey
+	}
+	if !slices.Equal(rest, []string{"session_lifecycle_3", "session_lifecycle_2"}) {
+		t.Errorf("expected unpinned sessions on later pages, got:%v", rest)
+	}
+
+	var archived int
+	for range store.ListSessions(userID, tb.SessionStatusFilter(model.SessionStatusArchived), -1, -1) {
+		archived++
+	}
+	if archived != 1 {
+		t.Errorf("expected 1 archived session, got:%d", archived)
+	}
+
+	update := model.Session{UserID: userID, SessionID: "session_lifecycle_1", UpdateTime: 50, Metadata: model.NewMetadata()}
+	if err := store.UpdateSession(&update); err != nil {
+		t.Fatal(err)
+	}
+	got = model.Session{UserID: userID, SessionID: "session_lifecycle_1"}
+	if err := store.GetSession(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.CreateTime != 10 || !got.IsActive() || !got.Pinned || got.Title != "" {
+		t.Errorf("expected update to keep create time, status and pin and clear title, got:%+v", got)
+	}
+	// a session built by the constructor leaves the lifecycle columns unset
+	if err := store.UpdateSession(model.NewSessionWithTime(userID, "session_lifecycle_2", 60).SetTitle("renamed")); err != nil {
+		t.Fatal(err)
+	}
+	got = model.Session{UserID: userID, SessionID: "session_lifecycle_2"}
+	if err := store.GetSession(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.CreateTime != 20 || got.Status != model.SessionStatusArchived || got.Title != "renamed" {
+		t.Errorf("expected update to keep create time and archived status, got:%+v", got)
+	}
+	if err := store.PinSession(userID, "session_lifecycle_1", false); err != nil {
+		t.Fatal(err)
+	}
+	got = model.Session{UserID: userID, SessionID: "session_lifecycle_1"}
+	if err := store.GetSession(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Pinned {
+		t.Error("expected session unpinned")
+	}
+	if err := store.PutSession(model.NewSession(userID, "session_lifecycle_5").SetStatus("closed")); err == nil {
+		t.Error("expected invalid status error")
+	}
+}
+
+func sessionIDs(sessions []model.Session) []string {
+	ret := make([]string, 0, len(sessions))
+	for _, v := range sessions {
+		ret = append(ret, v.SessionID)
+	}
+	return ret
+}
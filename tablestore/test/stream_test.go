@@ -0,0 +1,93 @@
+package test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bububa/tablestore-memory/model"
+	tb "github.com/bububa/tablestore-memory/tablestore"
+)
+
+func TestStreamingMessage(t *testing.T) {
+	opts := []model.Option{
+		model.WithSessionTableName("session_stream"),
+		model.WithMessageTableName("message_stream"),
+		model.WithMemoryTableName("memory_stream"),
+		model.WithStreamFlush(time.Hour, 8),
+	}
+	store := MemoryStore(opts...)
+	if err := store.InitTable(); err != nil {
+		t.Fatal(err)
+	}
+	sessionID := "session_stream_1"
+	if _, err := store.DeleteMessages(sessionID); err != nil {
+		t.Fatal(err)
+	}
+	message := randomMessage(sessionID).SetContent("")
+	if err := store.BeginMessage(message); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.BeginMessage(message); err == nil {
+		t.Error("expected beginning a streaming message twice to fail")
+	}
+	observe := func() model.Message {
+		got := model.Message{SessionID: sessionID, MessageID: message.MessageID, CreateTime: message.CreateTime}
+		if err := store.GetMessage(&got); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	if err := store.AppendChunk(sessionID, message.MessageID, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if got := observe(); !got.IsStreaming() || got.Content != "" {
+		t.Errorf("expected nothing flushed below the flush size, got:%+v", got)
+	}
+	if err := store.AppendChunk(sessionID, message.MessageID, " world"); err != nil {
+		t.Fatal(err)
+	}
+	if got := observe(); !got.IsStreaming() || got.Content != "hello world" {
+		t.Errorf("expected partial content flushed, got:%+v", got)
+	}
+	if err := store.AppendChunk(sessionID, message.MessageID, "!"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.FinishMessage(sessionID, message.MessageID); err != nil {
+		t.Fatal(err)
+	}
+	if got := observe(); got.StreamStatus != model.StreamStatusComplete || got.Content != "hello world!" || got.SearchContent != "hello world!" {
+		t.Errorf("expected complete content, got:%+v", got)
+	}
+	if err := store.AppendChunk(sessionID, message.MessageID, "late"); err == nil {
+		t.Error("expected appending to a finished message to fail")
+	}
+
+	// a stream abandoned by a crashed writer is recovered by another store
+	abandoned := randomMessage(sessionID).SetContent("partial")
+	if err := store.BeginMessage(abandoned); err != nil {
+		t.Fatal(err)
+	}
+	recovery := MemoryStore(opts...)
+	time.Sleep(time.Second)
+	n, err := recovery.AbortStaleStreams(sessionID, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 aborted stream, got:%d", n)
+	}
+	got := model.Message{SessionID: sessionID, MessageID: abandoned.MessageID, CreateTime: abandoned.CreateTime}
+	if err := recovery.GetMessage(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.StreamStatus != model.StreamStatusAborted || got.Content != "partial" {
+		t.Errorf("expected aborted partial message, got:%+v", got)
+	}
+	if err := store.AppendChunk(sessionID, abandoned.MessageID, " more content"); !errors.Is(err, tb.ErrStreamAborted) {
+		t.Errorf("expected appending to an aborted stream to fail with ErrStreamAborted, got:%v", err)
+	}
+	if err := store.FinishMessage(sessionID, abandoned.MessageID); err == nil || errors.Is(err, tb.ErrStreamAborted) {
+		t.Errorf("expected the aborted stream to be ended, got:%v", err)
+	}
+}
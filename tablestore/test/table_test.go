@@ -0,0 +1,35 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+func TestTableAndIndex(t *testing.T) {
+	store := MemoryStore()
+	if err := store.InitTable(); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.InitSearchIndex(); err != nil {
+		t.Error(err)
+	}
+	if err := store.DeleteTableAndIndex(); err != nil {
+		t.Fatal(err)
+	}
+	// deleting again should be a no-op once tables are gone
+	if err := store.DeleteTableAndIndex(); err != nil {
+		t.Error(err)
+	}
+	var lastProgress model.ReadyProgress
+	store = MemoryStore(model.WithWaitReady(time.Minute*3, func(progress model.ReadyProgress) {
+		lastProgress = progress
+	}))
+	if err := store.InitTable(); err != nil {
+		t.Fatal(err)
+	}
+	if !lastProgress.Ready {
+		t.Errorf("expect last progress ready, got:%+v", lastProgress)
+	}
+}
@@ -0,0 +1,146 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+func TestSoftDelete(t *testing.T) {
+	store := MemoryStore(
+		model.WithSessionTableName("session_trash"),
+		model.WithMessageTableName("message_trash"),
+		model.WithMemoryTableName("memory_trash"),
+		model.WithSoftDelete(time.Hour),
+	)
+	if err := store.InitTable(); err != nil {
+		t.Fatal(err)
+	}
+	userID := "user_trash_1"
+	if _, err := store.DeleteSessions(userID); err != nil {
+		t.Fatal(err)
+	}
+	session := model.NewSession(userID, "session_trash_1")
+	if err := store.PutSession(session); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.DeleteMessages(session.SessionID); err != nil {
+		t.Fatal(err)
+	}
+	messages := []*model.Message{randomMessage(session.SessionID), randomMessage(session.SessionID), randomMessage(session.SessionID)}
+	for _, message := range messages {
+		message.SetSearchContent("recoverable conversation")
+		if err := store.PutMessage(message); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a single message goes to the trash and comes back
+	if err := store.DeleteMessage(session.SessionID, messages[0].MessageID, messages[0].CreateTime); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.GetMessage(&model.Message{SessionID: session.SessionID, MessageID: messages[0].MessageID, CreateTime: messages[0].CreateTime}); err == nil {
+		t.Error("expected trashed message hidden from get")
+	}
+	if n := countMessages(store.ListMessages(session.SessionID)); n != 2 {
+		t.Errorf("expected 2 listed messages, got:%d", n)
+	}
+	trash, err := store.ListTrash(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trash.Sessions) != 0 || len(trash.Messages) != 1 || trash.Messages[0].MessageID != messages[0].MessageID {
+		t.Errorf("unexpected trash: %+v", trash)
+	}
+	if err := store.RestoreMessage(session.SessionID, messages[0].MessageID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if n := countMessages(store.ListMessages(session.SessionID)); n != 3 {
+		t.Errorf("expected 3 listed messages after restore, got:%d", n)
+	}
+
+	// a session goes to the trash with its messages
+	if err := store.DeleteSessionAndMessages(userID, session.SessionID); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.GetSession(&model.Session{UserID: userID, SessionID: session.SessionID}); err == nil {
+		t.Error("expected trashed session hidden from get")
+	}
+	if n := countSessions(store.ListSessions(userID, nil, -1, -1)); n != 0 {
+		t.Errorf("expected no listed sessions, got:%d", n)
+	}
+	if n := countMessages(store.ListMessages(session.SessionID)); n != 0 {
+		t.Errorf("expected no listed messages, got:%d", n)
+	}
+	time.Sleep(time.Second * 11)
+	resp, err := store.SearchMessages(session.SessionID, "recoverable", 0, 0, 10, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Hits) != 0 {
+		t.Errorf("expected trashed messages hidden from search, got:%d", len(resp.Hits))
+	}
+	trash, err = store.ListTrash(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trash.Sessions) != 1 || trash.Sessions[0].Status != model.SessionStatusDeleted || len(trash.Messages) != 3 {
+		t.Errorf("unexpected trash: %d sessions, %d messages", len(trash.Sessions), len(trash.Messages))
+	}
+	report, err := store.PurgeTrash(model.PurgeOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Sessions != 0 || report.Messages != 0 {
+		t.Errorf("expected nothing purgeable within retention, got:%+v", report)
+	}
+	if err := store.RestoreSession(userID, session.SessionID); err != nil {
+		t.Fatal(err)
+	}
+	restored := model.Session{UserID: userID, SessionID: session.SessionID}
+	if err := store.GetSession(&restored); err != nil {
+		t.Fatal(err)
+	}
+	if restored.Status != model.SessionStatusActive {
+		t.Errorf("expected restored session active, got:%s", restored.Status)
+	}
+	if n := countMessages(store.ListMessages(session.SessionID)); n != 3 {
+		t.Errorf("expected messages restored with session, got:%d", n)
+	}
+	if err := store.RestoreSession(userID, session.SessionID); err == nil {
+		t.Error("expected error restoring a session not in trash")
+	}
+
+	// purge hard deletes what was trashed before the cutoff
+	if err := store.DeleteSessionAndMessages(userID, session.SessionID); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second * 11)
+	report, err = store.PurgeTrash(model.PurgeOptions{Before: model.CurrentTimeMicroseconds()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Sessions != 1 || report.Messages != 3 {
+		t.Errorf("expected 1 session and 3 messages purged, got:%+v", report)
+	}
+	if err := store.RestoreSession(userID, session.SessionID); err == nil {
+		t.Error("expected error restoring a purged session")
+	}
+}
+
+func countMessages(ch <-chan model.Message) int {
+	var n int
+	for range ch {
+		n++
+	}
+	return n
+}
+
+func countSessions(ch <-chan model.Session) int {
+	var n int
+	for range ch {
+		n++
+	}
+	return n
+}
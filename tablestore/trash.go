@@ -0,0 +1,327 @@
+package tablestore
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore/search"
+	"github.com/spf13/cast"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+// notDeletedFilter column filter skipping rows moved to the trash
+func notDeletedFilter(field string) tablestore.ColumnFilter {
+	return tablestore.NewSingleColumnCondition(field, tablestore.CT_EQUAL, int64(0))
+}
+
+// deletedFilter column filter keeping rows moved to the trash
+func deletedFilter(field string) tablestore.ColumnFilter {
+	condition := tablestore.NewSingleColumnCondition(field, tablestore.CT_GREATER_THAN, int64(0))
+	condition.FilterIfMissing = true
+	return condition
+}
+
+// deletedAtFilter column filter keeping rows moved to the trash at deletedAt
+func deletedAtFilter(field string, deletedAt int64) tablestore.ColumnFilter {
+	condition := tablestore.NewSingleColumnCondition(field, tablestore.CT_EQUAL, deletedAt)
+	condition.FilterIfMissing = true
+	return condition
+}
+
+// excludeDeleted restricts a search query to rows not in the trash. The deleted_at field only exists in
+// search indexes created by this version, so it is applied when soft delete is enabled.
+func (s *MemoryStore) excludeDeleted(query search.Query, field string) search.Query {
+	if !s.SoftDelete {
+		return query
+	}
+	return &search.BoolQuery{
+		MustQueries:    []search.Query{query},
+		MustNotQueries: []search.Query{&search.ExistsQuery{FieldName: field}},
+	}
+}
+
+// trashSession move a session to the trash, failing when it does not exist or is already trashed
+func (s *MemoryStore) trashSession(userID string, sessionID string, deletedAt int64) error {
+	pk := new(tablestore.PrimaryKey)
+	pk.AddPrimaryKeyColumn(SessionUserIDField, userID)
+	pk.AddPrimaryKeyColumn(SessionSessionIDField, sessionID)
+	updateReq := new(tablestore.UpdateRowRequest)
+	updateReq.UpdateRowChange = new(tablestore.UpdateRowChange)
+	updateReq.UpdateRowChange.TableName = s.SessionTableName
+	updateReq.UpdateRowChange.PrimaryKey = pk
+	updateReq.UpdateRowChange.PutColumn(SessionDeletedAtField, deletedAt)
+	updateReq.UpdateRowChange.PutColumn(SessionStatusField, string(model.SessionStatusDeleted))
+	updateReq.UpdateRowChange.SetCondition(tablestore.RowExistenceExpectation_EXPECT_EXIST)
+	updateReq.UpdateRowChange.SetColumnCondition(notDeletedFilter(SessionDeletedAtField))
+	if _, err := s.clt.UpdateRow(updateReq); err != nil {
+		return fmt.Errorf("move session to trash failed, %w", err)
+	}
+	s.writes.record(s.SessionTableName, sessionWriteKey(userID, sessionID), nil)
+	return nil
+}
+
+// trashMessage move a message to the trash, failing when it does not exist or is already trashed
+func (s *MemoryStore) trashMessage(sessionID string, messageID string, createTime int64, deletedAt int64) error {
+	updateReq := new(tablestore.UpdateRowRequest)
+	updateReq.UpdateRowChange = new(tablestore.UpdateRowChange)
+	updateReq.UpdateRowChange.TableName = s.MessageTableName
+	updateReq.UpdateRowChange.PrimaryKey = messagePrimaryKey(sessionID, createTime, messageID)
+	updateReq.UpdateRowChange.PutColumn(MessageDeletedAtField, deletedAt)
+	updateReq.UpdateRowChange.SetCondition(tablestore.RowExistenceExpectation_EXPECT_EXIST)
+	updateReq.UpdateRowChange.SetColumnCondition(notDeletedFilter(MessageDeletedAtField))
+	if _, err := s.clt.UpdateRow(updateReq); err != nil {
+		return fmt.Errorf("move message to trash failed, %w", err)
+	}
+	s.writes.record(s.MessageTableName, messageWriteKey(sessionID, messageID), nil)
+	return nil
+}
+
+// trashMessages move the messages of a session not in the trash yet to the trash at deletedAt
+func (s *MemoryStore) trashMessages(sessionID string, deletedAt int64) (int, error) {
+	writer := s.newBatchWriter(0, 0)
+	// skip messages deleted since they were listed
+	writer.skipConditionFailed = true
+	var addErr error
+	for message := range s.listStoredMessages(sessionID, nil, 0, 0, tablestore.FORWARD, -1, 5000) {
+		if addErr != nil {
+			// drain the channel so the producer is not blocked
+			continue
+		}
+		rowChange := new(tablestore.UpdateRowChange)
+		rowChange.TableName = s.MessageTableName
+		rowChange.PrimaryKey = messagePrimaryKey(message.SessionID, message.CreateTime, message.MessageID)
+		rowChange.PutColumn(MessageDeletedAtField, deletedAt)
+		rowChange.SetCondition(tablestore.RowExistenceExpectation_EXPECT_EXIST)
+		key := messageWriteKey(message.SessionID, message.MessageID)
+		addErr = writer.Add(rowChange, func(written bool) {
+			if written {
+				s.writes.record(s.MessageTableName, key, nil)
+			}
+		})
+	}
+	if addErr != nil {
+		return writer.Written(), fmt.Errorf("move session messages to trash failed, %w", addErr)
+	}
+	if err := writer.Flush(); err != nil {
+		return writer.Written(), fmt.Errorf("move session messages to trash failed, %w", err)
+	}
+	return writer.Written(), nil
+}
+
+// RestoreSession move a session out of the trash as active, together with the messages moved to the
+// trash with it by DeleteSessionAndMessages
+func (s *MemoryStore) RestoreSession(userID string, sessionID string) error {
+	session := model.Session{
+		UserID:    userID,
+		SessionID: sessionID,
+	}
+	if err := s.getSession(&session); err != nil {
+		return fmt.Errorf("restore session failed, %w", err)
+	}
+	if !session.IsDeleted() {
+		return errors.New("restore session failed, session is not in trash")
+	}
+	pk := new(tablestore.PrimaryKey)
+	pk.AddPrimaryKeyColumn(SessionUserIDField, userID)
+	pk.AddPrimaryKeyColumn(SessionSessionIDField, sessionID)
+	updateReq := new(tablestore.UpdateRowRequest)
+	updateReq.UpdateRowChange = new(tablestore.UpdateRowChange)
+	updateReq.UpdateRowChange.TableName = s.SessionTableName
+	updateReq.UpdateRowChange.PrimaryKey = pk
+	updateReq.UpdateRowChange.DeleteColumn(SessionDeletedAtField)
+	updateReq.UpdateRowChange.PutColumn(SessionStatusField, string(model.SessionStatusActive))
+	updateReq.UpdateRowChange.SetCondition(tablestore.RowExistenceExpectation_EXPECT_EXIST)
+	// fails when the session was purged or restored concurrently
+	updateReq.UpdateRowChange.SetColumnCondition(deletedAtFilter(SessionDeletedAtField, session.DeletedAt))
+	if _, err := s.clt.UpdateRow(updateReq); err != nil {
+		return fmt.Errorf("restore session failed, %w", err)
+	}
+	if _, err := s.restoreMessages(sessionID, session.DeletedAt); err != nil {
+		return fmt.Errorf("restore session failed, %w", err)
+	}
+	session.DeletedAt = 0
+	session.Status = model.SessionStatusActive
+	s.writes.record(s.SessionTableName, sessionWriteKey(userID, sessionID), session.Clone())
+	s.rememberSessionOwner(userID, sessionID)
+	return nil
+}
+
+// restoreMessages move the messages of a session moved to the trash at deletedAt out of the trash
+func (s *MemoryStore) restoreMessages(sessionID string, deletedAt int64) (int, error) {
+	writer := s.newBatchWriter(0, 0)
+	// skip messages purged since they were listed
+	writer.skipConditionFailed = true
+	var addErr error
+	for message := range s.listMessagesWithFilter(sessionID, deletedAtFilter(MessageDeletedAtField, deletedAt), 0, 0, tablestore.FORWARD, -1, 5000) {
+		if addErr != nil {
+			// drain the channel so the producer is not blocked
+			continue
+		}
+		rowChange := new(tablestore.UpdateRowChange)
+		rowChange.TableName = s.MessageTableName
+		rowChange.PrimaryKey = messagePrimaryKey(message.SessionID, message.CreateTime, message.MessageID)
+		rowChange.DeleteColumn(MessageDeletedAtField)
+		rowChange.SetCondition(tablestore.RowExistenceExpectation_EXPECT_EXIST)
+		message.DeletedAt = 0
+		restored := message.Clone()
+		addErr = writer.Add(rowChange, func(written bool) {
+			if written {
+				s.writes.record(s.MessageTableName, messageWriteKey(restored.SessionID, restored.MessageID), restored)
+			}
+		})
+	}
+	if addErr != nil {
+		return writer.Written(), fmt.Errorf("restore session messages failed, %w", addErr)
+	}
+	if err := writer.Flush(); err != nil {
+		return writer.Written(), fmt.Errorf("restore session messages failed, %w", err)
+	}
+	return writer.Written(), nil
+}
+
+// RestoreMessage move a message out of the trash, createTime 0 looks it up from the secondary index
+func (s *MemoryStore) RestoreMessage(sessionID string, messageID string, createTime int64) error {
+	message := model.Message{
+		SessionID:  sessionID,
+		MessageID:  messageID,
+		CreateTime: createTime,
+	}
+	if err := s.getMessage(&message); err != nil {
+		return fmt.Errorf("restore message failed, %w", err)
+	}
+	if !message.IsDeleted() {
+		return errors.New("restore message failed, message is not in trash")
+	}
+	updateReq := new(tablestore.UpdateRowRequest)
+	updateReq.UpdateRowChange = new(tablestore.UpdateRowChange)
+	updateReq.UpdateRowChange.TableName = s.MessageTableName
+	updateReq.UpdateRowChange.PrimaryKey = messagePrimaryKey(message.SessionID, message.CreateTime, message.MessageID)
+	updateReq.UpdateRowChange.DeleteColumn(MessageDeletedAtField)
+	updateReq.UpdateRowChange.SetCondition(tablestore.RowExistenceExpectation_EXPECT_EXIST)
+	// fails when the message was purged or restored concurrently
+	updateReq.UpdateRowChange.SetColumnCondition(deletedAtFilter(MessageDeletedAtField, message.DeletedAt))
+	if _, err := s.clt.UpdateRow(updateReq); err != nil {
+		return fmt.Errorf("restore message failed, %w", err)
+	}
+	message.DeletedAt = 0
+	s.writes.record(s.MessageTableName, messageWriteKey(sessionID, messageID), message.Clone())
+	return nil
+}
+
+// ListTrash list the sessions of a user in the trash and the trashed messages of all the user's sessions
+func (s *MemoryStore) ListTrash(userID string) (*model.Trash, error) {
+	if userID == "" {
+		return nil, errors.New("user id is required")
+	}
+	ret := new(model.Trash)
+	var sessionIDs []string
+	for v := range s.listSessions(userID, nil, -1, 5000) {
+		sessionIDs = append(sessionIDs, v.SessionID)
+		if v.IsDeleted() {
+			ret.Sessions = append(ret.Sessions, v)
+		}
+	}
+	for _, sessionID := range sessionIDs {
+		for v := range s.listMessagesWithFilter(sessionID, deletedFilter(MessageDeletedAtField), 0, 0, tablestore.FORWARD, -1, 5000) {
+			ret.Messages = append(ret.Messages, v)
+		}
+	}
+	return ret, nil
+}
+
+// PurgeTrash hard delete sessions and messages moved to the trash before the purge cutoff, messages
+// first. It scans the search indexes, so rows trashed just before the cutoff may be left to the next run.
+func (s *MemoryStore) PurgeTrash(opts model.PurgeOptions) (*model.PurgeReport, error) {
+	before := opts.Cutoff(s.TrashRetention)
+	report := &model.PurgeReport{
+		Before:    before,
+		DryRun:    opts.DryRun,
+		StartTime: model.CurrentTimeMicroseconds(),
+	}
+	writer := s.newBatchWriter(opts.BatchSize, opts.RowsPerSecond)
+	// skip rows restored since the scan
+	writer.skipConditionFailed = true
+	purgeable := func(field string) tablestore.ColumnFilter {
+		condition := tablestore.NewSingleColumnCondition(field, tablestore.CT_LESS_THAN, before)
+		condition.FilterIfMissing = true
+		return condition
+	}
+	deleteHistory := s.deleteHistoryAfterFlush(writer)
+	err := s.parallelScan(s.MessageTableName, s.MessageSearchIndexName, &search.RangeQuery{
+		FieldName: MessageDeletedAtField,
+		From:      tablestore.MIN,
+		To:        before,
+	}, nil, func(row *tablestore.Row) error {
+		if opts.DryRun {
+			report.Messages++
+			return nil
+		}
+		var sessionID, messageID string
+		for _, col := range row.PrimaryKey.PrimaryKeys {
+			switch col.ColumnName {
+			case MessageSessionIDField:
+				sessionID = cast.ToString(col.Value)
+			case MessageMessageIDField:
+				messageID = cast.ToString(col.Value)
+			}
+		}
+		rowChange := new(tablestore.DeleteRowChange)
+		rowChange.TableName = s.MessageTableName
+		rowChange.PrimaryKey = row.PrimaryKey
+		rowChange.SetCondition(tablestore.RowExistenceExpectation_IGNORE)
+		rowChange.SetColumnCondition(purgeable(MessageDeletedAtField))
+		return writer.Add(rowChange, func(written bool) {
+			if written {
+				report.Messages++
+				deleteHistory(sessionID, messageID)
+			}
+		})
+	})
+	if err == nil {
+		err = writer.Flush()
+	}
+	// sessions carry no history
+	writer.flushed = nil
+	if err == nil {
+		err = s.parallelScan(s.SessionTableName, s.SessionSearchIndexName, &search.RangeQuery{
+			FieldName: SessionDeletedAtField,
+			From:      tablestore.MIN,
+			To:        before,
+		}, nil, func(row *tablestore.Row) error {
+			if opts.DryRun {
+				report.Sessions++
+				return nil
+			}
+			var userID, sessionID string
+			for _, col := range row.PrimaryKey.PrimaryKeys {
+				switch col.ColumnName {
+				case SessionUserIDField:
+					userID = cast.ToString(col.Value)
+				case SessionSessionIDField:
+					sessionID = cast.ToString(col.Value)
+				}
+			}
+			rowChange := new(tablestore.DeleteRowChange)
+			rowChange.TableName = s.SessionTableName
+			rowChange.PrimaryKey = row.PrimaryKey
+			rowChange.SetCondition(tablestore.RowExistenceExpectation_IGNORE)
+			rowChange.SetColumnCondition(purgeable(SessionDeletedAtField))
+			return writer.Add(rowChange, func(written bool) {
+				if written {
+					report.Sessions++
+					s.owners.forget(userID, sessionID)
+				}
+			})
+		})
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	report.EndTime = model.CurrentTimeMicroseconds()
+	if err != nil {
+		return report, fmt.Errorf("purge trash failed, %w", err)
+	}
+	return report, nil
+}
@@ -1,7 +1,16 @@
 package tablestore
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
 	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore/search"
+	"github.com/golang/protobuf/proto"
 	"github.com/spf13/cast"
 
 	"github.com/bububa/tablestore-memory/model"
@@ -24,8 +33,22 @@ func parseSessionFromRow(session *model.Session, columns []*tablestore.Attribute
 		switch col.ColumnName {
 		case SessionUpdateTimeField:
 			session.UpdateTime = cast.ToInt64(col.Value)
+		case SessionTitleField:
+			session.Title = cast.ToString(col.Value)
+		case SessionCreateTimeField:
+			session.CreateTime = cast.ToInt64(col.Value)
+		case SessionStatusField:
+			session.Status = model.SessionStatus(cast.ToString(col.Value))
+		case SessionPinnedField:
+			session.Pinned = cast.ToBool(col.Value)
+		case SessionDeletedAtField:
+			session.DeletedAt = cast.ToInt64(col.Value)
+		case SessionActiveBranchField:
+			session.ActiveBranchID = cast.ToString(col.Value)
 		case SessionSearchContentField:
 			session.SearchContent = cast.ToString(col.Value)
+		case SessionEmbeddingField:
+			session.Embedding = decodeVector(col.Value)
 		default:
 			if session.Metadata == nil {
 				session.Metadata = model.NewMetadata()
@@ -49,6 +72,24 @@ func configBatchSize(batchSize int, iteratorMaxCount int, filter tablestore.Colu
 	return batchSize
 }
 
+// andColumnFilters combines the non nil filters with AND, returning nil when none is left
+func andColumnFilters(filters ...tablestore.ColumnFilter) tablestore.ColumnFilter {
+	filters = slices.DeleteFunc(filters, func(v tablestore.ColumnFilter) bool {
+		return v == nil
+	})
+	switch len(filters) {
+	case 0:
+		return nil
+	case 1:
+		return filters[0]
+	}
+	ret := tablestore.NewCompositeColumnCondition(tablestore.LO_AND)
+	for _, v := range filters {
+		ret.AddFilter(v)
+	}
+	return ret
+}
+
 func parseMessageFromRow(message *model.Message, columns []*tablestore.AttributeColumn, primaryKey *tablestore.PrimaryKey) {
 	if primaryKey != nil {
 		for _, col := range primaryKey.PrimaryKeys {
@@ -64,10 +105,24 @@ func parseMessageFromRow(message *model.Message, columns []*tablestore.Attribute
 	}
 	for _, col := range columns {
 		switch col.ColumnName {
+		case MessageUserIDField:
+			message.UserID = cast.ToString(col.Value)
 		case MessageContentField:
 			message.Content = cast.ToString(col.Value)
 		case MessageSearchContentField:
 			message.SearchContent = cast.ToString(col.Value)
+		case MessageEmbeddingField:
+			message.Embedding = decodeVector(col.Value)
+		case MessageDeletedAtField:
+			message.DeletedAt = cast.ToInt64(col.Value)
+		case MessageParentIDField:
+			message.ParentMessageID = cast.ToString(col.Value)
+		case MessageBranchIDField:
+			message.BranchID = cast.ToString(col.Value)
+		case MessageStreamStatusField:
+			message.StreamStatus = model.StreamStatus(cast.ToString(col.Value))
+		case MessageStreamUpdateField:
+			message.StreamUpdateTime = cast.ToInt64(col.Value)
 		default:
 			if message.Metadata == nil {
 				message.Metadata = model.NewMetadata()
@@ -76,3 +131,240 @@ func parseMessageFromRow(message *model.Message, columns []*tablestore.Attribute
 		}
 	}
 }
+
+func (s *tableClient) searchIndexExists(tableName string, indexName string) (bool, error) {
+	listReq := new(tablestore.ListSearchIndexRequest)
+	listReq.TableName = tableName
+	resp, err := s.clt.ListSearchIndex(listReq)
+	if err != nil {
+		return false, err
+	}
+	for _, v := range resp.IndexInfo {
+		if v.IndexName == indexName {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// describeTableIfExists returns nil response without error if the table does not exist
+func (s *tableClient) describeTableIfExists(tableName string) (*tablestore.DescribeTableResponse, error) {
+	listResp, err := s.clt.ListTable()
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(listResp.TableNames, tableName) {
+		return nil, nil
+	}
+	describeReq := new(tablestore.DescribeTableRequest)
+	describeReq.TableName = tableName
+	return s.clt.DescribeTable(describeReq)
+}
+
+func (s *tableClient) deleteSearchIndexIfExists(tableName string, indexName string) error {
+	exists, err := s.searchIndexExists(tableName, indexName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
+	deleteReq := new(tablestore.DeleteSearchIndexRequest)
+	deleteReq.TableName = tableName
+	deleteReq.IndexName = indexName
+	_, err = s.clt.DeleteSearchIndex(deleteReq)
+	return err
+}
+
+func (s *tableClient) deleteSecondaryIndexIfExists(tableName string, indexName string, indexMetas []*tablestore.IndexMeta) error {
+	for _, v := range indexMetas {
+		if v.IndexName != indexName {
+			continue
+		}
+		deleteReq := new(tablestore.DeleteIndexRequest)
+		deleteReq.MainTableName = tableName
+		deleteReq.IndexName = indexName
+		_, err := s.clt.DeleteIndex(deleteReq)
+		return err
+	}
+	return nil
+}
+
+type primaryKeySpec struct {
+	name    string
+	keyType tablestore.PrimaryKeyType
+}
+
+func checkSessionTableSchema(meta *tablestore.TableMeta) error {
+	return checkTableSchema(meta, []primaryKeySpec{
+		{name: SessionUserIDField, keyType: tablestore.PrimaryKeyType_STRING},
+		{name: SessionSessionIDField, keyType: tablestore.PrimaryKeyType_STRING},
+	}, []string{SessionUpdateTimeField})
+}
+
+func checkMessageTableSchema(meta *tablestore.TableMeta) error {
+	return checkTableSchema(meta, []primaryKeySpec{
+		{name: MessageSessionIDField, keyType: tablestore.PrimaryKeyType_STRING},
+		{name: MessageCreateTimeField, keyType: tablestore.PrimaryKeyType_INTEGER},
+		{name: MessageMessageIDField, keyType: tablestore.PrimaryKeyType_STRING},
+	}, []string{MessageContentField})
+}
+
+func checkMessageHistoryTableSchema(meta *tablestore.TableMeta) error {
+	return checkTableSchema(meta, []primaryKeySpec{
+		{name: MessageSessionIDField, keyType: tablestore.PrimaryKeyType_STRING},
+		{name: MessageMessageIDField, keyType: tablestore.PrimaryKeyType_STRING},
+		{name: MessageHistoryValidToField, keyType: tablestore.PrimaryKeyType_INTEGER},
+	}, []string{MessageContentField})
+}
+
+func checkMemoryTableSchema(meta *tablestore.TableMeta) error {
+	return checkTableSchema(meta, []primaryKeySpec{
+		{name: MemoryUserIDField, keyType: tablestore.PrimaryKeyType_STRING},
+		{name: MemoryMemoryIDField, keyType: tablestore.PrimaryKeyType_STRING},
+	}, []string{MemoryContentField})
+}
+
+// checkTableSchema verifies a table was created by this library by comparing its primary keys and defined columns
+func checkTableSchema(meta *tablestore.TableMeta, primaryKeys []primaryKeySpec, definedColumns []string) error {
+	if meta == nil {
+		return errors.New("missing table meta")
+	}
+	if len(meta.SchemaEntry) != len(primaryKeys) {
+		return fmt.Errorf("table has %d primary keys, expected %d", len(meta.SchemaEntry), len(primaryKeys))
+	}
+	for idx, v := range meta.SchemaEntry {
+		expected := primaryKeys[idx]
+		if v.Name == nil || *v.Name != expected.name {
+			return fmt.Errorf("primary key #%d is not %s", idx, expected.name)
+		}
+		if v.Type == nil || *v.Type != expected.keyType {
+			return fmt.Errorf("primary key %s has unexpected type", expected.name)
+		}
+	}
+	for _, name := range definedColumns {
+		if !slices.ContainsFunc(meta.DefinedColumns, func(col *tablestore.DefinedColumnSchema) bool {
+			return col.Name == name
+		}) {
+			return fmt.Errorf("missing defined column %s", name)
+		}
+	}
+	return nil
+}
+
+func messagePrimaryKey(sessionID string, createTime int64, messageID string) *tablestore.PrimaryKey {
+	pk := new(tablestore.PrimaryKey)
+	pk.AddPrimaryKeyColumn(MessageSessionIDField, sessionID)
+	pk.AddPrimaryKeyColumn(MessageCreateTimeField, createTime)
+	pk.AddPrimaryKeyColumn(MessageMessageIDField, messageID)
+	return pk
+}
+
+func checkKnowledgeTableSchema(meta *tablestore.TableMeta) error {
+	return checkTableSchema(meta, []primaryKeySpec{
+		{name: KnowledgeTenantIDField, keyType: tablestore.PrimaryKeyType_STRING},
+		{name: KnowledgeDocumentIDField, keyType: tablestore.PrimaryKeyType_STRING},
+		{name: KnowledgeChunkIndexField, keyType: tablestore.PrimaryKeyType_INTEGER},
+	}, []string{KnowledgeContentField})
+}
+
+// encodeVector encodes a vector as the JSON array string expected by vector fields of a search index
+func encodeVector(vector []float32) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, v := range vector {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(float64(v), 'g', -1, 32))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+func decodeVector(value any) []float32 {
+	var vector []float32
+	if err := json.Unmarshal([]byte(cast.ToString(value)), &vector); err != nil {
+		return nil
+	}
+	return vector
+}
+
+// indexFieldSchema search index schema of an indexed metadata column
+func indexFieldSchema(field model.IndexField) (*tablestore.FieldSchema, error) {
+	var fieldType tablestore.FieldType
+	switch field.Type {
+	case model.STRING:
+		fieldType = tablestore.FieldType_KEYWORD
+	case model.INTEGER:
+		fieldType = tablestore.FieldType_LONG
+	case model.DOUBLE:
+		fieldType = tablestore.FieldType_DOUBLE
+	case model.BOOLEAN:
+		fieldType = tablestore.FieldType_BOOLEAN
+	default:
+		return nil, fmt.Errorf("metadata field %s of type %s can not be indexed", field.Name, field.Type)
+	}
+	return &tablestore.FieldSchema{
+		FieldName:        proto.String(field.Name),
+		FieldType:        fieldType,
+		Index:            proto.Bool(true),
+		EnableSortAndAgg: proto.Bool(true),
+	}, nil
+}
+
+// metadataFilterQuery search query of a metadata filter
+func metadataFilterQuery(filter model.MetadataFilter) search.Query {
+	if len(filter.Values) == 1 {
+		return &search.TermQuery{FieldName: filter.Field, Term: filter.Values[0]}
+	}
+	if len(filter.Values) > 1 {
+		return &search.TermsQuery{FieldName: filter.Field, Terms: filter.Values}
+	}
+	query := &search.RangeQuery{
+		FieldName: filter.Field,
+		From:      tablestore.MIN,
+		To:        tablestore.MAX,
+	}
+	if filter.From != nil {
+		query.From = filter.From
+		query.IncludeLower = true
+	}
+	if filter.To != nil {
+		query.To = filter.To
+		query.IncludeUpper = true
+	}
+	return query
+}
+
+// isOtsErrorCode reports whether err is a TableStore error with one of the codes
+func isOtsErrorCode(err error, codes ...string) bool {
+	var otsErr *tablestore.OtsError
+	if !errors.As(err, &otsErr) {
+		return false
+	}
+	return slices.Contains(codes, otsErr.Code)
+}
+
+// searchIndexHasField reports whether a search index of the store indexes field, the schema is read
+// once and a failed read reports false without being cached
+func (s *MemoryStore) searchIndexHasField(tableName string, indexName string, field string) bool {
+	if v, ok := s.indexedFields.Load(indexName); ok {
+		return slices.Contains(v.([]string), field)
+	}
+	describeReq := new(tablestore.DescribeSearchIndexRequest)
+	describeReq.TableName = tableName
+	describeReq.IndexName = indexName
+	describeResp, err := s.clt.DescribeSearchIndex(describeReq)
+	if err != nil || describeResp.Schema == nil {
+		return false
+	}
+	fields := make([]string, 0, len(describeResp.Schema.FieldSchemas))
+	for _, v := range describeResp.Schema.FieldSchemas {
+		if v.FieldName != nil {
+			fields = append(fields, *v.FieldName)
+		}
+	}
+	s.indexedFields.Store(indexName, fields)
+	return slices.Contains(fields, field)
+}
@@ -0,0 +1,300 @@
+package tablestore
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/cast"
+
+	"github.com/bububa/tablestore-memory/model"
+)
+
+// waitIndexPollInterval interval between search index sync checks of ConsistencyWaitIndex
+const waitIndexPollInterval = 200 * time.Millisecond
+
+// writeEntry a row written or deleted through the store
+type writeEntry struct {
+	at    time.Time
+	table string
+	key   string
+	// value latest written row, nil when deleted
+	value any
+}
+
+// writeLog short-lived in-process log of written rows, it lets searches read their own writes before
+// the search indexes sync them
+type writeLog struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	size      int
+	entries   []writeEntry
+	lastWrite map[string]time.Time
+}
+
+func newWriteLog(ttl time.Duration, size int) *writeLog {
+	return &writeLog{
+		ttl:       ttl,
+		size:      size,
+		lastWrite: make(map[string]time.Time),
+	}
+}
+
+// record logs a written row, value nil marks the row deleted
+func (l *writeLog) record(table string, key string, value any) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lastWrite[table] = now
+	l.entries = append(l.entries, writeEntry{at: now, table: table, key: key, value: value})
+	l.expire(now)
+}
+
+// touch logs a write to table without keeping the row
+func (l *writeLog) touch(table string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lastWrite[table] = time.Now()
+}
+
+func (l *writeLog) lastWriteTime(table string) time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lastWrite[table]
+}
+
+// recent latest entry of each row of table still in the log
+func (l *writeLog) recent(table string) []writeEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.expire(time.Now())
+	var (
+		ret  []writeEntry
+		seen = make(map[string]struct{})
+	)
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		entry := l.entries[i]
+		if entry.table != table {
+			continue
+		}
+		if _, ok := seen[entry.key]; ok {
+			continue
+		}
+		seen[entry.key] = struct{}{}
+		ret = append(ret, entry)
+	}
+	slices.Reverse(ret)
+	return ret
+}
+
+func (l *writeLog) expire(now time.Time) {
+	idx := 0
+	for idx < len(l.entries) && now.Sub(l.entries[idx].at) > l.ttl {
+		idx++
+	}
+	if overflow := len(l.entries) - idx - l.size; overflow > 0 {
+		idx += overflow
+	}
+	if idx > 0 {
+		l.entries = slices.Delete(l.entries, 0, idx)
+	}
+}
+
+func messageWriteKey(sessionID string, messageID string) string {
+	return sessionID + "\x00" + messageID
+}
+
+func sessionWriteKey(userID string, sessionID string) string {
+	return userID + "\x00" + sessionID
+}
+
+// waitForIndex blocks until the search index synced the last write of this store to its table
+func (s *MemoryStore) waitForIndex(tableName string, indexName string, timeout time.Duration) error {
+	lastWrite := s.writes.lastWriteTime(tableName)
+	if lastWrite.IsZero() {
+		return nil
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		describeReq := new(tablestore.DescribeSearchIndexRequest)
+		describeReq.TableName = tableName
+		describeReq.IndexName = indexName
+		describeReq.IncludeSyncStat = proto.Bool(true)
+		describeResp, err := s.clt.DescribeSearchIndex(describeReq)
+		if err != nil {
+			return fmt.Errorf("describe search index %s failed during wait for index, %w", indexName, err)
+		}
+		if stat := describeResp.SyncStat; stat != nil && stat.CurrentSyncTimestamp != nil {
+			if !syncTime(*stat.CurrentSyncTimestamp).Before(lastWrite) {
+				return nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("wait for search index %s to sync writes timeout after %s", indexName, timeout)
+		}
+		time.Sleep(waitIndexPollInterval)
+	}
+}
+
+// syncTime converts a search index sync timestamp, its unit is inferred from its magnitude
+func syncTime(ts int64) time.Time {
+	switch {
+	case ts > 1e17:
+		return time.Unix(0, ts)
+	case ts > 1e14:
+		return time.UnixMicro(ts)
+	case ts > 1e11:
+		return time.UnixMilli(ts)
+	}
+	return time.Unix(ts, 0)
+}
+
+// mergedHit a hit of a merged page, merged when it comes from the write log
+type mergedHit[T any] struct {
+	value  T
+	detail model.HitDetail
+	merged bool
+}
+
+// mergeWrites merges rows written through the store into the first page of search results. Deleted
+// rows and rows no longer matching are dropped. Matching rows missing from the index are inserted by
+// compare, or put first when it is nil as their score is unknown. Rows sorting after the last hit of a
+// page followed by others are left to the index, and merged rows beyond pageSize are dropped so the
+// indexed hits of the page are kept.
+func mergeWrites[T any](ret *model.Response[T], entries []writeEntry, pageSize int, key func(*T) string, match func(*T) bool, compare func(a, b *T) int) {
+	if len(entries) == 0 {
+		return
+	}
+	latest := make(map[string]*T, len(entries))
+	for _, entry := range entries {
+		var value *T
+		if v, ok := entry.value.(*T); ok {
+			value = v
+		}
+		latest[entry.key] = value
+	}
+	var (
+		page    = make([]mergedHit[T], 0, len(ret.Hits)+len(entries))
+		indexed = make(map[string]struct{}, len(ret.Hits))
+		removed int64
+	)
+	for idx := range ret.Hits {
+		hit := mergedHit[T]{value: ret.Hits[idx]}
+		if idx < len(ret.Details) {
+			hit.detail = ret.Details[idx]
+		}
+		k := key(&hit.value)
+		indexed[k] = struct{}{}
+		if value, ok := latest[k]; ok {
+			if value == nil || !match(value) {
+				removed++
+				continue
+			}
+			hit.value = *value
+		}
+		page = append(page, hit)
+	}
+	var unindexed []mergedHit[T]
+	for _, entry := range entries {
+		value := latest[entry.key]
+		if value == nil || !match(value) {
+			continue
+		}
+		if _, ok := indexed[entry.key]; ok {
+			continue
+		}
+		unindexed = append(unindexed, mergedHit[T]{value: *value, merged: true})
+	}
+	if compare == nil {
+		page = append(unindexed, page...)
+	} else {
+		for _, hit := range unindexed {
+			if ret.NextToken != nil && len(page) > 0 && compare(&hit.value, &page[len(page)-1].value) > 0 {
+				continue
+			}
+			pos := slices.IndexFunc(page, func(v mergedHit[T]) bool { return compare(&hit.value, &v.value) < 0 })
+			if pos < 0 {
+				pos = len(page)
+			}
+			page = slices.Insert(page, pos, hit)
+		}
+	}
+	for idx := len(page) - 1; pageSize > 0 && len(page) > pageSize && idx >= 0; idx-- {
+		if page[idx].merged {
+			page = slices.Delete(page, idx, idx+1)
+		}
+	}
+	var added int64
+	hits := make([]T, 0, len(page))
+	var details []model.HitDetail
+	if ret.Details != nil {
+		details = make([]model.HitDetail, 0, len(page))
+	}
+	for _, hit := range page {
+		if hit.merged {
+			added++
+		}
+		hits = append(hits, hit.value)
+		if details != nil {
+			details = append(details, hit.detail)
+		}
+	}
+	ret.Hits = hits
+	ret.Details = details
+	ret.Total += added - removed
+}
+
+// mergeCompare orders rows merged into search results by the field sorters of the search, it returns
+// nil when the results are sorted by score first. Missing values sort last.
+func mergeCompare[T any](sorts []model.SortField, value func(v *T, field string) any) func(a, b *T) int {
+	if len(sorts) == 0 || sorts[0].Field == model.SortByScore {
+		return nil
+	}
+	return func(a, b *T) int {
+		for _, v := range sorts {
+			if v.Field == model.SortByScore {
+				return 0
+			}
+			va, vb := value(a, v.Field), value(b, v.Field)
+			switch {
+			case va == nil && vb == nil:
+				continue
+			case va == nil:
+				return 1
+			case vb == nil:
+				return -1
+			}
+			c := compareSortValues(va, vb)
+			if v.Desc {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	}
+}
+
+// compareSortValues compares strings as keywords and numbers and booleans by value
+func compareSortValues(a any, b any) int {
+	_, isStringA := a.(string)
+	_, isStringB := b.(string)
+	if !isStringA && !isStringB {
+		fa, errA := cast.ToFloat64E(a)
+		fb, errB := cast.ToFloat64E(b)
+		if errA == nil && errB == nil {
+			return cmp.Compare(fa, fb)
+		}
+	}
+	return cmp.Compare(cast.ToString(a), cast.ToString(b))
+}
+
+// inTimeRange reports whether ts is within the inclusive range, 0 bounds are open
+func inTimeRange(ts int64, inclusiveStart int64, inclusiveEnd int64) bool {
+	return (inclusiveStart <= 0 || ts >= inclusiveStart) && (inclusiveEnd <= 0 || ts <= inclusiveEnd)
+}